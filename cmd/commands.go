@@ -0,0 +1,143 @@
+package main
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"flag"
+	"fmt"
+	"hot-coffee1/internal/service"
+	"hot-coffee1/models"
+	"os"
+	"os/user"
+	"strings"
+)
+
+// runCommand handles the management subcommands given after the global
+// flags, e.g. `hot-coffee --dir data users add alice --password secret1`.
+func runCommand(args []string) error {
+	auth := service.NewAuthService()
+	// Changes made here are audited as the operating-system user.
+	ctx := service.WithPrincipal(context.Background(), service.Principal{
+		Kind: service.PrincipalCLI, Name: currentUser(), ID: currentUser(), Role: service.RoleOwner,
+	})
+
+	switch args[0] + " " + arg(args, 1) {
+	case "users list":
+		users, err := auth.GetAllUsers()
+		if err != nil {
+			return err
+		}
+		for _, user := range users {
+			fmt.Printf("%s\t%s\tcreated %s\n", user.Username, user.Role, user.CreatedAt)
+		}
+		return nil
+	case "users add", "users passwd":
+		fs := flag.NewFlagSet(args[0]+" "+args[1], flag.ContinueOnError)
+		password := fs.String("password", "", "password (read from stdin when omitted)")
+		role := fs.String("role", service.RoleBarista, "barista, manager or owner")
+		if err := fs.Parse(args[2:]); err != nil {
+			return err
+		}
+		username := fs.Arg(0)
+		if username == "" {
+			return errors.New("username is required")
+		}
+		// Flags may also follow the username.
+		if err := fs.Parse(fs.Args()[1:]); err != nil {
+			return err
+		}
+		if *password == "" {
+			*password = readLine("Password: ")
+		}
+		if args[1] == "add" {
+			if err := auth.AddNewUser(ctx, username, *password, *role); err != nil {
+				return err
+			}
+			fmt.Println("User", username, "added as", *role)
+			return nil
+		}
+		if err := auth.ChangePassword(ctx, username, *password); err != nil {
+			return err
+		}
+		fmt.Println("Password of", username, "changed")
+		return nil
+	case "users role":
+		if arg(args, 2) == "" || arg(args, 3) == "" {
+			return errors.New("usage: users role <username> <role>")
+		}
+		if err := auth.ChangeRole(ctx, args[2], args[3]); err != nil {
+			return err
+		}
+		fmt.Println("User", args[2], "is now", args[3])
+		return nil
+	case "users delete":
+		if arg(args, 2) == "" {
+			return errors.New("username is required")
+		}
+		if err := auth.DeleteUser(ctx, args[2]); err != nil {
+			return err
+		}
+		fmt.Println("User", args[2], "deleted")
+		return nil
+	case "keys list":
+		keys, err := auth.GetAllAPIKeys()
+		if err != nil {
+			return err
+		}
+		for _, key := range keys {
+			fmt.Printf("%s\t%s\t%s\tcreated %s\n", key.ID, key.Name, key.Role, key.CreatedAt)
+		}
+		return nil
+	case "keys create":
+		fs := flag.NewFlagSet("keys create", flag.ContinueOnError)
+		role := fs.String("role", service.RoleBarista, "barista, manager or owner")
+		if err := fs.Parse(args[2:]); err != nil {
+			return err
+		}
+		name := strings.Join(fs.Args(), " ")
+		plaintext, key, err := auth.CreateAPIKey(ctx, name, *role)
+		if err != nil {
+			return err
+		}
+		fmt.Printf("API key %s for %q (store it now, it is not shown again):\n%s\n", key.ID, key.Name, plaintext)
+		return nil
+	case "migrate ":
+		files, err := service.MigrateMoney()
+		if err != nil {
+			return err
+		}
+		fmt.Println("Rewrote", strings.Join(files, ", "), "with amounts in", models.DefaultCurrency, "minor units")
+		return nil
+	case "keys revoke":
+		if arg(args, 2) == "" {
+			return errors.New("key id is required")
+		}
+		if err := auth.RevokeAPIKey(ctx, args[2]); err != nil {
+			return err
+		}
+		fmt.Println("API key", args[2], "revoked")
+		return nil
+	}
+	return fmt.Errorf("unknown command %q, see --help", strings.Join(args, " "))
+}
+
+func arg(args []string, i int) string {
+	if i < len(args) {
+		return args[i]
+	}
+	return ""
+}
+
+func readLine(prompt string) string {
+	fmt.Fprint(os.Stderr, prompt)
+	line, _ := bufio.NewReader(os.Stdin).ReadString('\n')
+	return strings.TrimRight(line, "\r\n")
+}
+
+func currentUser() string {
+	if u, err := user.Current(); err == nil {
+		return u.Username
+	}
+	return "unknown"
+}
@@ -1,31 +1,48 @@
 package main
 
 import (
+	"context"
+	"flag"
 	"fmt"
 	"hot-coffee1/internal/config"
 	"hot-coffee1/internal/handler"
+	"hot-coffee1/internal/service"
+	"hot-coffee1/models"
 	"log"
 	"net/http"
+	"os"
 )
 
 func main() {
 	if err := config.ConfigLoad(); err != nil {
 		log.Fatal(err)
 	}
+	models.DefaultCurrency = config.GetCurrency()
+
+	if args := flag.Args(); len(args) > 0 {
+		if err := runCommand(args); err != nil {
+			fmt.Fprintln(os.Stderr, err)
+			os.Exit(1)
+		}
+		return
+	}
 
 	port := config.GetConfigPort()
 
 	mux := http.NewServeMux()
 
-	handler.InventoryEndpoints(mux)
-	handler.MenuEndpoints(mux)
-	handler.OrderEndpoints(mux)
-	handler.AggregationEndpoints(mux)
+	handler.AllEndpoints(mux)
+
+	// Покрытие проверяет тест, здесь только предупреждаем
+	if err := handler.CheckOpenAPICoverage(); err != nil {
+		log.Printf("warning: %v", err)
+	}
 
-	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
-		handler.ErrorResponse(w, "405 - No such method", http.StatusMethodNotAllowed)
-	})
+	service.ConfigureNotifiers()
+	service.StartWebhookDispatcher(context.Background())
+	service.StartPreorderRelease(context.Background())
+	service.StartPriceActivation(context.Background())
 
 	fmt.Println("Server started listening on port -", port)
-	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", port), mux))
+	log.Fatal(http.ListenAndServe(fmt.Sprintf(":%d", port), handler.Authenticate(mux, handler.Authorize(mux, handler.WithFallback(mux)))))
 }
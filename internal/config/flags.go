@@ -1,23 +1,40 @@
 package config
 
 import (
+	"crypto/rand"
+	"encoding/hex"
 	"errors"
 	"flag"
 	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
+	"time"
 )
 
 type Config struct {
 	Port        int
 	Directory   string
 	StoragePath string
+	AuthSecret  []byte
+	SessionTTL  time.Duration
+	Notifiers   []string
+	SMTPRelay   string
+	SMTPFrom    string
+	SMTPTo      []string
+	Currency    string
 }
 
 func ConfigLoad() error {
 	port := flag.Int("port", 8080, "port of srever")
 	directory := flag.String("dir", "data", "data directory")
+	authSecret := flag.String("auth-secret", os.Getenv("HOT_COFFEE_AUTH_SECRET"), "secret for signing session tokens (default: generated into <dir>/auth_secret)")
+	sessionTTL := flag.Duration("session-ttl", 12*time.Hour, "lifetime of staff session tokens")
+	notifiers := flag.String("alert-notifiers", "log,webhook", "comma-separated stock alert notifiers: log, webhook, smtp")
+	smtpRelay := flag.String("smtp-relay", "localhost:25", "SMTP relay for the smtp notifier")
+	smtpFrom := flag.String("smtp-from", "hot-coffee@localhost", "sender address of alert mails")
+	smtpTo := flag.String("smtp-to", "", "comma-separated recipients of alert mails")
+	currency := flag.String("currency", "USD", "ISO 4217 code of the shop currency")
 	help := flag.Bool("help", false, "help")
 
 	flag.Parse()
@@ -37,8 +54,86 @@ func ConfigLoad() error {
 		return errors.New("port couldn't be equal less than 1024")
 	}
 
-	cfg = Config{*port, *directory, storagePath}
-	return cfg.CreateStorage()
+	if *sessionTTL <= 0 {
+		return errors.New("session TTL must be positive")
+	}
+
+	cfg = Config{
+		Port: *port, Directory: *directory, StoragePath: storagePath, SessionTTL: *sessionTTL,
+		Notifiers: splitList(*notifiers), SMTPRelay: *smtpRelay, SMTPFrom: *smtpFrom, SMTPTo: splitList(*smtpTo),
+		Currency: strings.ToUpper(*currency),
+	}
+	if len(cfg.Currency) != 3 || strings.Trim(cfg.Currency, "ABCDEFGHIJKLMNOPQRSTUVWXYZ") != "" {
+		return fmt.Errorf("invalid currency code %q", *currency)
+	}
+	for _, notifier := range cfg.Notifiers {
+		if notifier != "log" && notifier != "webhook" && notifier != "smtp" {
+			return fmt.Errorf("unknown alert notifier %q", notifier)
+		}
+		if notifier == "smtp" && len(cfg.SMTPTo) == 0 {
+			return errors.New("the smtp notifier needs --smtp-to")
+		}
+	}
+	if err := cfg.CreateStorage(); err != nil {
+		return err
+	}
+	return cfg.loadAuthSecret(*authSecret)
+}
+
+func GetAuthSecret() []byte {
+	return cfg.AuthSecret
+}
+
+func GetSessionTTL() time.Duration {
+	return cfg.SessionTTL
+}
+
+// loadAuthSecret prefers an explicit secret, then the one persisted in the
+// storage directory, and generates a new one on first start.
+func (c *Config) loadAuthSecret(explicit string) error {
+	if explicit != "" {
+		c.AuthSecret = []byte(explicit)
+		return nil
+	}
+
+	path := filepath.Join(c.StoragePath, "auth_secret")
+	if data, err := os.ReadFile(path); err == nil && len(strings.TrimSpace(string(data))) > 0 {
+		c.AuthSecret = []byte(strings.TrimSpace(string(data)))
+		return nil
+	}
+
+	buf := make([]byte, 32)
+	if _, err := rand.Read(buf); err != nil {
+		return fmt.Errorf("could not generate auth secret: %w", err)
+	}
+	secret := hex.EncodeToString(buf)
+	if err := os.WriteFile(path, []byte(secret), 0o600); err != nil {
+		return fmt.Errorf("could not save auth secret: %w", err)
+	}
+	c.AuthSecret = []byte(secret)
+	return nil
+}
+
+func GetCurrency() string {
+	return cfg.Currency
+}
+
+func GetNotifiers() []string {
+	return cfg.Notifiers
+}
+
+func GetSMTP() (relay, from string, to []string) {
+	return cfg.SMTPRelay, cfg.SMTPFrom, cfg.SMTPTo
+}
+
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
 }
 
 func GetStoragePath() string {
@@ -100,6 +195,13 @@ func printHelp() {
 
 Usage:
   hot-coffee [--port <N>] [--dir <S>] 
+  hot-coffee [--dir <S>] users add <username> --password <P> --role barista|manager|owner
+  hot-coffee [--dir <S>] users passwd <username> --password <P>
+  hot-coffee [--dir <S>] users role <username> barista|manager|owner
+  hot-coffee [--dir <S>] users list|delete [<username>]
+  hot-coffee [--dir <S>] keys create --role <R> <name>
+  hot-coffee [--dir <S>] keys list|revoke [<id>]
+  hot-coffee [--dir <S>] [--currency <C>] migrate
   hot-coffee --help`)
 	fmt.Println("\nOptions:")
 	flag.PrintDefaults()
@@ -0,0 +1,23 @@
+package dal
+
+import (
+	"hot-coffee1/models"
+
+	repositories "hot-coffee1/internal/dal/utils"
+)
+
+type alertRepo struct{}
+
+func NewAlertRepository() repositories.AlertRepository {
+	return &alertRepo{}
+}
+
+func (repo *alertRepo) ReadAlerts() ([]models.Alert, error) {
+	var alerts []models.Alert
+	err := readJSONFile("alerts.json", &alerts)
+	return alerts, err
+}
+
+func (repo *alertRepo) WriteAlerts(alerts []models.Alert) error {
+	return writeJSONFile("alerts.json", alerts)
+}
@@ -0,0 +1,76 @@
+package dal
+
+import (
+	"bufio"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hot-coffee1/internal/config"
+	"hot-coffee1/models"
+	"os"
+	"path/filepath"
+
+	repositories "hot-coffee1/internal/dal/utils"
+)
+
+const auditFile = "audit.jsonl"
+
+type auditRepo struct{}
+
+func NewAuditRepository() repositories.AuditRepository {
+	return &auditRepo{}
+}
+
+func (repo *auditRepo) ReadAudit() ([]models.AuditEntry, error) {
+	storeMu.Lock()
+	defer storeMu.Unlock()
+
+	file, err := os.Open(filepath.Join(config.GetStoragePath(), auditFile))
+	if errors.Is(err, os.ErrNotExist) {
+		return nil, nil
+	}
+	if err != nil {
+		return nil, errors.New("unable to open " + auditFile + ": " + err.Error())
+	}
+	defer file.Close()
+
+	var entries []models.AuditEntry
+	scanner := bufio.NewScanner(file)
+	scanner.Buffer(make([]byte, 64*1024), 16*1024*1024)
+	for line := 1; scanner.Scan(); line++ {
+		if len(scanner.Bytes()) == 0 {
+			continue
+		}
+		var entry models.AuditEntry
+		if err := json.Unmarshal(scanner.Bytes(), &entry); err != nil {
+			return nil, fmt.Errorf("unable to read %s line %d: %v", auditFile, line, err)
+		}
+		entries = append(entries, entry)
+	}
+	if err := scanner.Err(); err != nil {
+		return nil, errors.New("unable to read " + auditFile + ": " + err.Error())
+	}
+	return entries, nil
+}
+
+// AppendAudit only ever appends; existing lines are never rewritten.
+func (repo *auditRepo) AppendAudit(entry models.AuditEntry) error {
+	data, err := json.Marshal(entry)
+	if err != nil {
+		return errors.New("unable to format audit entry: " + err.Error())
+	}
+
+	storeMu.Lock()
+	defer storeMu.Unlock()
+
+	file, err := os.OpenFile(filepath.Join(config.GetStoragePath(), auditFile), os.O_WRONLY|os.O_APPEND|os.O_CREATE, 0o644)
+	if err != nil {
+		return errors.New("unable to open " + auditFile + ": " + err.Error())
+	}
+	defer file.Close()
+
+	if _, err := file.Write(append(data, '\n')); err != nil {
+		return errors.New("unable to write " + auditFile + ": " + err.Error())
+	}
+	return file.Sync()
+}
@@ -0,0 +1,23 @@
+package dal
+
+import (
+	"hot-coffee1/models"
+
+	repositories "hot-coffee1/internal/dal/utils"
+)
+
+type categoryRepo struct{}
+
+func NewCategoryRepository() repositories.CategoryRepository {
+	return &categoryRepo{}
+}
+
+func (repo *categoryRepo) ReadCategories() ([]models.Category, error) {
+	var categories []models.Category
+	err := readJSONFile("categories.json", &categories)
+	return categories, err
+}
+
+func (repo *categoryRepo) WriteCategories(categories []models.Category) error {
+	return writeJSONFile("categories.json", categories)
+}
@@ -0,0 +1,23 @@
+package dal
+
+import (
+	"hot-coffee1/models"
+
+	repositories "hot-coffee1/internal/dal/utils"
+)
+
+type customerRepo struct{}
+
+func NewCustomerRepository() repositories.CustomerRepository {
+	return &customerRepo{}
+}
+
+func (repo *customerRepo) ReadCustomers() ([]models.Customer, error) {
+	var customers []models.Customer
+	err := readJSONFile("customers.json", &customers)
+	return customers, err
+}
+
+func (repo *customerRepo) WriteCustomers(customers []models.Customer) error {
+	return writeJSONFile("customers.json", customers)
+}
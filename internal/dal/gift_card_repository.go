@@ -0,0 +1,31 @@
+package dal
+
+import (
+	"hot-coffee1/models"
+
+	repositories "hot-coffee1/internal/dal/utils"
+)
+
+type giftCardRepo struct{}
+
+func NewGiftCardRepository() repositories.GiftCardRepository {
+	return &giftCardRepo{}
+}
+
+func (repo *giftCardRepo) ReadGiftCards() ([]models.GiftCard, error) {
+	var cards []models.GiftCard
+	err := readJSONFile("gift_cards.json", &cards)
+	return cards, err
+}
+
+func (repo *giftCardRepo) ReadGiftCardLedger() ([]models.GiftCardTransaction, error) {
+	var transactions []models.GiftCardTransaction
+	err := readJSONFile("gift_card_ledger.json", &transactions)
+	return transactions, err
+}
+
+// WriteGiftCardsWithLedger saves the cards and the ledger together, so a
+// balance never changes without its transaction.
+func (repo *giftCardRepo) WriteGiftCardsWithLedger(cards []models.GiftCard, transactions []models.GiftCardTransaction) error {
+	return writeJSONFiles(jsonFile{"gift_cards.json", cards}, jsonFile{"gift_card_ledger.json", transactions})
+}
@@ -0,0 +1,110 @@
+package dal
+
+import (
+	"encoding/json"
+	"errors"
+	"hot-coffee1/internal/config"
+	"os"
+	"path/filepath"
+	"sync"
+)
+
+// storeMu serialises access to the JSON files written through this helper;
+// background workers and request handlers share them.
+var storeMu sync.Mutex
+
+func readJSONFile(name string, v any) error {
+	storeMu.Lock()
+	defer storeMu.Unlock()
+
+	file, err := os.OpenFile(filepath.Join(config.GetStoragePath(), name), os.O_RDWR|os.O_CREATE, 0o644)
+	if err != nil {
+		return errors.New("unable to open " + name + ": " + err.Error())
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return errors.New("unable to get file info: " + err.Error())
+	}
+
+	if stat.Size() > 0 {
+		if err := json.NewDecoder(file).Decode(v); err != nil {
+			return errors.New("unable to read " + name + ": " + err.Error())
+		}
+	}
+	return nil
+}
+
+func writeJSONFile(name string, v any) error {
+	storeMu.Lock()
+	defer storeMu.Unlock()
+
+	data, err := json.MarshalIndent(v, "", "    ")
+	if err != nil {
+		return errors.New("unable to format " + name + ": " + err.Error())
+	}
+
+	path := filepath.Join(config.GetStoragePath(), name)
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, data, 0o644); err != nil {
+		return errors.New("unable to write " + name + ": " + err.Error())
+	}
+	if err := os.Rename(tmp, path); err != nil {
+		return errors.New("unable to replace " + name + ": " + err.Error())
+	}
+	return nil
+}
+
+// jsonFile is one file written by writeJSONFiles.
+type jsonFile struct {
+	name string
+	v    any
+}
+
+// writeJSONFiles replaces files together: each is written to a temporary
+// file first, and if a rename fails the files already replaced get their
+// old contents back.
+func writeJSONFiles(files ...jsonFile) error {
+	storeMu.Lock()
+	defer storeMu.Unlock()
+
+	paths := make([]string, len(files))
+	for i, file := range files {
+		data, err := json.MarshalIndent(file.v, "", "    ")
+		if err == nil {
+			paths[i] = filepath.Join(config.GetStoragePath(), file.name)
+			err = os.WriteFile(paths[i]+".tmp", data, 0o644)
+		}
+		if err != nil {
+			for _, path := range paths[:i+1] {
+				os.Remove(path + ".tmp")
+			}
+			return errors.New("unable to write " + file.name + ": " + err.Error())
+		}
+	}
+
+	// Старое содержимое нужно, чтобы откатить уже заменённые файлы
+	old := make([][]byte, len(paths))
+	for i, path := range paths {
+		if data, err := os.ReadFile(path); err == nil {
+			old[i] = data
+		}
+	}
+	for i, path := range paths {
+		if err := os.Rename(path+".tmp", path); err != nil {
+			for j, done := range paths[:i] {
+				if old[j] == nil {
+					os.Remove(done)
+				} else {
+					os.WriteFile(done, old[j], 0o644)
+				}
+			}
+			for _, rest := range paths[i:] {
+				os.Remove(rest + ".tmp")
+			}
+			return errors.New("unable to replace " + files[i].name + ": " + err.Error())
+		}
+	}
+	return nil
+}
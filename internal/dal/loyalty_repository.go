@@ -0,0 +1,33 @@
+package dal
+
+import (
+	"hot-coffee1/models"
+
+	repositories "hot-coffee1/internal/dal/utils"
+)
+
+type loyaltyRepo struct{}
+
+func NewLoyaltyRepository() repositories.LoyaltyRepository {
+	return &loyaltyRepo{}
+}
+
+func (repo *loyaltyRepo) ReadLoyaltyProgram() (models.LoyaltyProgram, error) {
+	var program models.LoyaltyProgram
+	err := readJSONFile("loyalty.json", &program)
+	return program, err
+}
+
+func (repo *loyaltyRepo) WriteLoyaltyProgram(program models.LoyaltyProgram) error {
+	return writeJSONFile("loyalty.json", program)
+}
+
+func (repo *loyaltyRepo) ReadLoyaltyLedger() ([]models.LoyaltyEntry, error) {
+	var entries []models.LoyaltyEntry
+	err := readJSONFile("loyalty_ledger.json", &entries)
+	return entries, err
+}
+
+func (repo *loyaltyRepo) WriteLoyaltyLedger(entries []models.LoyaltyEntry) error {
+	return writeJSONFile("loyalty_ledger.json", entries)
+}
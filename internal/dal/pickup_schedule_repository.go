@@ -0,0 +1,23 @@
+package dal
+
+import (
+	"hot-coffee1/models"
+
+	repositories "hot-coffee1/internal/dal/utils"
+)
+
+type pickupScheduleRepo struct{}
+
+func NewPickupScheduleRepository() repositories.PickupScheduleRepository {
+	return &pickupScheduleRepo{}
+}
+
+func (repo *pickupScheduleRepo) ReadPickupSchedule() (models.PickupSchedule, error) {
+	var schedule models.PickupSchedule
+	err := readJSONFile("pickup_schedule.json", &schedule)
+	return schedule, err
+}
+
+func (repo *pickupScheduleRepo) WritePickupSchedule(schedule models.PickupSchedule) error {
+	return writeJSONFile("pickup_schedule.json", schedule)
+}
@@ -0,0 +1,23 @@
+package dal
+
+import (
+	"hot-coffee1/models"
+
+	repositories "hot-coffee1/internal/dal/utils"
+)
+
+type pricingRuleRepo struct{}
+
+func NewPricingRuleRepository() repositories.PricingRuleRepository {
+	return &pricingRuleRepo{}
+}
+
+func (repo *pricingRuleRepo) ReadPricingRules() ([]models.PricingRule, error) {
+	var rules []models.PricingRule
+	err := readJSONFile("pricing_rules.json", &rules)
+	return rules, err
+}
+
+func (repo *pricingRuleRepo) WritePricingRules(rules []models.PricingRule) error {
+	return writeJSONFile("pricing_rules.json", rules)
+}
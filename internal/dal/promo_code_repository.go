@@ -0,0 +1,23 @@
+package dal
+
+import (
+	"hot-coffee1/models"
+
+	repositories "hot-coffee1/internal/dal/utils"
+)
+
+type promoCodeRepo struct{}
+
+func NewPromoCodeRepository() repositories.PromoCodeRepository {
+	return &promoCodeRepo{}
+}
+
+func (repo *promoCodeRepo) ReadPromoCodes() ([]models.PromoCode, error) {
+	var codes []models.PromoCode
+	err := readJSONFile("promo_codes.json", &codes)
+	return codes, err
+}
+
+func (repo *promoCodeRepo) WritePromoCodes(codes []models.PromoCode) error {
+	return writeJSONFile("promo_codes.json", codes)
+}
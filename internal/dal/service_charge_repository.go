@@ -0,0 +1,23 @@
+package dal
+
+import (
+	"hot-coffee1/models"
+
+	repositories "hot-coffee1/internal/dal/utils"
+)
+
+type serviceChargeRepo struct{}
+
+func NewServiceChargeRepository() repositories.ServiceChargeRepository {
+	return &serviceChargeRepo{}
+}
+
+func (repo *serviceChargeRepo) ReadServiceCharge() (models.ServiceCharge, error) {
+	var charge models.ServiceCharge
+	err := readJSONFile("service_charge.json", &charge)
+	return charge, err
+}
+
+func (repo *serviceChargeRepo) WriteServiceCharge(charge models.ServiceCharge) error {
+	return writeJSONFile("service_charge.json", charge)
+}
@@ -0,0 +1,23 @@
+package dal
+
+import (
+	"hot-coffee1/models"
+
+	repositories "hot-coffee1/internal/dal/utils"
+)
+
+type taxRepo struct{}
+
+func NewTaxRepository() repositories.TaxRepository {
+	return &taxRepo{}
+}
+
+func (repo *taxRepo) ReadTaxConfig() (models.TaxConfig, error) {
+	var config models.TaxConfig
+	err := readJSONFile("tax.json", &config)
+	return config, err
+}
+
+func (repo *taxRepo) WriteTaxConfig(config models.TaxConfig) error {
+	return writeJSONFile("tax.json", config)
+}
@@ -0,0 +1,39 @@
+package dal
+
+import (
+	"hot-coffee1/models"
+
+	repositories "hot-coffee1/internal/dal/utils"
+)
+
+type userRepo struct{}
+
+func NewUserRepository() repositories.UserRepository {
+	return &userRepo{}
+}
+
+func (repo *userRepo) ReadUsers() ([]models.User, error) {
+	var users []models.User
+	err := readJSONFile("users.json", &users)
+	return users, err
+}
+
+func (repo *userRepo) WriteUsers(users []models.User) error {
+	return writeJSONFile("users.json", users)
+}
+
+type apiKeyRepo struct{}
+
+func NewAPIKeyRepository() repositories.APIKeyRepository {
+	return &apiKeyRepo{}
+}
+
+func (repo *apiKeyRepo) ReadAPIKeys() ([]models.APIKey, error) {
+	var keys []models.APIKey
+	err := readJSONFile("api_keys.json", &keys)
+	return keys, err
+}
+
+func (repo *apiKeyRepo) WriteAPIKeys(keys []models.APIKey) error {
+	return writeJSONFile("api_keys.json", keys)
+}
@@ -16,3 +16,83 @@ type OrderRepository interface {
 	ReadOrder() ([]models.Order, error)
 	WriteOrder([]models.Order) error
 }
+
+type UserRepository interface {
+	ReadUsers() ([]models.User, error)
+	WriteUsers([]models.User) error
+}
+
+type APIKeyRepository interface {
+	ReadAPIKeys() ([]models.APIKey, error)
+	WriteAPIKeys([]models.APIKey) error
+}
+
+type AuditRepository interface {
+	ReadAudit() ([]models.AuditEntry, error)
+	AppendAudit(models.AuditEntry) error
+}
+
+type WebhookRepository interface {
+	ReadWebhooks() ([]models.Webhook, error)
+	WriteWebhooks([]models.Webhook) error
+	ReadDeliveries() ([]models.WebhookDelivery, error)
+	WriteDeliveries([]models.WebhookDelivery) error
+}
+
+type CustomerRepository interface {
+	ReadCustomers() ([]models.Customer, error)
+	WriteCustomers([]models.Customer) error
+}
+
+type CategoryRepository interface {
+	ReadCategories() ([]models.Category, error)
+	WriteCategories([]models.Category) error
+}
+
+type PricingRuleRepository interface {
+	ReadPricingRules() ([]models.PricingRule, error)
+	WritePricingRules([]models.PricingRule) error
+}
+
+type PromoCodeRepository interface {
+	ReadPromoCodes() ([]models.PromoCode, error)
+	WritePromoCodes([]models.PromoCode) error
+}
+
+type TaxRepository interface {
+	ReadTaxConfig() (models.TaxConfig, error)
+	WriteTaxConfig(models.TaxConfig) error
+}
+
+type GiftCardRepository interface {
+	ReadGiftCards() ([]models.GiftCard, error)
+	ReadGiftCardLedger() ([]models.GiftCardTransaction, error)
+	WriteGiftCardsWithLedger([]models.GiftCard, []models.GiftCardTransaction) error
+}
+
+type VoucherRepository interface {
+	ReadVouchers() ([]models.Voucher, error)
+	WriteVouchers([]models.Voucher) error
+}
+
+type LoyaltyRepository interface {
+	ReadLoyaltyProgram() (models.LoyaltyProgram, error)
+	WriteLoyaltyProgram(models.LoyaltyProgram) error
+	ReadLoyaltyLedger() ([]models.LoyaltyEntry, error)
+	WriteLoyaltyLedger([]models.LoyaltyEntry) error
+}
+
+type ServiceChargeRepository interface {
+	ReadServiceCharge() (models.ServiceCharge, error)
+	WriteServiceCharge(models.ServiceCharge) error
+}
+
+type PickupScheduleRepository interface {
+	ReadPickupSchedule() (models.PickupSchedule, error)
+	WritePickupSchedule(models.PickupSchedule) error
+}
+
+type AlertRepository interface {
+	ReadAlerts() ([]models.Alert, error)
+	WriteAlerts([]models.Alert) error
+}
@@ -0,0 +1,23 @@
+package dal
+
+import (
+	"hot-coffee1/models"
+
+	repositories "hot-coffee1/internal/dal/utils"
+)
+
+type voucherRepo struct{}
+
+func NewVoucherRepository() repositories.VoucherRepository {
+	return &voucherRepo{}
+}
+
+func (repo *voucherRepo) ReadVouchers() ([]models.Voucher, error) {
+	var vouchers []models.Voucher
+	err := readJSONFile("vouchers.json", &vouchers)
+	return vouchers, err
+}
+
+func (repo *voucherRepo) WriteVouchers(vouchers []models.Voucher) error {
+	return writeJSONFile("vouchers.json", vouchers)
+}
@@ -0,0 +1,33 @@
+package dal
+
+import (
+	"hot-coffee1/models"
+
+	repositories "hot-coffee1/internal/dal/utils"
+)
+
+type webhookRepo struct{}
+
+func NewWebhookRepository() repositories.WebhookRepository {
+	return &webhookRepo{}
+}
+
+func (repo *webhookRepo) ReadWebhooks() ([]models.Webhook, error) {
+	var webhooks []models.Webhook
+	err := readJSONFile("webhooks.json", &webhooks)
+	return webhooks, err
+}
+
+func (repo *webhookRepo) WriteWebhooks(webhooks []models.Webhook) error {
+	return writeJSONFile("webhooks.json", webhooks)
+}
+
+func (repo *webhookRepo) ReadDeliveries() ([]models.WebhookDelivery, error) {
+	var deliveries []models.WebhookDelivery
+	err := readJSONFile("webhook_deliveries.json", &deliveries)
+	return deliveries, err
+}
+
+func (repo *webhookRepo) WriteDeliveries(deliveries []models.WebhookDelivery) error {
+	return writeJSONFile("webhook_deliveries.json", deliveries)
+}
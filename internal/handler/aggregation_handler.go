@@ -7,17 +7,14 @@ import (
 )
 
 func AggregationEndpoints(mux *http.ServeMux) {
-	mux.HandleFunc("GET /reports/total-sales", GetTotalSalesHandler)
-	mux.HandleFunc("GET /reports/total-sales/", GetTotalSalesHandler)
-
-	mux.HandleFunc("GET /reports/popular-items", GetPopularItemsHandler)
-	mux.HandleFunc("GET /reports/popular-items/", GetPopularItemsHandler)
+	handle(mux, http.MethodGet, "/reports/total-sales", GetTotalSalesHandler, service.PermReportsRead)
+	handle(mux, http.MethodGet, "/reports/popular-items", GetPopularItemsHandler, service.PermReportsRead)
 }
 
 func GetTotalSalesHandler(w http.ResponseWriter, r *http.Request) {
 	totalSales, err := service.GetTotalSales()
 	if err != nil {
-		ErrorResponse(w, err.Error(), http.StatusInternalServerError)
+		WriteError(w, err)
 		return
 	}
 
@@ -38,7 +35,7 @@ func GetTotalSalesHandler(w http.ResponseWriter, r *http.Request) {
 func GetPopularItemsHandler(w http.ResponseWriter, r *http.Request) {
 	popularItems, err := service.GetPopularItems()
 	if err != nil {
-		ErrorResponse(w, err.Error(), http.StatusInternalServerError)
+		WriteError(w, err)
 		return
 	}
 
@@ -0,0 +1,35 @@
+package handler
+
+import (
+	"hot-coffee1/internal/service"
+	"log/slog"
+	"net/http"
+)
+
+var AlertService = service.NewAlertService()
+
+func AlertEndpoints(mux *http.ServeMux) {
+	handleV1(mux, http.MethodGet, "/alerts", GetAlertsHandler, service.PermInventoryRead)
+	handleV1(mux, http.MethodPost, "/alerts/{id}/acknowledge", PostAcknowledgeAlertHandler, service.PermInventoryAdmin)
+}
+
+func GetAlertsHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	alerts, err := AlertService.GetAlerts(query.Get("status"), query.Get("ingredient_id"))
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, alerts)
+}
+
+func PostAcknowledgeAlertHandler(w http.ResponseWriter, r *http.Request) {
+	alert, err := AlertService.AcknowledgeAlert(r.Context(), r.PathValue("id"))
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, alert)
+	slog.Info("Acknowledged alert", "ID", alert.ID, "ingredient", alert.IngredientID)
+}
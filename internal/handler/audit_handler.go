@@ -0,0 +1,39 @@
+package handler
+
+import (
+	"hot-coffee1/internal/service"
+	"net/http"
+)
+
+var AuditService = service.NewAuditService()
+
+func AuditEndpoints(mux *http.ServeMux) {
+	handleV1(mux, http.MethodGet, "/audit", GetAuditLogHandler, service.PermAuditRead)
+	handleV1(mux, http.MethodGet, "/audit/verify", GetAuditVerifyHandler, service.PermAuditRead)
+}
+
+func GetAuditLogHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	entries, err := AuditService.GetAuditLog(service.AuditFilter{
+		Entity:   query.Get("entity"),
+		EntityID: query.Get("id"),
+		Actor:    query.Get("actor"),
+		Action:   query.Get("action"),
+		From:     query.Get("from"),
+		To:       query.Get("to"),
+	})
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, entries)
+}
+
+func GetAuditVerifyHandler(w http.ResponseWriter, r *http.Request) {
+	result, err := AuditService.VerifyAuditLog()
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, result)
+}
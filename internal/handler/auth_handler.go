@@ -0,0 +1,333 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"hot-coffee1/internal/service"
+	"hot-coffee1/models"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+var AuthService = service.NewAuthService()
+
+type LoginRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+}
+
+type UserRequest struct {
+	Username string `json:"username"`
+	Password string `json:"password"`
+	Role     string `json:"role"`
+}
+
+type UserResponse struct {
+	Username  string `json:"username"`
+	Role      string `json:"role"`
+	CreatedAt string `json:"created_at"`
+}
+
+type APIKeyRequest struct {
+	Name string `json:"name"`
+	Role string `json:"role"`
+}
+
+type APIKeyResponse struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Role      string `json:"role"`
+	CreatedAt string `json:"created_at"`
+	Key       string `json:"key,omitempty"`
+}
+
+type PermissionsResponse struct {
+	Principal   service.Principal    `json:"principal"`
+	Permissions []service.Permission `json:"permissions"`
+}
+
+func AuthEndpoints(mux *http.ServeMux) {
+	handlePublic(mux, http.MethodPost, "/auth/login", PostLoginHandler)
+	handleV1(mux, http.MethodPost, "/auth/logout", PostLogoutHandler, "")
+
+	handleV1(mux, http.MethodGet, "/auth/users", GetAllUsersHandler, service.PermUsersAdmin)
+	handleV1(mux, http.MethodPost, "/auth/users", PostUserHandler, service.PermUsersAdmin)
+	handleV1(mux, http.MethodPut, "/auth/users/{username}/password", PutUserPasswordHandler, service.PermUsersAdmin)
+	handleV1(mux, http.MethodPut, "/auth/users/{username}/role", PutUserRoleHandler, service.PermUsersAdmin)
+	handleV1(mux, http.MethodDelete, "/auth/users/{username}", DeleteUserHandler, service.PermUsersAdmin)
+
+	handleV1(mux, http.MethodGet, "/auth/keys", GetAllAPIKeysHandler, service.PermUsersAdmin)
+	handleV1(mux, http.MethodPost, "/auth/keys", PostAPIKeyHandler, service.PermUsersAdmin)
+	handleV1(mux, http.MethodDelete, "/auth/keys/{id}", DeleteAPIKeyHandler, service.PermUsersAdmin)
+
+	handleV1(mux, http.MethodGet, "/me/permissions", GetMyPermissionsHandler, "")
+}
+
+// Authenticate wraps the whole server. Credentials, when present, must be
+// valid; routes not registered as public additionally require them.
+func Authenticate(mux *http.ServeMux, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		credentials := r.Header.Get("Authorization")
+		if credentials == "" && r.Header.Get("X-API-Key") != "" {
+			credentials = "ApiKey " + r.Header.Get("X-API-Key")
+		}
+
+		if credentials != "" {
+			principal, err := AuthService.Authenticate(credentials)
+			if err != nil {
+				unauthorized(w, err)
+				return
+			}
+			r = r.WithContext(service.WithPrincipal(r.Context(), principal))
+		}
+
+		if route, ok := routeFor(mux, r); ok && !route.Public {
+			if _, authenticated := service.PrincipalFrom(r.Context()); !authenticated {
+				unauthorized(w, service.ErrUnauthorized)
+				return
+			}
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// Authorize enforces the permission each route was registered with. It must
+// run inside Authenticate so the principal is already known.
+func Authorize(mux *http.ServeMux, next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		route, ok := routeFor(mux, r)
+		if !ok || route.Permission == "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		principal, _ := service.PrincipalFrom(r.Context())
+		if !principal.Can(route.Permission) {
+			WriteError(w, fmt.Errorf("%w: %s requires %s", service.ErrForbidden, route.Path, route.Permission))
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func unauthorized(w http.ResponseWriter, err error) {
+	w.Header().Set("WWW-Authenticate", `Bearer realm="hot-coffee", ApiKey realm="hot-coffee"`)
+	WriteError(w, err)
+}
+
+func decodeBody(r *http.Request, v any, form func()) error {
+	switch r.Header.Get("Content-Type") {
+	case "application/json":
+		if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+			return fmt.Errorf("%w: invalid JSON payload", service.ErrMalformedContent)
+		}
+	case "application/x-www-form-urlencoded":
+		if err := r.ParseForm(); err != nil {
+			return fmt.Errorf("%w: invalid form data", service.ErrMalformedContent)
+		}
+		form()
+	default:
+		return ErrUnsupportedContentType
+	}
+	return nil
+}
+
+// formList splits a comma-separated form value, dropping empty entries.
+func formList(value string) []string {
+	var list []string
+	for _, entry := range strings.Split(value, ",") {
+		if entry = strings.TrimSpace(entry); entry != "" {
+			list = append(list, entry)
+		}
+	}
+	return list
+}
+
+func PostLoginHandler(w http.ResponseWriter, r *http.Request) {
+	var req LoginRequest
+	if err := decodeBody(r, &req, func() {
+		req = LoginRequest{Username: r.FormValue("username"), Password: r.FormValue("password")}
+	}); err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	session, err := AuthService.Login(req.Username, req.Password)
+	if err != nil {
+		slog.Warn("Failed login", "username", req.Username)
+		WriteError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, session)
+	slog.Info("Logged in", "username", req.Username)
+}
+
+func PostLogoutHandler(w http.ResponseWriter, r *http.Request) {
+	scheme, token, _ := strings.Cut(r.Header.Get("Authorization"), " ")
+	if !strings.EqualFold(scheme, "bearer") {
+		ErrorResponse(w, "only session tokens can be logged out", http.StatusBadRequest)
+		return
+	}
+
+	if err := AuthService.Logout(strings.TrimSpace(token)); err != nil {
+		WriteError(w, err)
+		return
+	}
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func GetAllUsersHandler(w http.ResponseWriter, r *http.Request) {
+	users, err := AuthService.GetAllUsers()
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	response := make([]UserResponse, 0, len(users))
+	for _, user := range users {
+		response = append(response, userToResponse(user))
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+func PostUserHandler(w http.ResponseWriter, r *http.Request) {
+	var req UserRequest
+	if err := decodeBody(r, &req, func() {
+		req = UserRequest{Username: r.FormValue("username"), Password: r.FormValue("password"), Role: r.FormValue("role")}
+	}); err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	if err := AuthService.AddNewUser(r.Context(), req.Username, req.Password, req.Role); err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusCreated)
+	if _, err := w.Write([]byte("User added successfully")); err != nil {
+		ErrorResponse(w, "Failed to write response", http.StatusInternalServerError)
+		return
+	}
+	slog.Info("Added user", "username", req.Username)
+}
+
+func PutUserPasswordHandler(w http.ResponseWriter, r *http.Request) {
+	var req UserRequest
+	if err := decodeBody(r, &req, func() {
+		req = UserRequest{Password: r.FormValue("password")}
+	}); err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	username := r.PathValue("username")
+	if err := AuthService.ChangePassword(r.Context(), username, req.Password); err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write([]byte("Password changed successfully")); err != nil {
+		ErrorResponse(w, "Failed to write response", http.StatusInternalServerError)
+		return
+	}
+	slog.Info("Changed password", "username", username)
+}
+
+func PutUserRoleHandler(w http.ResponseWriter, r *http.Request) {
+	var req UserRequest
+	if err := decodeBody(r, &req, func() {
+		req = UserRequest{Role: r.FormValue("role")}
+	}); err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	username := r.PathValue("username")
+	if err := AuthService.ChangeRole(r.Context(), username, req.Role); err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write([]byte("Role changed successfully")); err != nil {
+		ErrorResponse(w, "Failed to write response", http.StatusInternalServerError)
+		return
+	}
+	slog.Info("Changed role", "username", username, "role", req.Role)
+}
+
+func DeleteUserHandler(w http.ResponseWriter, r *http.Request) {
+	username := r.PathValue("username")
+	if err := AuthService.DeleteUser(r.Context(), username); err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	slog.Info("Deleted user", "username", username)
+}
+
+func GetAllAPIKeysHandler(w http.ResponseWriter, r *http.Request) {
+	keys, err := AuthService.GetAllAPIKeys()
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	response := make([]APIKeyResponse, 0, len(keys))
+	for _, key := range keys {
+		response = append(response, apiKeyToResponse(key, ""))
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+func PostAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	var req APIKeyRequest
+	if err := decodeBody(r, &req, func() {
+		req = APIKeyRequest{Name: r.FormValue("name"), Role: r.FormValue("role")}
+	}); err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	plaintext, key, err := AuthService.CreateAPIKey(r.Context(), req.Name, req.Role)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, apiKeyToResponse(key, plaintext))
+	slog.Info("Created API key", "ID", key.ID, "name", key.Name)
+}
+
+func DeleteAPIKeyHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if err := AuthService.RevokeAPIKey(r.Context(), id); err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	slog.Info("Revoked API key", "ID", id)
+}
+
+func GetMyPermissionsHandler(w http.ResponseWriter, r *http.Request) {
+	principal, _ := service.PrincipalFrom(r.Context())
+	writeJSON(w, http.StatusOK, PermissionsResponse{
+		Principal:   principal,
+		Permissions: service.PermissionsOf(principal.Role),
+	})
+}
+
+func userToResponse(user models.User) UserResponse {
+	return UserResponse{Username: user.Username, Role: user.Role, CreatedAt: user.CreatedAt}
+}
+
+func apiKeyToResponse(key models.APIKey, plaintext string) APIKeyResponse {
+	return APIKeyResponse{ID: key.ID, Name: key.Name, Role: key.Role, CreatedAt: key.CreatedAt, Key: plaintext}
+}
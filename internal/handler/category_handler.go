@@ -0,0 +1,106 @@
+package handler
+
+import (
+	"hot-coffee1/internal/service"
+	"hot-coffee1/models"
+	"log/slog"
+	"net/http"
+	"strconv"
+)
+
+var CategoryService = service.NewCategoryService()
+
+func CategoryEndpoints(mux *http.ServeMux) {
+	handleV1(mux, http.MethodPost, "/categories", PostCategoryHandler, service.PermMenuAdmin)
+	handleV1(mux, http.MethodGet, "/categories", GetAllCategoriesHandler, service.PermMenuRead)
+	handleV1(mux, http.MethodGet, "/categories/{id}", GetCategoryByIDHandler, service.PermMenuRead)
+	handleV1(mux, http.MethodPut, "/categories/{id}", PutCategoryHandler, service.PermMenuAdmin)
+	handleV1(mux, http.MethodDelete, "/categories/{id}", DeleteCategoryHandler, service.PermMenuAdmin)
+	handleV1(mux, http.MethodGet, "/menu/tree", GetMenuTreeHandler, service.PermMenuRead)
+}
+
+func parseCategory(r *http.Request) (models.Category, error) {
+	var category models.Category
+	err := decodeBody(r, &category, func() {
+		category = models.Category{ID: r.FormValue("category_id"), Name: r.FormValue("name"), ParentID: r.FormValue("parent_id")}
+		category.SortOrder, _ = strconv.Atoi(r.FormValue("sort_order"))
+	})
+	return category, err
+}
+
+func PostCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	category, err := parseCategory(r)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	if err = CategoryService.AddNewCategory(r.Context(), category); err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, category)
+	slog.Info("Added category", "ID", category.ID)
+}
+
+func GetAllCategoriesHandler(w http.ResponseWriter, r *http.Request) {
+	categories, err := CategoryService.GetAllCategories()
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, categories)
+}
+
+func GetCategoryByIDHandler(w http.ResponseWriter, r *http.Request) {
+	category, err := CategoryService.GetCategoryByID(r.PathValue("id"))
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, category)
+}
+
+func PutCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	category, err := parseCategory(r)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	if id := r.PathValue("id"); id != category.ID {
+		v := &service.ValidationError{}
+		v.Add(service.Pointer("category_id"), "category ID does not match id")
+		WriteError(w, v)
+		return
+	}
+
+	if err = CategoryService.ModifyCategory(r.Context(), category); err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, category)
+	slog.Info("Updated category", "ID", category.ID)
+}
+
+func DeleteCategoryHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if err := CategoryService.DeleteCategory(r.Context(), id); err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	slog.Info("Deleted category", "ID", id)
+}
+
+func GetMenuTreeHandler(w http.ResponseWriter, r *http.Request) {
+	tree, err := CategoryService.GetMenuTree()
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, tree)
+}
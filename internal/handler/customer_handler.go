@@ -0,0 +1,113 @@
+package handler
+
+import (
+	"hot-coffee1/internal/service"
+	"hot-coffee1/models"
+	"log/slog"
+	"net/http"
+)
+
+var CustomerService = service.NewCustomerService()
+
+func CustomerEndpoints(mux *http.ServeMux) {
+	handleV1(mux, http.MethodPost, "/customers", PostCustomerHandler, service.PermOrdersWrite)
+	handleV1(mux, http.MethodGet, "/customers", GetAllCustomersHandler, service.PermOrdersRead)
+	handleV1(mux, http.MethodGet, "/customers/{id}", GetCustomerByIDHandler, service.PermOrdersRead)
+	handleV1(mux, http.MethodPut, "/customers/{id}", PutCustomerHandler, service.PermOrdersWrite)
+	handleV1(mux, http.MethodDelete, "/customers/{id}", DeleteCustomerHandler, service.PermOrdersDelete)
+	handleV1(mux, http.MethodGet, "/customers/{id}/orders", GetCustomerOrdersHandler, service.PermOrdersRead)
+	handleV1(mux, http.MethodPost, "/customers/{id}/reorder", PostReorderHandler, service.PermOrdersWrite)
+}
+
+func PostCustomerHandler(w http.ResponseWriter, r *http.Request) {
+	var customer models.Customer
+	if err := decodeJSONBody(r, &customer); err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	customer, err := CustomerService.AddNewCustomer(r.Context(), customer)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, customer)
+	slog.Info("Added customer", "ID", customer.ID)
+}
+
+func GetAllCustomersHandler(w http.ResponseWriter, r *http.Request) {
+	customers, err := CustomerService.GetAllCustomers(r.URL.Query().Get("q"))
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, customers)
+}
+
+func GetCustomerByIDHandler(w http.ResponseWriter, r *http.Request) {
+	customer, err := CustomerService.GetCustomerByID(r.PathValue("id"))
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, customer)
+}
+
+func PutCustomerHandler(w http.ResponseWriter, r *http.Request) {
+	var customer models.Customer
+	if err := decodeJSONBody(r, &customer); err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	if id := r.PathValue("id"); customer.ID == "" {
+		customer.ID = id
+	} else if customer.ID != id {
+		v := &service.ValidationError{}
+		v.Add(service.Pointer("customer_id"), "customer ID does not match id")
+		WriteError(w, v)
+		return
+	}
+
+	customer, err := CustomerService.ModifyCustomer(r.Context(), customer)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, customer)
+	slog.Info("Updated customer", "ID", customer.ID)
+}
+
+func DeleteCustomerHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if err := CustomerService.DeleteCustomer(r.Context(), id); err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	slog.Info("Deleted customer", "ID", id)
+}
+
+func GetCustomerOrdersHandler(w http.ResponseWriter, r *http.Request) {
+	orders, err := CustomerService.GetCustomerOrders(r.PathValue("id"))
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, orders)
+}
+
+func PostReorderHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	order, err := CustomerService.ReorderLast(r.Context(), id, r.URL.Query().Get("order_id"))
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, order)
+	slog.Info("Reordered", "customer", id, "ID", order.ID)
+}
@@ -3,27 +3,126 @@ package handler
 import (
 	"encoding/json"
 	"errors"
+	"hot-coffee1/internal/service"
 	"log/slog"
 	"net/http"
+	"strings"
 )
 
 var ErrUnsupportedContentType = errors.New("unsupported content type")
 
+// ErrorJson is the envelope every failed request returns. Error keeps the
+// human-readable message for older clients; Code is stable and meant to be
+// switched on.
 type ErrorJson struct {
-	Error string `json:"error"`
+	Error      string                   `json:"error"`
+	Code       string                   `json:"code"`
+	Status     int                      `json:"status"`
+	Violations []service.FieldViolation `json:"violations,omitempty"`
+}
+
+type errorMapping struct {
+	target error
+	status int
+	code   string
+}
+
+// Order matters: the first matching sentinel wins, so storage failures are
+// checked before the business errors they may be joined with.
+var errorMappings = []errorMapping{
+	{service.ErrInventoryNotRead, http.StatusInternalServerError, "storage_unavailable"},
+	{service.ErrMenuNotRead, http.StatusInternalServerError, "storage_unavailable"},
+	{service.ErrOrderNotRead, http.StatusInternalServerError, "storage_unavailable"},
+	{service.ErrAuthNotRead, http.StatusInternalServerError, "storage_unavailable"},
+	{service.ErrAuditNotRead, http.StatusInternalServerError, "storage_unavailable"},
+	{service.ErrWebhookNotRead, http.StatusInternalServerError, "storage_unavailable"},
+	{service.ErrAlertNotRead, http.StatusInternalServerError, "storage_unavailable"},
+	{service.ErrCategoryNotRead, http.StatusInternalServerError, "storage_unavailable"},
+	{service.ErrPricingRuleNotRead, http.StatusInternalServerError, "storage_unavailable"},
+	{service.ErrPromoCodeNotRead, http.StatusInternalServerError, "storage_unavailable"},
+	{service.ErrTaxNotRead, http.StatusInternalServerError, "storage_unavailable"},
+	{service.ErrServiceChargeNotRead, http.StatusInternalServerError, "storage_unavailable"},
+	{service.ErrCustomerNotRead, http.StatusInternalServerError, "storage_unavailable"},
+	{service.ErrLoyaltyNotRead, http.StatusInternalServerError, "storage_unavailable"},
+	{service.ErrGiftCardNotRead, http.StatusInternalServerError, "storage_unavailable"},
+	{service.ErrVoucherNotRead, http.StatusInternalServerError, "storage_unavailable"},
+	{service.ErrPickupScheduleNotRead, http.StatusInternalServerError, "storage_unavailable"},
+	{service.ErrUnauthorized, http.StatusUnauthorized, "unauthorized"},
+	{service.ErrInvalidCredentials, http.StatusUnauthorized, "invalid_credentials"},
+	{service.ErrForbidden, http.StatusForbidden, "forbidden"},
+	{service.ErrConflict, http.StatusConflict, "conflict"},
+	{service.ErrNotFound, http.StatusNotFound, "not_found"},
+	{service.ErrNotExists, http.StatusNotFound, "not_found"},
+	{service.ErrIDNotExist, http.StatusNotFound, "not_found"},
+	{service.ErrNotFoundID, http.StatusNotFound, "not_found"},
+	{service.ErrOrderClosed, http.StatusConflict, "order_closed"},
+	{service.ErrInsufficientStock, http.StatusConflict, "insufficient_stock"},
+	{service.ErrItemUnavailable, http.StatusConflict, "item_unavailable"},
+	{service.ErrPromoNotApplicable, http.StatusConflict, "promo_not_applicable"},
+	{service.ErrOrderNotPaid, http.StatusConflict, "order_not_paid"},
+	{service.ErrPaymentDeclined, http.StatusPaymentRequired, "payment_declined"},
+	{service.ErrLoyaltyNotApplicable, http.StatusConflict, "loyalty_not_applicable"},
+	{service.ErrPickupUnavailable, http.StatusConflict, "pickup_unavailable"},
+	{service.ErrNothingToModify, http.StatusBadRequest, "nothing_to_modify"},
+	{service.ErrValidation, http.StatusBadRequest, "validation_failed"},
+	{service.ErrMalformedContent, http.StatusBadRequest, "malformed_content"},
+	{service.ErrZeroLengthID, http.StatusBadRequest, "validation_failed"},
+	{ErrUnsupportedContentType, http.StatusUnsupportedMediaType, "unsupported_content_type"},
+	{service.ErrUnsupportedContentType, http.StatusUnsupportedMediaType, "unsupported_content_type"},
+}
+
+// WriteError maps a service error onto its HTTP status and stable code.
+// Errors without a known sentinel are failures of the server, not of the
+// request: they answer 500 without their message, which is only logged.
+func WriteError(w http.ResponseWriter, err error) {
+	body := errorBody(err)
+	if body.Code == "internal_error" {
+		slog.Error("Unexpected error", "error", err)
+	}
+	writeErrorJson(w, body)
+}
+
+func errorBody(err error) ErrorJson {
+	body := ErrorJson{Error: "internal server error", Code: "internal_error", Status: http.StatusInternalServerError}
+	for _, m := range errorMappings {
+		if errors.Is(err, m.target) {
+			body.Error, body.Status, body.Code = err.Error(), m.status, m.code
+			break
+		}
+	}
+
+	var validationErr *service.ValidationError
+	if errors.As(err, &validationErr) {
+		body.Violations = validationErr.Violations
+	}
+	return body
 }
 
 func ErrorResponse(w http.ResponseWriter, msg string, statusCode int) {
-	w.Header().Set("Content-Type", "application/json")
-	w.WriteHeader(statusCode)
+	writeErrorJson(w, ErrorJson{Error: msg, Code: codeForStatus(statusCode), Status: statusCode})
+}
 
-	errorResponse := ErrorJson{Error: msg}
-	jsonResponse, err := json.Marshal(errorResponse)
+func writeErrorJson(w http.ResponseWriter, body ErrorJson) {
+	jsonResponse, err := json.Marshal(body)
 	if err != nil {
 		http.Error(w, "Failed to generate error response", http.StatusInternalServerError)
 		return
 	}
 
-	slog.Error(msg)
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(body.Status)
+
+	slog.Error(body.Error, "code", body.Code, "status", body.Status)
 	w.Write(jsonResponse)
 }
+
+func codeForStatus(statusCode int) string {
+	if statusCode == http.StatusInternalServerError {
+		return "internal_error"
+	}
+	text := http.StatusText(statusCode)
+	if text == "" {
+		return "error"
+	}
+	return strings.ReplaceAll(strings.ToLower(text), " ", "_")
+}
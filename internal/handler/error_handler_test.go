@@ -0,0 +1,45 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"hot-coffee1/internal/service"
+	"net/http"
+	"testing"
+)
+
+func TestErrorBody(t *testing.T) {
+	validation := &service.ValidationError{}
+	validation.Add(service.Pointer("name"), "name cannot be empty")
+
+	tests := []struct {
+		name   string
+		err    error
+		status int
+		code   string
+	}{
+		{"unknown error", errors.New("open data/orders.json: permission denied"), http.StatusInternalServerError, "internal_error"},
+		{"not found", fmt.Errorf("order with ID order1 %w", service.ErrNotFound), http.StatusNotFound, "not_found"},
+		{"validation", validation, http.StatusBadRequest, "validation_failed"},
+		{"storage before business errors", errors.Join(service.ErrOrderNotRead, service.ErrNotFound), http.StatusInternalServerError, "storage_unavailable"},
+		{"payment declined", fmt.Errorf("%w: card decline", service.ErrPaymentDeclined), http.StatusPaymentRequired, "payment_declined"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			body := errorBody(tt.err)
+			if body.Status != tt.status || body.Code != tt.code {
+				t.Errorf("got %d %s, want %d %s", body.Status, body.Code, tt.status, tt.code)
+			}
+		})
+	}
+}
+
+func TestErrorBodyHidesUnexpectedErrors(t *testing.T) {
+	body := errorBody(errors.New("open data/orders.json: permission denied"))
+	if body.Error != "internal server error" {
+		t.Errorf("error = %q, want the details kept out of the response", body.Error)
+	}
+	if body := errorBody(&service.ValidationError{Violations: []service.FieldViolation{{Field: "/name", Message: "name cannot be empty"}}}); len(body.Violations) != 1 {
+		t.Errorf("violations = %+v, want the one violation", body.Violations)
+	}
+}
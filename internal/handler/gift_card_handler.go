@@ -0,0 +1,83 @@
+package handler
+
+import (
+	"hot-coffee1/internal/service"
+	"hot-coffee1/models"
+	"log/slog"
+	"net/http"
+)
+
+var GiftCardService = service.NewGiftCardService()
+
+type GiftCardReloadRequest struct {
+	Amount models.Money `json:"amount"`
+}
+
+func GiftCardEndpoints(mux *http.ServeMux) {
+	handleV1(mux, http.MethodPost, "/gift-cards", PostGiftCardHandler, service.PermGiftCardsAdmin)
+	handleV1(mux, http.MethodGet, "/gift-cards", GetAllGiftCardsHandler, service.PermOrdersRead)
+	handleV1(mux, http.MethodGet, "/gift-cards/{code}", GetGiftCardHandler, service.PermOrdersRead)
+	handleV1(mux, http.MethodPost, "/gift-cards/{code}/reload", PostGiftCardReloadHandler, service.PermGiftCardsAdmin)
+	handleV1(mux, http.MethodGet, "/gift-cards/{code}/transactions", GetGiftCardTransactionsHandler, service.PermOrdersRead)
+}
+
+func PostGiftCardHandler(w http.ResponseWriter, r *http.Request) {
+	var card models.GiftCard
+	if err := decodeJSONBody(r, &card); err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	card, err := GiftCardService.IssueGiftCard(r.Context(), card)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, card)
+	slog.Info("Issued gift card", "code", card.Code, "balance", card.Balance)
+}
+
+func GetAllGiftCardsHandler(w http.ResponseWriter, r *http.Request) {
+	cards, err := GiftCardService.GetAllGiftCards()
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, cards)
+}
+
+func GetGiftCardHandler(w http.ResponseWriter, r *http.Request) {
+	card, err := GiftCardService.GetGiftCard(r.PathValue("code"))
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, card)
+}
+
+func PostGiftCardReloadHandler(w http.ResponseWriter, r *http.Request) {
+	var req GiftCardReloadRequest
+	if err := decodeJSONBody(r, &req); err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	card, err := GiftCardService.ReloadGiftCard(r.Context(), r.PathValue("code"), req.Amount)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, card)
+	slog.Info("Reloaded gift card", "code", card.Code, "amount", req.Amount, "balance", card.Balance)
+}
+
+func GetGiftCardTransactionsHandler(w http.ResponseWriter, r *http.Request) {
+	transactions, err := GiftCardService.GetGiftCardTransactions(r.PathValue("code"))
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, transactions)
+}
@@ -2,7 +2,6 @@ package handler
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
 	"hot-coffee1/internal/service"
 	"hot-coffee1/models"
@@ -14,26 +13,17 @@ import (
 var InventoryService = service.NewInventoryService()
 
 func InventoryEndpoints(mux *http.ServeMux) {
-	mux.HandleFunc("POST /inventory", PostInventoryHandler)
-	mux.HandleFunc("POST /inventory/", PostInventoryHandler)
-
-	mux.HandleFunc("GET /inventory", GetAllInventoryHandler)
-	mux.HandleFunc("GET /inventory/", GetAllInventoryHandler)
-
-	mux.HandleFunc("GET /inventory/{id}", GetInventoryByIDHandler)
-	mux.HandleFunc("GET /inventory/{id}/", GetInventoryByIDHandler)
-
-	mux.HandleFunc("PUT /inventory/{id}", PutInventoryHandler)
-	mux.HandleFunc("PUT /inventory/{id}/", PutInventoryHandler)
-
-	mux.HandleFunc("DELETE /inventory/{id}", DeleteInventoryByIDHandler)
-	mux.HandleFunc("DELETE /inventory/{id}/", DeleteInventoryByIDHandler)
+	handle(mux, http.MethodPost, "/inventory", PostInventoryHandler, service.PermInventoryAdmin)
+	handle(mux, http.MethodGet, "/inventory", GetAllInventoryHandler, service.PermInventoryRead)
+	handle(mux, http.MethodGet, "/inventory/{id}", GetInventoryByIDHandler, service.PermInventoryRead)
+	handle(mux, http.MethodPut, "/inventory/{id}", PutInventoryHandler, service.PermInventoryAdmin)
+	handle(mux, http.MethodDelete, "/inventory/{id}", DeleteInventoryByIDHandler, service.PermInventoryAdmin)
 }
 
 func GetAllInventoryHandler(w http.ResponseWriter, r *http.Request) {
 	inventory, err := InventoryService.GetAllInventory()
 	if err != nil {
-		ErrorResponse(w, "Could not retrieve inventory data", http.StatusInternalServerError)
+		WriteError(w, err)
 		return
 	}
 
@@ -56,11 +46,8 @@ func GetAllInventoryHandler(w http.ResponseWriter, r *http.Request) {
 func GetInventoryByIDHandler(w http.ResponseWriter, r *http.Request) {
 	itemId := r.PathValue("id")
 	item, err := InventoryService.GetInventoryByID(itemId)
-	if errors.Is(err, service.ErrInventoryNotRead) {
-		ErrorResponse(w, err.Error(), http.StatusInternalServerError)
-		return
-	} else if err != nil {
-		ErrorResponse(w, err.Error(), http.StatusNotFound)
+	if err != nil {
+		WriteError(w, err)
 		return
 	}
 
@@ -83,12 +70,8 @@ func GetInventoryByIDHandler(w http.ResponseWriter, r *http.Request) {
 
 func DeleteInventoryByIDHandler(w http.ResponseWriter, r *http.Request) {
 	itemId := r.PathValue("id")
-	err := InventoryService.DeleteInventoryItem(itemId)
-	if errors.Is(err, service.ErrInventoryNotRead) {
-		ErrorResponse(w, err.Error(), http.StatusNotFound)
-		return
-	} else if err != nil {
-		ErrorResponse(w, err.Error(), http.StatusNotFound)
+	if err := InventoryService.DeleteInventoryItem(r.Context(), itemId); err != nil {
+		WriteError(w, err)
 		return
 	}
 
@@ -102,25 +85,38 @@ func parseInventoryItem(r *http.Request) (models.InventoryItem, error) {
 
 	if contentType == "application/json" {
 		if err := json.NewDecoder(r.Body).Decode(&item); err != nil {
-			return item, fmt.Errorf("invalid JSON payload")
+			return item, fmt.Errorf("%w: invalid JSON payload", service.ErrMalformedContent)
 		}
 	} else if contentType == "application/x-www-form-urlencoded" {
 		if err := r.ParseForm(); err != nil {
-			return item, fmt.Errorf("invalid form data")
+			return item, fmt.Errorf("%w: invalid form data", service.ErrMalformedContent)
 		}
 
 		quantity, err := strconv.ParseFloat(r.FormValue("quantity"), 64)
 		if err != nil {
-			return item, fmt.Errorf("quantity is not a float")
+			v := &service.ValidationError{}
+			v.Add(service.Pointer("quantity"), "quantity is not a float")
+			return item, v
+		}
+		var threshold float64
+		if value := r.FormValue("low_stock_threshold"); value != "" {
+			if threshold, err = strconv.ParseFloat(value, 64); err != nil {
+				v := &service.ValidationError{}
+				v.Add(service.Pointer("low_stock_threshold"), "low stock threshold is not a float")
+				return item, v
+			}
 		}
 		item = models.InventoryItem{
-			IngredientID: r.FormValue("ingredient_id"),
-			Name:         r.FormValue("name"),
-			Quantity:     quantity,
-			Unit:         r.FormValue("unit"),
+			IngredientID:      r.FormValue("ingredient_id"),
+			Name:              r.FormValue("name"),
+			Quantity:          quantity,
+			Unit:              r.FormValue("unit"),
+			LowStockThreshold: threshold,
+			Allergens:         formList(r.FormValue("allergens")),
+			Dietary:           formList(r.FormValue("dietary")),
 		}
 	} else {
-		return item, fmt.Errorf("unsupported content type")
+		return item, ErrUnsupportedContentType
 	}
 
 	return item, nil
@@ -128,18 +124,13 @@ func parseInventoryItem(r *http.Request) (models.InventoryItem, error) {
 
 func PostInventoryHandler(w http.ResponseWriter, r *http.Request) {
 	item, err := parseInventoryItem(r)
-	if errors.Is(err, ErrUnsupportedContentType) {
-		ErrorResponse(w, err.Error(), http.StatusUnsupportedMediaType)
-	} else if err != nil {
-		ErrorResponse(w, err.Error(), http.StatusBadRequest)
+	if err != nil {
+		WriteError(w, err)
 		return
 	}
 
-	if err = InventoryService.AddNewInventoryItem(item); errors.Is(err, service.ErrConflict) {
-		ErrorResponse(w, err.Error(), http.StatusConflict)
-		return
-	} else if err != nil {
-		ErrorResponse(w, err.Error(), http.StatusBadRequest)
+	if err = InventoryService.AddNewInventoryItem(r.Context(), item); err != nil {
+		WriteError(w, err)
 		return
 	}
 
@@ -155,26 +146,20 @@ func PostInventoryHandler(w http.ResponseWriter, r *http.Request) {
 func PutInventoryHandler(w http.ResponseWriter, r *http.Request) {
 	id := r.PathValue("id")
 	item, err := parseInventoryItem(r)
-	if errors.Is(err, ErrUnsupportedContentType) {
-		ErrorResponse(w, err.Error(), http.StatusUnsupportedMediaType)
-	} else if err != nil {
-		ErrorResponse(w, err.Error(), http.StatusBadRequest)
+	if err != nil {
+		WriteError(w, err)
 		return
 	}
 
 	if id != item.IngredientID {
-		ErrorResponse(w, "IngredientID does not match id", http.StatusBadRequest)
+		v := &service.ValidationError{}
+		v.Add(service.Pointer("ingredient_id"), "IngredientID does not match id")
+		WriteError(w, v)
 		return
 	}
 
-	if err = InventoryService.ModifyInventoryItem(item); errors.Is(err, service.ErrConflict) {
-		ErrorResponse(w, err.Error(), http.StatusConflict)
-		return
-	} else if errors.Is(err, service.ErrNothingToModify) {
-		ErrorResponse(w, err.Error(), http.StatusNoContent)
-		return
-	} else if err != nil {
-		ErrorResponse(w, err.Error(), http.StatusBadRequest)
+	if err = InventoryService.ModifyInventoryItem(r.Context(), item); err != nil {
+		WriteError(w, err)
 		return
 	}
 
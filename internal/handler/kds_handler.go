@@ -0,0 +1,242 @@
+package handler
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hot-coffee1/internal/service"
+	"hot-coffee1/internal/websocket"
+	"hot-coffee1/models"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+const kdsPingInterval = 30 * time.Second
+
+// KDSMessage is what a kitchen display sends. Ref is echoed back in the
+// ack or error answering it.
+type KDSMessage struct {
+	Type        string   `json:"type"`
+	Ref         string   `json:"ref,omitempty"`
+	OrderID     string   `json:"order_id,omitempty"`
+	ProductID   string   `json:"product_id,omitempty"`
+	Statuses    []string `json:"statuses,omitempty"`
+	LastEventID *int64   `json:"last_event_id,omitempty"`
+}
+
+// KDSEvent is what the server sends to a kitchen display.
+type KDSEvent struct {
+	Type      string         `json:"type"`
+	Ref       string         `json:"ref,omitempty"`
+	EventID   int64          `json:"event_id,omitempty"`
+	ProductID string         `json:"product_id,omitempty"`
+	Order     *models.Order  `json:"order,omitempty"`
+	Orders    []models.Order `json:"orders,omitempty"`
+	Error     *ErrorJson     `json:"error,omitempty"`
+}
+
+type kdsSession struct {
+	conn      *websocket.Conn
+	r         *http.Request
+	principal service.Principal
+	sub       *service.Subscription
+	statuses  []string
+}
+
+// GetKDSHandler upgrades to a WebSocket speaking the kitchen display
+// protocol: the display subscribes, receives a snapshot and then order
+// events, and reports preparation progress with item_started, item_done and
+// bump.
+func GetKDSHandler(w http.ResponseWriter, r *http.Request) {
+	conn, err := websocket.Upgrade(w, r)
+	if err != nil {
+		if errors.Is(err, websocket.ErrNotWebSocket) {
+			w.Header().Set("Upgrade", "websocket")
+			ErrorResponse(w, err.Error(), http.StatusUpgradeRequired)
+			return
+		}
+		slog.Error("WebSocket upgrade failed", "error", err)
+		return
+	}
+	defer conn.Close(websocket.CloseGoingAway, "")
+	principal, _ := service.PrincipalFrom(r.Context())
+	session := &kdsSession{conn: conn, r: r, principal: principal}
+	defer func() {
+		if session.sub != nil {
+			session.sub.Close()
+		}
+	}()
+	slog.Info("Kitchen display connected", "principal", principal.String())
+
+	// done отпускает читателя, когда основной цикл уже вышел
+	messages := make(chan []byte)
+	readErr := make(chan error, 1)
+	done := make(chan struct{})
+	defer close(done)
+	go func() {
+		for {
+			data, err := conn.ReadMessage()
+			if err != nil {
+				readErr <- err
+				return
+			}
+			select {
+			case messages <- data:
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	ping := time.NewTicker(kdsPingInterval)
+	defer ping.Stop()
+	for {
+		var events <-chan service.Event
+		if session.sub != nil {
+			events = session.sub.Events()
+		}
+
+		select {
+		case err := <-readErr:
+			slog.Info("Kitchen display disconnected", "principal", principal.String(), "reason", err)
+			return
+		case data := <-messages:
+			session.handleMessage(data)
+		case event, ok := <-events:
+			if !ok {
+				conn.Close(websocket.ClosePolicy, "fell behind, reconnect with last_event_id")
+				return
+			}
+			session.sendEvent(event)
+		case <-ping.C:
+			if err := conn.Ping(); err != nil {
+				conn.Close(websocket.CloseGoingAway, "")
+				return
+			}
+		}
+	}
+}
+
+func (s *kdsSession) handleMessage(data []byte) {
+	var msg KDSMessage
+	if err := json.Unmarshal(data, &msg); err != nil {
+		s.sendError("", fmt.Errorf("%w: invalid JSON message", service.ErrMalformedContent))
+		return
+	}
+
+	switch msg.Type {
+	case "subscribe":
+		s.subscribe(msg)
+	case "item_started", "item_done", "bump":
+		if !s.principal.Can(service.PermOrdersWrite) {
+			s.sendError(msg.Ref, fmt.Errorf("%w: %s requires %s", service.ErrForbidden, msg.Type, service.PermOrdersWrite))
+			return
+		}
+		var order models.Order
+		var err error
+		switch msg.Type {
+		case "item_started":
+			order, err = OrderService.StartOrderItem(s.r.Context(), msg.OrderID, msg.ProductID)
+		case "item_done":
+			order, err = OrderService.FinishOrderItem(s.r.Context(), msg.OrderID, msg.ProductID)
+		default:
+			order, err = OrderService.BumpOrder(s.r.Context(), msg.OrderID)
+		}
+		if err != nil {
+			s.sendError(msg.Ref, err)
+			return
+		}
+		s.send(KDSEvent{Type: "ack", Ref: msg.Ref, Order: &order})
+	default:
+		v := &service.ValidationError{}
+		v.Add(service.Pointer("type"), "unknown message type %q", msg.Type)
+		s.sendError(msg.Ref, v)
+	}
+}
+
+// subscribe replaces any earlier subscription. Without a resumable
+// last_event_id the display first gets a snapshot of the orders it shows.
+func (s *kdsSession) subscribe(msg KDSMessage) {
+	if s.sub != nil {
+		s.sub.Close()
+	}
+	s.statuses = nil
+	for _, status := range msg.Statuses {
+		s.statuses = append(s.statuses, strings.ToLower(status))
+	}
+	if len(s.statuses) == 0 {
+		s.statuses = []string{"open", "ready"}
+	}
+
+	afterID := service.Events.LastID()
+	if msg.LastEventID != nil {
+		afterID = *msg.LastEventID
+	}
+	sub, missed, complete := service.Events.Subscribe(afterID)
+	s.sub = sub
+
+	if msg.LastEventID == nil || !complete {
+		orders, err := OrderService.GetAllOrders()
+		if err != nil {
+			s.sendError(msg.Ref, err)
+			return
+		}
+		snapshot := KDSEvent{Type: "snapshot", Ref: msg.Ref, EventID: afterID, Orders: []models.Order{}}
+		for _, order := range orders {
+			if slices.Contains(s.statuses, strings.ToLower(order.Status)) {
+				snapshot.Orders = append(snapshot.Orders, order)
+			}
+		}
+		slices.SortFunc(snapshot.Orders, func(a, b models.Order) int { return strings.Compare(a.CreatedAt, b.CreatedAt) })
+		s.send(snapshot)
+		if !complete {
+			missed = nil
+		}
+	} else {
+		s.send(KDSEvent{Type: "ack", Ref: msg.Ref, EventID: afterID})
+	}
+	for _, event := range missed {
+		s.sendEvent(event)
+	}
+}
+
+func (s *kdsSession) sendEvent(event service.Event) {
+	if !service.MatchesType(event.Type, "order.*") {
+		return
+	}
+	order, ok := event.Data.(models.Order)
+	if !ok {
+		return
+	}
+	if (event.Type == service.EventOrderCreated || event.Type == service.EventOrderModified) &&
+		!slices.Contains(s.statuses, strings.ToLower(order.Status)) {
+		return
+	}
+
+	name := strings.TrimPrefix(event.Type, "order.")
+	if !strings.HasPrefix(name, "item_") {
+		name = "order_" + name
+	}
+	s.send(KDSEvent{Type: name, EventID: event.ID, ProductID: event.Item, Order: &order})
+}
+
+func (s *kdsSession) sendError(ref string, err error) {
+	body := errorBody(err)
+	s.send(KDSEvent{Type: "error", Ref: ref, Error: &body})
+}
+
+func (s *kdsSession) send(event KDSEvent) {
+	data, err := json.Marshal(event)
+	if err != nil {
+		slog.Error("Failed to encode kitchen display message", "error", err)
+		return
+	}
+	if err := s.conn.WriteMessage(data); err != nil {
+		// Закрытие будит читателя, и сессия завершается
+		slog.Error("Failed to write kitchen display message", "error", err)
+		s.conn.Close(websocket.CloseGoingAway, "")
+	}
+}
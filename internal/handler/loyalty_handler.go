@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"hot-coffee1/internal/service"
+	"hot-coffee1/models"
+	"log/slog"
+	"net/http"
+)
+
+var LoyaltyService = service.NewLoyaltyService()
+
+func LoyaltyEndpoints(mux *http.ServeMux) {
+	handleV1(mux, http.MethodGet, "/loyalty", GetLoyaltyProgramHandler, service.PermMenuRead)
+	handleV1(mux, http.MethodPut, "/loyalty", PutLoyaltyProgramHandler, service.PermMenuAdmin)
+	handleV1(mux, http.MethodGet, "/customers/{id}/loyalty", GetLoyaltyAccountHandler, service.PermOrdersRead)
+}
+
+func GetLoyaltyProgramHandler(w http.ResponseWriter, r *http.Request) {
+	program, err := LoyaltyService.GetLoyaltyProgram()
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, program)
+}
+
+func PutLoyaltyProgramHandler(w http.ResponseWriter, r *http.Request) {
+	var program models.LoyaltyProgram
+	if err := decodeJSONBody(r, &program); err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	program, err := LoyaltyService.SetLoyaltyProgram(r.Context(), program)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, program)
+	slog.Info("Updated loyalty program", "mode", program.Mode)
+}
+
+func GetLoyaltyAccountHandler(w http.ResponseWriter, r *http.Request) {
+	account, err := LoyaltyService.GetLoyaltyAccount(r.PathValue("id"))
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, account)
+}
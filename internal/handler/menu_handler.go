@@ -2,7 +2,6 @@ package handler
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
 	"hot-coffee1/internal/service"
 	"hot-coffee1/models"
@@ -14,26 +13,22 @@ import (
 var MenuService = service.NewMenuService()
 
 func MenuEndpoints(mux *http.ServeMux) {
-	mux.HandleFunc("POST /menu", PostMenuHandler)
-	mux.HandleFunc("POST /menu/", PostMenuHandler)
-
-	mux.HandleFunc("GET /menu", GetAllMenuHandler)
-	mux.HandleFunc("GET /menu/", GetAllMenuHandler)
-
-	mux.HandleFunc("GET /menu/{id}", GetMenuByIDHandler)
-	mux.HandleFunc("GET /menu/{id}/", GetMenuByIDHandler)
-
-	mux.HandleFunc("PUT /menu/{id}", PutMenuHandler)
-	mux.HandleFunc("PUT /menu/{id}/", PutMenuHandler)
-
-	mux.HandleFunc("DELETE /menu/{id}", DeleteMenuByIDHandler)
-	mux.HandleFunc("DELETE /menu/{id}/", DeleteMenuByIDHandler)
+	handle(mux, http.MethodPost, "/menu", PostMenuHandler, service.PermMenuAdmin)
+	handle(mux, http.MethodGet, "/menu", GetAllMenuHandler, service.PermMenuRead)
+	handle(mux, http.MethodGet, "/menu/{id}", GetMenuByIDHandler, service.PermMenuRead)
+	handle(mux, http.MethodPut, "/menu/{id}", PutMenuHandler, service.PermMenuAdmin)
+	handle(mux, http.MethodDelete, "/menu/{id}", DeleteMenuByIDHandler, service.PermMenuAdmin)
+	handleV1(mux, http.MethodGet, "/menu/available", GetAvailableMenuHandler, service.PermMenuRead)
+	handleV1(mux, http.MethodPut, "/menu/{id}/86", PutEightySixHandler, service.PermOrdersWrite)
+	handleV1(mux, http.MethodDelete, "/menu/{id}/86", DeleteEightySixHandler, service.PermOrdersWrite)
+	handleV1(mux, http.MethodPost, "/menu/{id}/scheduled-prices", PostScheduledPriceHandler, service.PermMenuAdmin)
+	handleV1(mux, http.MethodDelete, "/menu/{id}/scheduled-prices/{change}", DeleteScheduledPriceHandler, service.PermMenuAdmin)
 }
 
 func GetAllMenuHandler(w http.ResponseWriter, r *http.Request) {
-	menu, err := MenuService.GetAllMenu()
+	menu, err := MenuService.GetMenuAvailability(menuFilter(r))
 	if err != nil {
-		ErrorResponse(w, "Could not retrieve menu data", http.StatusInternalServerError)
+		WriteError(w, err)
 		return
 	}
 
@@ -56,12 +51,9 @@ func GetAllMenuHandler(w http.ResponseWriter, r *http.Request) {
 
 func GetMenuByIDHandler(w http.ResponseWriter, r *http.Request) {
 	itemId := r.PathValue("id")
-	item, err := MenuService.GetMenuByID(itemId)
-	if errors.Is(err, service.ErrMenuNotRead) {
-		ErrorResponse(w, err.Error(), http.StatusInternalServerError)
-		return
-	} else if err != nil {
-		ErrorResponse(w, err.Error(), http.StatusNotFound)
+	item, err := MenuService.GetMenuItemAvailability(itemId)
+	if err != nil {
+		WriteError(w, err)
 		return
 	}
 
@@ -82,14 +74,86 @@ func GetMenuByIDHandler(w http.ResponseWriter, r *http.Request) {
 	slog.Info("Retrieved menu item", "ID", item.ID)
 }
 
+func GetAvailableMenuHandler(w http.ResponseWriter, r *http.Request) {
+	menu, err := MenuService.GetMenuAvailability(menuFilter(r))
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	available := []models.MenuItem{}
+	for _, item := range menu {
+		if item.Available != nil && *item.Available {
+			available = append(available, item)
+		}
+	}
+	writeJSON(w, http.StatusOK, available)
+}
+
+func menuFilter(r *http.Request) service.MenuFilter {
+	query := r.URL.Query()
+	return service.MenuFilter{
+		CategoryID:       query.Get("category"),
+		Tag:              query.Get("tag"),
+		ExcludeAllergens: formList(query.Get("exclude_allergens")),
+	}
+}
+
+func PutEightySixHandler(w http.ResponseWriter, r *http.Request) {
+	setEightySixed(w, r, true)
+}
+
+func DeleteEightySixHandler(w http.ResponseWriter, r *http.Request) {
+	setEightySixed(w, r, false)
+}
+
+func setEightySixed(w http.ResponseWriter, r *http.Request, eightySixed bool) {
+	item, err := MenuService.SetEightySixed(r.Context(), r.PathValue("id"), eightySixed)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, item)
+	slog.Info("Changed menu item availability", "ID", item.ID, "eighty_sixed", eightySixed)
+}
+
+func PostScheduledPriceHandler(w http.ResponseWriter, r *http.Request) {
+	var change models.PriceChange
+	if err := decodeBody(r, &change, func() {
+		change.EffectiveFrom = r.FormValue("effective_from")
+		change.Price, _ = models.ParseMoney(r.FormValue("price"))
+	}); err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	id := r.PathValue("id")
+	change, err := MenuService.SchedulePrice(r.Context(), id, change)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, change)
+	slog.Info("Scheduled price change", "ID", id, "price", change.Price, "effective_from", change.EffectiveFrom)
+}
+
+func DeleteScheduledPriceHandler(w http.ResponseWriter, r *http.Request) {
+	id, change := r.PathValue("id"), r.PathValue("change")
+	if err := MenuService.CancelScheduledPrice(r.Context(), id, change); err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	slog.Info("Cancelled scheduled price change", "ID", id, "change", change)
+}
+
 func DeleteMenuByIDHandler(w http.ResponseWriter, r *http.Request) {
 	itemId := r.PathValue("id")
-	err := MenuService.DeleteMenuItem(itemId)
-	if errors.Is(err, service.ErrMenuNotRead) {
-		ErrorResponse(w, err.Error(), http.StatusNotFound)
-		return
-	} else if err != nil {
-		ErrorResponse(w, err.Error(), http.StatusNotFound)
+	if err := MenuService.DeleteMenuItem(r.Context(), itemId); err != nil {
+		WriteError(w, err)
 		return
 	}
 
@@ -103,29 +167,43 @@ func parseMenuItem(r *http.Request) (models.MenuItem, error) {
 
 	if contentType == "application/json" {
 		if err := json.NewDecoder(r.Body).Decode(&item); err != nil {
-			return item, fmt.Errorf("invalid JSON payload")
+			return item, fmt.Errorf("%w: invalid JSON payload", service.ErrMalformedContent)
 		}
 	} else if contentType == "application/x-www-form-urlencoded" {
 		if err := r.ParseForm(); err != nil {
-			return item, fmt.Errorf("invalid form data")
+			return item, fmt.Errorf("%w: invalid form data", service.ErrMalformedContent)
 		}
-		price, err := strconv.ParseFloat(r.FormValue("price"), 64)
+		v := &service.ValidationError{}
+		price, err := models.ParseMoney(r.FormValue("price"))
 		if err != nil {
-			return item, fmt.Errorf("price is not a float")
+			v.Add(service.Pointer("price"), "price is not a number")
 		}
 
 		var ingredients []models.MenuItemIngredient
 		ingredientsJSON := r.FormValue("ingredients")
 		if err := json.Unmarshal([]byte(ingredientsJSON), &ingredients); err != nil {
-			return item, fmt.Errorf("error parsing ingredients: %v", err)
+			v.Add(service.Pointer("ingredients"), "error parsing ingredients: %v", err)
+		}
+		var displayOrder int
+		if value := r.FormValue("display_order"); value != "" {
+			if displayOrder, err = strconv.Atoi(value); err != nil {
+				v.Add(service.Pointer("display_order"), "display order is not an integer")
+			}
+		}
+		if err := v.Err(); err != nil {
+			return item, err
 		}
 
 		item = models.MenuItem{
-			ID:          r.FormValue("product_id"),
-			Name:        r.FormValue("name"),
-			Description: r.FormValue("description"),
-			Price:       price,
-			Ingredients: ingredients,
+			ID:           r.FormValue("product_id"),
+			Name:         r.FormValue("name"),
+			Description:  r.FormValue("description"),
+			Price:        price,
+			Ingredients:  ingredients,
+			CategoryID:   r.FormValue("category_id"),
+			Tags:         formList(r.FormValue("tags")),
+			Images:       formList(r.FormValue("images")),
+			DisplayOrder: displayOrder,
 		}
 	} else {
 		return item, ErrUnsupportedContentType
@@ -136,18 +214,13 @@ func parseMenuItem(r *http.Request) (models.MenuItem, error) {
 
 func PostMenuHandler(w http.ResponseWriter, r *http.Request) {
 	item, err := parseMenuItem(r)
-	if errors.Is(err, ErrUnsupportedContentType) {
-		ErrorResponse(w, err.Error(), http.StatusUnsupportedMediaType)
-	} else if err != nil {
-		ErrorResponse(w, err.Error(), http.StatusBadRequest)
+	if err != nil {
+		WriteError(w, err)
 		return
 	}
 
-	if err := MenuService.AddNewMenuItem(item); errors.Is(err, service.ErrConflict) {
-		ErrorResponse(w, err.Error(), http.StatusConflict)
-		return
-	} else if err != nil {
-		ErrorResponse(w, err.Error(), http.StatusBadRequest)
+	if err := MenuService.AddNewMenuItem(r.Context(), item); err != nil {
+		WriteError(w, err)
 		return
 	}
 
@@ -162,25 +235,22 @@ func PostMenuHandler(w http.ResponseWriter, r *http.Request) {
 
 func PutMenuHandler(w http.ResponseWriter, r *http.Request) {
 	item, err := parseMenuItem(r)
-	if errors.Is(err, ErrUnsupportedContentType) {
-		ErrorResponse(w, err.Error(), http.StatusUnsupportedMediaType)
-	} else if err != nil {
-		ErrorResponse(w, err.Error(), http.StatusBadRequest)
+	if err != nil {
+		WriteError(w, err)
 		return
 	}
 
 	id := r.PathValue("id")
 
 	if id != item.ID {
-		ErrorResponse(w, "product ID does not match id", http.StatusBadRequest)
+		v := &service.ValidationError{}
+		v.Add(service.Pointer("product_id"), "product ID does not match id")
+		WriteError(w, v)
 		return
 	}
 
-	if err := MenuService.ModifyMenuItem(item); errors.Is(err, service.ErrConflict) {
-		ErrorResponse(w, err.Error(), http.StatusConflict)
-		return
-	} else if err != nil {
-		ErrorResponse(w, err.Error(), http.StatusBadRequest)
+	if err := MenuService.ModifyMenuItem(r.Context(), item); err != nil {
+		WriteError(w, err)
 		return
 	}
 
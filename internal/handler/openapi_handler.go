@@ -0,0 +1,359 @@
+package handler
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"hot-coffee1/internal/openapi"
+	"hot-coffee1/internal/service"
+	"hot-coffee1/models"
+	"net/http"
+	"regexp"
+	"sort"
+	"strings"
+	"sync"
+)
+
+//go:embed assets/docs.html
+var docsPage []byte
+
+type apiOperation struct {
+	Method   string
+	Path     string
+	Tag      string
+	Summary  string
+	Query    []string
+	Request  any
+	Response any
+	Status   int
+}
+
+// apiOperations is the hand-maintained part of the specification, one table
+// per mount: v1 routes (documented under /api/v1 and as deprecated
+// unversioned aliases), v2 routes and unversioned documentation routes.
+// Every registered route must have an entry, see CheckOpenAPICoverage.
+var apiOperations = []apiOperation{
+	{http.MethodPost, "/inventory", "Inventory", "Add an inventory item", nil, models.InventoryItem{}, nil, http.StatusCreated},
+	{http.MethodGet, "/inventory", "Inventory", "List inventory items", nil, nil, []models.InventoryItem{}, http.StatusOK},
+	{http.MethodGet, "/inventory/{id}", "Inventory", "Get an inventory item", nil, nil, models.InventoryItem{}, http.StatusOK},
+	{http.MethodPut, "/inventory/{id}", "Inventory", "Replace an inventory item", nil, models.InventoryItem{}, nil, http.StatusOK},
+	{http.MethodDelete, "/inventory/{id}", "Inventory", "Delete an inventory item", nil, nil, nil, http.StatusNoContent},
+
+	{http.MethodPost, "/menu", "Menu", "Add a menu item", nil, models.MenuItem{}, nil, http.StatusCreated},
+	{http.MethodGet, "/menu", "Menu", "List menu items", []string{"category", "tag", "exclude_allergens"}, nil, []models.MenuItem{}, http.StatusOK},
+	{http.MethodGet, "/menu/{id}", "Menu", "Get a menu item", nil, nil, models.MenuItem{}, http.StatusOK},
+	{http.MethodPut, "/menu/{id}", "Menu", "Replace a menu item", nil, models.MenuItem{}, nil, http.StatusCreated},
+	{http.MethodDelete, "/menu/{id}", "Menu", "Delete a menu item", nil, nil, nil, http.StatusNoContent},
+
+	{http.MethodPost, "/orders", "Orders", "Create an order", nil, models.Order{}, nil, http.StatusCreated},
+	{http.MethodGet, "/orders", "Orders", "List orders", nil, nil, []models.Order{}, http.StatusOK},
+	{http.MethodGet, "/orders/{id}", "Orders", "Get an order", nil, nil, models.Order{}, http.StatusOK},
+	{http.MethodPut, "/orders/{id}", "Orders", "Modify an order", nil, models.Order{}, nil, http.StatusCreated},
+	{http.MethodDelete, "/orders/{id}", "Orders", "Delete an order", nil, nil, nil, http.StatusNoContent},
+	{http.MethodPost, "/orders/{id}/close", "Orders", "Close an order and deduct inventory", nil, nil, nil, http.StatusCreated},
+
+	{http.MethodGet, "/reports/total-sales", "Reports", "Total sales of closed orders", nil, nil, models.TotalSales{}, http.StatusOK},
+	{http.MethodGet, "/reports/popular-items", "Reports", "Top three items by quantity sold", nil, nil, []models.PopularItem{}, http.StatusOK},
+}
+
+var apiV1Operations = []apiOperation{
+	{http.MethodGet, "/menu/available", "Menu", "List menu items that can be ordered now", []string{"category", "tag", "exclude_allergens"}, nil, []models.MenuItem{}, http.StatusOK},
+	{http.MethodPut, "/menu/{id}/86", "Menu", "Mark a menu item as unavailable (86 it)", nil, nil, models.MenuItem{}, http.StatusOK},
+	{http.MethodDelete, "/menu/{id}/86", "Menu", "Make an 86'd menu item available again", nil, nil, models.MenuItem{}, http.StatusOK},
+	{http.MethodPost, "/menu/{id}/scheduled-prices", "Menu", "Schedule a price change for a future date", nil, models.PriceChange{}, models.PriceChange{}, http.StatusCreated},
+	{http.MethodDelete, "/menu/{id}/scheduled-prices/{change}", "Menu", "Cancel a scheduled price change", nil, nil, nil, http.StatusNoContent},
+	{http.MethodPost, "/pricing-rules", "Menu", "Add a time-based pricing rule", nil, models.PricingRule{}, models.PricingRule{}, http.StatusCreated},
+	{http.MethodGet, "/pricing-rules", "Menu", "List pricing rules", nil, nil, []models.PricingRule{}, http.StatusOK},
+	{http.MethodGet, "/pricing-rules/{id}", "Menu", "Get a pricing rule", nil, nil, models.PricingRule{}, http.StatusOK},
+	{http.MethodPut, "/pricing-rules/{id}", "Menu", "Replace a pricing rule", nil, models.PricingRule{}, models.PricingRule{}, http.StatusOK},
+	{http.MethodDelete, "/pricing-rules/{id}", "Menu", "Delete a pricing rule", nil, nil, nil, http.StatusNoContent},
+	{http.MethodPost, "/promo-codes", "Menu", "Add a promo code", nil, models.PromoCode{}, models.PromoCode{}, http.StatusCreated},
+	{http.MethodGet, "/promo-codes", "Menu", "List promo codes with their use counts", nil, nil, []models.PromoCode{}, http.StatusOK},
+	{http.MethodGet, "/promo-codes/{code}", "Menu", "Get a promo code", nil, nil, models.PromoCode{}, http.StatusOK},
+	{http.MethodPut, "/promo-codes/{code}", "Menu", "Replace the terms of a promo code", nil, models.PromoCode{}, models.PromoCode{}, http.StatusOK},
+	{http.MethodDelete, "/promo-codes/{code}", "Menu", "Delete a promo code", nil, nil, nil, http.StatusNoContent},
+	{http.MethodGet, "/tax", "Menu", "Tax rates, inclusive or exclusive pricing and rounding", nil, nil, models.TaxConfig{}, http.StatusOK},
+	{http.MethodPut, "/tax", "Menu", "Replace the tax configuration", nil, models.TaxConfig{}, models.TaxConfig{}, http.StatusOK},
+	{http.MethodGet, "/loyalty", "Menu", "Loyalty program: points rules or stamp card, and expiry", nil, nil, models.LoyaltyProgram{}, http.StatusOK},
+	{http.MethodPut, "/loyalty", "Menu", "Replace the loyalty program", nil, models.LoyaltyProgram{}, models.LoyaltyProgram{}, http.StatusOK},
+	{http.MethodGet, "/pickup-schedule", "Menu", "Opening hours, slot capacity and release lead time for orders placed ahead", nil, nil, models.PickupSchedule{}, http.StatusOK},
+	{http.MethodPut, "/pickup-schedule", "Menu", "Replace the pickup schedule", nil, models.PickupSchedule{}, models.PickupSchedule{}, http.StatusOK},
+	{http.MethodGet, "/service-charge", "Menu", "Automatic service charge for large parties", nil, nil, models.ServiceCharge{}, http.StatusOK},
+	{http.MethodPut, "/service-charge", "Menu", "Replace the service charge; a percent of 0 turns it off", nil, models.ServiceCharge{}, models.ServiceCharge{}, http.StatusOK},
+	{http.MethodGet, "/menu/tree", "Menu", "Categories with their subcategories and items in display order", nil, nil, models.MenuTree{}, http.StatusOK},
+	{http.MethodPost, "/categories", "Menu", "Add a menu category", nil, models.Category{}, models.Category{}, http.StatusCreated},
+	{http.MethodGet, "/categories", "Menu", "List menu categories in sort order", nil, nil, []models.Category{}, http.StatusOK},
+	{http.MethodGet, "/categories/{id}", "Menu", "Get a menu category", nil, nil, models.Category{}, http.StatusOK},
+	{http.MethodPut, "/categories/{id}", "Menu", "Replace a menu category", nil, models.Category{}, models.Category{}, http.StatusOK},
+	{http.MethodDelete, "/categories/{id}", "Menu", "Delete an empty menu category", nil, nil, nil, http.StatusNoContent},
+
+	{http.MethodPost, "/auth/login", "Auth", "Exchange staff credentials for a session token", nil, LoginRequest{}, service.Session{}, http.StatusOK},
+	{http.MethodPost, "/auth/logout", "Auth", "Revoke the current session token", nil, nil, nil, http.StatusNoContent},
+	{http.MethodGet, "/auth/users", "Auth", "List staff accounts", nil, nil, []UserResponse{}, http.StatusOK},
+	{http.MethodPost, "/auth/users", "Auth", "Create a staff account", nil, UserRequest{}, nil, http.StatusCreated},
+	{http.MethodPut, "/auth/users/{username}/password", "Auth", "Change a staff password", nil, UserRequest{}, nil, http.StatusOK},
+	{http.MethodPut, "/auth/users/{username}/role", "Auth", "Change a staff role", nil, UserRequest{}, nil, http.StatusOK},
+	{http.MethodDelete, "/auth/users/{username}", "Auth", "Delete a staff account", nil, nil, nil, http.StatusNoContent},
+	{http.MethodGet, "/auth/keys", "Auth", "List device API keys", nil, nil, []APIKeyResponse{}, http.StatusOK},
+	{http.MethodPost, "/auth/keys", "Auth", "Create a device API key; the key is only shown once", nil, APIKeyRequest{}, APIKeyResponse{}, http.StatusCreated},
+	{http.MethodDelete, "/auth/keys/{id}", "Auth", "Revoke a device API key", nil, nil, nil, http.StatusNoContent},
+	{http.MethodGet, "/me/permissions", "Auth", "Role and permissions of the caller", nil, nil, PermissionsResponse{}, http.StatusOK},
+
+	{http.MethodGet, "/orders/stream", "Orders", "Stream order events (Server-Sent Events)", []string{"status", "last_event_id"}, nil, nil, http.StatusOK},
+	{http.MethodGet, "/pickup-slots", "Orders", "Pickup slots of a day (today by default) that can still be booked", []string{"date"}, nil, []models.PickupSlot{}, http.StatusOK},
+	{http.MethodPost, "/orders/{id}/release", "Orders", "Send a scheduled order to the kitchen before its release time", nil, nil, models.Order{}, http.StatusOK},
+	{http.MethodPost, "/orders/{id}/discounts", "Orders", "Apply a promo code to an open order", nil, DiscountRequest{}, models.Order{}, http.StatusOK},
+	{http.MethodDelete, "/orders/{id}/discounts/{code}", "Orders", "Remove a promo code from an open order", nil, nil, models.Order{}, http.StatusOK},
+	{http.MethodPost, "/orders/{id}/payments", "Orders", "Pay all or part of an order, or the items of one guest", nil, models.Payment{}, models.Payment{}, http.StatusCreated},
+	{http.MethodDelete, "/orders/{id}/payments/{payment}", "Orders", "Refund a payment of an order that is not closed", nil, nil, models.Order{}, http.StatusOK},
+	{http.MethodPost, "/gift-cards", "Gift cards", "Issue a gift card with its opening balance; the code is made up when left out", nil, models.GiftCard{}, models.GiftCard{}, http.StatusCreated},
+	{http.MethodGet, "/gift-cards", "Gift cards", "List gift cards", nil, nil, []models.GiftCard{}, http.StatusOK},
+	{http.MethodGet, "/gift-cards/{code}", "Gift cards", "Balance inquiry", nil, nil, models.GiftCard{}, http.StatusOK},
+	{http.MethodPost, "/gift-cards/{code}/reload", "Gift cards", "Add to the balance of a gift card", nil, GiftCardReloadRequest{}, models.GiftCard{}, http.StatusOK},
+	{http.MethodGet, "/gift-cards/{code}/transactions", "Gift cards", "Ledger of a gift card, newest first", nil, nil, []models.GiftCardTransaction{}, http.StatusOK},
+	{http.MethodPost, "/vouchers", "Vouchers", "Record a paper voucher; the code is made up when left out", nil, models.Voucher{}, models.Voucher{}, http.StatusCreated},
+	{http.MethodGet, "/vouchers", "Vouchers", "List vouchers", nil, nil, []models.Voucher{}, http.StatusOK},
+	{http.MethodGet, "/vouchers/{code}", "Vouchers", "Look up a voucher and the order it was used on", nil, nil, models.Voucher{}, http.StatusOK},
+	{http.MethodPut, "/orders/{id}/tip", "Orders", "Set a fixed or percent tip on an order that is not closed", nil, models.OrderTip{}, models.Order{}, http.StatusOK},
+	{http.MethodDelete, "/orders/{id}/tip", "Orders", "Remove the tip from an order that is not closed", nil, nil, models.Order{}, http.StatusOK},
+	{http.MethodGet, "/reports/tips", "Reports", "Tips and service charges of closed orders by day and by staff member", []string{"from", "to"}, nil, models.TipReport{}, http.StatusOK},
+	{http.MethodGet, "/reports/tip-pool", "Reports", "Split the tips of closed orders by hours worked, e.g. hours=alice:6,bob:4", []string{"from", "to", "hours"}, nil, models.TipPool{}, http.StatusOK},
+	{http.MethodGet, "/kds", "Orders", "Kitchen display WebSocket; messages are KDSMessage in and KDSEvent out", nil, nil, nil, http.StatusSwitchingProtocols},
+
+	{http.MethodPost, "/customers", "Customers", "Add a customer profile; phone or email is required", nil, models.Customer{}, models.Customer{}, http.StatusCreated},
+	{http.MethodGet, "/customers", "Customers", "List customers by name; q searches name, phone and email", []string{"q"}, nil, []models.Customer{}, http.StatusOK},
+	{http.MethodGet, "/customers/{id}", "Customers", "Get a customer profile", nil, nil, models.Customer{}, http.StatusOK},
+	{http.MethodPut, "/customers/{id}", "Customers", "Replace a customer profile", nil, models.Customer{}, models.Customer{}, http.StatusOK},
+	{http.MethodDelete, "/customers/{id}", "Customers", "Delete a customer without orders in progress", nil, nil, nil, http.StatusNoContent},
+	{http.MethodGet, "/customers/{id}/orders", "Customers", "Order history of a customer, newest first", nil, nil, []models.Order{}, http.StatusOK},
+	{http.MethodGet, "/customers/{id}/loyalty", "Customers", "Loyalty balance, next expiry and ledger of a customer", nil, nil, models.LoyaltyAccount{}, http.StatusOK},
+	{http.MethodPost, "/customers/{id}/reorder", "Customers", "Place the customer's last order, or order_id, again as a new open order", []string{"order_id"}, nil, models.Order{}, http.StatusCreated},
+
+	{http.MethodPost, "/webhooks", "Webhooks", "Subscribe a URL to events; the secret is only shown once", nil, WebhookRequest{}, WebhookResponse{}, http.StatusCreated},
+	{http.MethodGet, "/webhooks", "Webhooks", "List webhook subscriptions", nil, nil, []WebhookResponse{}, http.StatusOK},
+	{http.MethodGet, "/webhooks/{id}", "Webhooks", "Get a webhook subscription", nil, nil, WebhookResponse{}, http.StatusOK},
+	{http.MethodPut, "/webhooks/{id}", "Webhooks", "Replace a webhook subscription; a new secret rotates it", nil, WebhookRequest{}, WebhookResponse{}, http.StatusOK},
+	{http.MethodDelete, "/webhooks/{id}", "Webhooks", "Delete a webhook subscription", nil, nil, nil, http.StatusNoContent},
+	{http.MethodGet, "/webhooks/{id}/deliveries", "Webhooks", "Delivery log of a webhook, newest first", []string{"status"}, nil, []models.WebhookDelivery{}, http.StatusOK},
+	{http.MethodPost, "/webhooks/{id}/deliveries/{delivery}/replay", "Webhooks", "Queue an earlier delivery again", nil, nil, models.WebhookDelivery{}, http.StatusAccepted},
+
+	{http.MethodGet, "/inventory/reservations", "Inventory", "Stock held by orders that are not closed, with the pickups holding it", nil, nil, []models.InventoryReservation{}, http.StatusOK},
+	{http.MethodGet, "/alerts", "Inventory", "Low-stock and out-of-stock alerts, newest first", []string{"status", "ingredient_id"}, nil, []models.Alert{}, http.StatusOK},
+	{http.MethodPost, "/alerts/{id}/acknowledge", "Inventory", "Acknowledge a stock alert", nil, nil, models.Alert{}, http.StatusOK},
+
+	{http.MethodGet, "/audit", "Audit", "Query the audit log of mutations", []string{"entity", "id", "actor", "action", "from", "to"}, nil, []models.AuditEntry{}, http.StatusOK},
+	{http.MethodGet, "/audit/verify", "Audit", "Verify the hash chain of the audit log", nil, nil, service.AuditVerification{}, http.StatusOK},
+}
+
+var apiV2Operations = []apiOperation{
+	{http.MethodPost, "/menu", "Menu v2", "Add a menu item", nil, MenuItemV2{}, MenuItemV2{}, http.StatusCreated},
+	{http.MethodGet, "/menu", "Menu v2", "List menu items", nil, nil, []MenuItemV2{}, http.StatusOK},
+	{http.MethodGet, "/menu/{id}", "Menu v2", "Get a menu item", nil, nil, MenuItemV2{}, http.StatusOK},
+	{http.MethodPut, "/menu/{id}", "Menu v2", "Replace a menu item", nil, MenuItemV2{}, MenuItemV2{}, http.StatusOK},
+	{http.MethodDelete, "/menu/{id}", "Menu v2", "Delete a menu item", nil, nil, nil, http.StatusNoContent},
+
+	{http.MethodPost, "/orders", "Orders v2", "Create an order", nil, OrderV2{}, OrderV2{}, http.StatusCreated},
+	{http.MethodGet, "/orders", "Orders v2", "List orders", nil, nil, []OrderV2{}, http.StatusOK},
+	{http.MethodGet, "/orders/{id}", "Orders v2", "Get an order", nil, nil, OrderV2{}, http.StatusOK},
+	{http.MethodPut, "/orders/{id}", "Orders v2", "Modify an order", nil, OrderV2{}, OrderV2{}, http.StatusOK},
+	{http.MethodDelete, "/orders/{id}", "Orders v2", "Delete an order", nil, nil, nil, http.StatusNoContent},
+	{http.MethodPost, "/orders/{id}/close", "Orders v2", "Close an order and deduct inventory", nil, nil, nil, http.StatusCreated},
+}
+
+var rootOperations = []apiOperation{
+	{http.MethodGet, "/openapi.json", "Documentation", "This document", nil, nil, nil, http.StatusOK},
+	{http.MethodGet, "/docs", "Documentation", "Interactive API viewer", nil, nil, nil, http.StatusOK},
+}
+
+var (
+	openAPIOnce sync.Once
+	openAPIDoc  *openapi.Document
+	pathParam   = regexp.MustCompile(`\{(\w+)\}`)
+)
+
+func OpenAPIEndpoints(mux *http.ServeMux) {
+	handleRoot(mux, http.MethodGet, "/openapi.json", GetOpenAPIHandler)
+	handleRoot(mux, http.MethodGet, "/docs", GetDocsHandler)
+}
+
+func OpenAPIDocument() *openapi.Document {
+	openAPIOnce.Do(func() {
+		openAPIDoc = buildOpenAPIDocument()
+	})
+	return openAPIDoc
+}
+
+func buildOpenAPIDocument() *openapi.Document {
+	doc := openapi.NewDocument(openapi.Info{
+		Title:       "Hot-Coffee API",
+		Version:     "1.0.0",
+		Description: "Coffee shop orders, menu, inventory and reports. Unversioned paths are deprecated aliases of /api/v1.",
+	})
+	doc.Components.SecuritySchemes = map[string]openapi.SecurityScheme{
+		"session": {Type: "http", Scheme: "bearer", BearerFormat: "HMAC-signed session token from /api/v1/auth/login"},
+		"apiKey":  {Type: "apiKey", In: "header", Name: "X-API-Key", Description: "Device key; also accepted as \"Authorization: ApiKey <key>\""},
+	}
+	doc.Security = []openapi.SecurityRequirement{{"session": {}}, {"apiKey": {}}}
+
+	for _, op := range apiOperations {
+		addOperation(doc, APIv1Prefix, op, false)
+		addOperation(doc, "", op, true)
+	}
+	for _, op := range apiV1Operations {
+		addOperation(doc, APIv1Prefix, op, false)
+	}
+	for _, op := range apiV2Operations {
+		addOperation(doc, APIv2Prefix, op, false)
+	}
+	for _, op := range rootOperations {
+		addOperation(doc, "", op, false)
+	}
+	setEventStream(doc, APIv1Prefix+"/orders/stream")
+	doc.SchemaOf(KDSMessage{})
+	doc.SchemaOf(KDSEvent{})
+	annotateSecurity(doc)
+	return doc
+}
+
+func addOperation(doc *openapi.Document, prefix string, op apiOperation, isDeprecated bool) {
+	path := prefix + op.Path
+	operation := &openapi.Operation{
+		Tags:        []string{op.Tag},
+		Summary:     op.Summary,
+		OperationID: operationID(op.Method, path),
+		Deprecated:  isDeprecated,
+		Responses: map[string]openapi.Response{
+			"default": {
+				Description: "Error",
+				Content:     map[string]openapi.MediaType{"application/json": {Schema: doc.SchemaOf(ErrorJson{})}},
+			},
+		},
+	}
+	if isDeprecated {
+		operation.Tags = []string{op.Tag + " (deprecated aliases)"}
+	}
+
+	for _, match := range pathParam.FindAllStringSubmatch(op.Path, -1) {
+		operation.Parameters = append(operation.Parameters, openapi.Parameter{
+			Name: match[1], In: "path", Required: true, Schema: &openapi.Schema{Type: "string"},
+		})
+	}
+	for _, name := range op.Query {
+		operation.Parameters = append(operation.Parameters, openapi.Parameter{
+			Name: name, In: "query", Schema: &openapi.Schema{Type: "string"},
+		})
+	}
+
+	if op.Request != nil {
+		schema := doc.SchemaOf(op.Request)
+		content := map[string]openapi.MediaType{"application/json": {Schema: schema}}
+		if prefix != APIv2Prefix {
+			content["application/x-www-form-urlencoded"] = openapi.MediaType{Schema: schema}
+		}
+		operation.RequestBody = &openapi.RequestBody{Required: true, Content: content}
+	}
+
+	success := openapi.Response{Description: http.StatusText(op.Status)}
+	if op.Response != nil {
+		success.Content = map[string]openapi.MediaType{"application/json": {Schema: doc.SchemaOf(op.Response)}}
+	} else if op.Status != http.StatusNoContent && op.Status != http.StatusSwitchingProtocols {
+		success.Content = map[string]openapi.MediaType{"text/plain": {Schema: &openapi.Schema{Type: "string"}}}
+	}
+	operation.Responses[fmt.Sprint(op.Status)] = success
+
+	doc.Add(op.Method, path, operation)
+}
+
+// setEventStream documents a GET operation as a text/event-stream whose
+// data lines carry service.Event.
+func setEventStream(doc *openapi.Document, path string) {
+	operation := doc.Paths[path]["get"]
+	operation.Responses["200"] = openapi.Response{
+		Description: "Stream of events",
+		Content:     map[string]openapi.MediaType{"text/event-stream": {Schema: doc.SchemaOf(service.Event{})}},
+	}
+	operation.Parameters = append(operation.Parameters, openapi.Parameter{
+		Name: "Last-Event-ID", In: "header", Schema: &openapi.Schema{Type: "string"},
+	})
+}
+
+// annotateSecurity copies what the route table knows about access onto the
+// operations: public routes need no credentials, the rest name the
+// permission they are registered with.
+func annotateSecurity(doc *openapi.Document) {
+	for _, route := range RegisteredRoutes() {
+		if !doc.Has(route.Method, route.Path) {
+			continue
+		}
+		operation := doc.Paths[route.Path][strings.ToLower(route.Method)]
+		if route.Public {
+			none := []openapi.SecurityRequirement{}
+			operation.Security = &none
+			continue
+		}
+		if route.Permission != "" {
+			operation.Description = fmt.Sprintf("Requires the `%s` permission.", route.Permission)
+		}
+	}
+}
+
+func operationID(method, path string) string {
+	var b strings.Builder
+	b.WriteString(strings.ToLower(method))
+	for _, part := range strings.FieldsFunc(path, func(r rune) bool {
+		return r == '/' || r == '-' || r == '.' || r == '{' || r == '}'
+	}) {
+		b.WriteString(strings.ToUpper(part[:1]) + part[1:])
+	}
+	return b.String()
+}
+
+// CheckOpenAPICoverage reports every registered route that the specification
+// does not describe, and every documented operation nobody registered.
+func CheckOpenAPICoverage() error {
+	doc := OpenAPIDocument()
+	registered := map[string]bool{}
+	var problems []string
+
+	for _, route := range RegisteredRoutes() {
+		registered[route.Method+" "+route.Path] = true
+		if !doc.Has(route.Method, route.Path) {
+			problems = append(problems, "undocumented route "+route.Method+" "+route.Path)
+		}
+	}
+	for path, item := range doc.Paths {
+		for method := range item {
+			if !registered[strings.ToUpper(method)+" "+path] {
+				problems = append(problems, "documented route is not registered "+strings.ToUpper(method)+" "+path)
+			}
+		}
+	}
+
+	if len(problems) > 0 {
+		sort.Strings(problems)
+		return fmt.Errorf("openapi specification is out of date:\n  %s", strings.Join(problems, "\n  "))
+	}
+	return nil
+}
+
+func GetOpenAPIHandler(w http.ResponseWriter, r *http.Request) {
+	jsonData, err := json.MarshalIndent(OpenAPIDocument(), "", "    ")
+	if err != nil {
+		ErrorResponse(w, "Failed to encode OpenAPI document", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(http.StatusOK)
+	if _, err = w.Write(jsonData); err != nil {
+		ErrorResponse(w, "Failed to write response", http.StatusInternalServerError)
+	}
+}
+
+func GetDocsHandler(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(http.StatusOK)
+	if _, err := w.Write(docsPage); err != nil {
+		ErrorResponse(w, "Failed to write response", http.StatusInternalServerError)
+	}
+}
@@ -0,0 +1,14 @@
+package handler
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestOpenAPICoversEveryRoute(t *testing.T) {
+	AllEndpoints(http.NewServeMux())
+
+	if err := CheckOpenAPICoverage(); err != nil {
+		t.Fatal(err)
+	}
+}
@@ -2,7 +2,6 @@ package handler
 
 import (
 	"encoding/json"
-	"errors"
 	"fmt"
 	"hot-coffee1/internal/service"
 	"hot-coffee1/models"
@@ -14,29 +13,20 @@ import (
 var OrderService = service.NewOrderService()
 
 func OrderEndpoints(mux *http.ServeMux) {
-	mux.HandleFunc("POST /orders", PostOrderHandler)
-	mux.HandleFunc("POST /orders/", PostOrderHandler)
-
-	mux.HandleFunc("GET /orders", GetAllOrdersHandler)
-	mux.HandleFunc("GET /orders/", GetAllOrdersHandler)
-
-	mux.HandleFunc("GET /orders/{id}", GetOrderByIDHandler)
-	mux.HandleFunc("GET /orders/{id}/", GetOrderByIDHandler)
-
-	mux.HandleFunc("PUT /orders/{id}", PutOrderHandler)
-	mux.HandleFunc("PUT /orders/{id}/", PutOrderHandler)
-
-	mux.HandleFunc("DELETE /orders/{id}", DeleteOrderByIDHandler)
-	mux.HandleFunc("DELETE /orders/{id}/", DeleteOrderByIDHandler)
-
-	mux.HandleFunc("POST /orders/{id}/close", PostOrderCloserHandler)
-	mux.HandleFunc("POST /orders/{id}/close/", PostOrderCloserHandler)
+	handle(mux, http.MethodPost, "/orders", PostOrderHandler, service.PermOrdersWrite)
+	handle(mux, http.MethodGet, "/orders", GetAllOrdersHandler, service.PermOrdersRead)
+	handle(mux, http.MethodGet, "/orders/{id}", GetOrderByIDHandler, service.PermOrdersRead)
+	handle(mux, http.MethodPut, "/orders/{id}", PutOrderHandler, service.PermOrdersWrite)
+	handle(mux, http.MethodDelete, "/orders/{id}", DeleteOrderByIDHandler, service.PermOrdersDelete)
+	handle(mux, http.MethodPost, "/orders/{id}/close", PostOrderCloserHandler, service.PermOrdersWrite)
+	handleV1(mux, http.MethodGet, "/orders/stream", GetOrderStreamHandler, service.PermOrdersRead)
+	handleV1(mux, http.MethodGet, "/kds", GetKDSHandler, service.PermOrdersRead)
 }
 
 func GetAllOrdersHandler(w http.ResponseWriter, r *http.Request) {
 	orders, err := OrderService.GetAllOrders()
 	if err != nil {
-		ErrorResponse(w, "Could not retrieve orders data", http.StatusInternalServerError)
+		WriteError(w, err)
 		return
 	}
 
@@ -60,11 +50,8 @@ func GetOrderByIDHandler(w http.ResponseWriter, r *http.Request) {
 	idString := r.PathValue("id")
 
 	order, err := OrderService.GetOrderByID(idString) // ID передаем как string
-	if errors.Is(err, service.ErrOrderNotRead) {
-		ErrorResponse(w, err.Error(), http.StatusInternalServerError)
-		return
-	} else if err != nil {
-		ErrorResponse(w, err.Error(), http.StatusNotFound)
+	if err != nil {
+		WriteError(w, err)
 		return
 	}
 
@@ -87,18 +74,13 @@ func GetOrderByIDHandler(w http.ResponseWriter, r *http.Request) {
 
 func PostOrderHandler(w http.ResponseWriter, r *http.Request) {
 	order, err := parseOrder(r)
-	if errors.Is(err, ErrUnsupportedContentType) {
-		ErrorResponse(w, err.Error(), http.StatusUnsupportedMediaType)
-	} else if err != nil {
-		ErrorResponse(w, err.Error(), http.StatusBadRequest)
+	if err != nil {
+		WriteError(w, err)
 		return
 	}
 
-	if err = OrderService.AddNewOrder(order); errors.Is(err, service.ErrConflict) {
-		ErrorResponse(w, err.Error(), http.StatusConflict)
-		return
-	} else if err != nil {
-		ErrorResponse(w, err.Error(), http.StatusBadRequest)
+	if order, err = OrderService.AddNewOrder(r.Context(), order); err != nil {
+		WriteError(w, err)
 		return
 	}
 
@@ -114,11 +96,8 @@ func PostOrderHandler(w http.ResponseWriter, r *http.Request) {
 func PostOrderCloserHandler(w http.ResponseWriter, r *http.Request) {
 	idString := r.PathValue("id") // id как строка
 
-	if err := OrderService.CloseOrder(idString); errors.Is(err, service.ErrNotExists) {
-		ErrorResponse(w, err.Error(), http.StatusNotFound)
-		return
-	} else if err != nil {
-		ErrorResponse(w, err.Error(), http.StatusBadRequest)
+	if err := OrderService.CloseOrder(r.Context(), idString); err != nil {
+		WriteError(w, err)
 		return
 	}
 
@@ -132,21 +111,15 @@ func PostOrderCloserHandler(w http.ResponseWriter, r *http.Request) {
 
 func PutOrderHandler(w http.ResponseWriter, r *http.Request) {
 	order, err := parseOrder(r)
-	if errors.Is(err, ErrUnsupportedContentType) {
-		ErrorResponse(w, err.Error(), http.StatusUnsupportedMediaType)
-		return
-	} else if err != nil {
-		ErrorResponse(w, err.Error(), http.StatusBadRequest)
+	if err != nil {
+		WriteError(w, err)
 		return
 	}
 
 	idString := r.PathValue("id") // id как строка
 
-	if err = OrderService.ModifyOrder(order, idString); errors.Is(err, service.ErrConflict) {
-		ErrorResponse(w, err.Error(), http.StatusConflict)
-		return
-	} else if err != nil {
-		ErrorResponse(w, err.Error(), http.StatusBadRequest)
+	if err = OrderService.ModifyOrder(r.Context(), order, idString); err != nil {
+		WriteError(w, err)
 		return
 	}
 
@@ -163,12 +136,8 @@ func PutOrderHandler(w http.ResponseWriter, r *http.Request) {
 func DeleteOrderByIDHandler(w http.ResponseWriter, r *http.Request) {
 	idString := r.PathValue("id") // id как строка
 
-	err := OrderService.DeleteOrder(idString) // ID передаем как string
-	if errors.Is(err, service.ErrOrderNotRead) {
-		ErrorResponse(w, err.Error(), http.StatusNotFound)
-		return
-	} else if err != nil {
-		ErrorResponse(w, err.Error(), http.StatusInternalServerError)
+	if err := OrderService.DeleteOrder(r.Context(), idString); err != nil { // ID передаем как string
+		WriteError(w, err)
 		return
 	}
 
@@ -183,34 +152,40 @@ func parseOrder(r *http.Request) (models.Order, error) {
 
 	if contentType == "application/json" {
 		if err := json.NewDecoder(r.Body).Decode(&order); err != nil {
-			return order, fmt.Errorf("invalid JSON payload")
+			return order, fmt.Errorf("%w: invalid JSON payload", service.ErrMalformedContent)
 		}
 	} else if contentType == "application/x-www-form-urlencoded" {
 		if err := r.ParseForm(); err != nil {
-			return order, fmt.Errorf("invalid form data")
+			return order, fmt.Errorf("%w: invalid form data", service.ErrMalformedContent)
 		}
 
+		v := &service.ValidationError{}
 		ID, err := strconv.Atoi(r.FormValue("order_id"))
 		if err != nil {
-			return order, fmt.Errorf("ID is not an integer")
+			v.Add(service.Pointer("order_id"), "ID is not an integer")
 		}
 
 		var items []models.OrderItem
 		itemsJson := r.FormValue("items")
 		if err := json.Unmarshal([]byte(itemsJson), &items); err != nil {
-			return order, fmt.Errorf("error parsing ingredients: %v", err)
+			v.Add(service.Pointer("items"), "error parsing items: %v", err)
+		}
+		if err := v.Err(); err != nil {
+			return order, err
 		}
 
 		// Используем строковый ID
 		order = models.Order{
 			ID:           fmt.Sprintf("%d", ID), // Преобразуем ID в строку
 			CustomerName: r.FormValue("customer_name"),
+			CustomerID:   r.FormValue("customer_id"),
 			Items:        items,
+			PickupAt:     r.FormValue("pickup_at"),
 			Status:       r.FormValue("status"),
 			CreatedAt:    r.FormValue("created_at"),
 		}
 	} else {
-		return order, fmt.Errorf("unsupported content type")
+		return order, ErrUnsupportedContentType
 	}
 
 	return order, nil
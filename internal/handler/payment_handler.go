@@ -0,0 +1,43 @@
+package handler
+
+import (
+	"hot-coffee1/internal/service"
+	"hot-coffee1/models"
+	"log/slog"
+	"net/http"
+)
+
+func PaymentEndpoints(mux *http.ServeMux) {
+	handleV1(mux, http.MethodPost, "/orders/{id}/payments", PostPaymentHandler, service.PermOrdersWrite)
+	handleV1(mux, http.MethodDelete, "/orders/{id}/payments/{payment}", DeletePaymentHandler, service.PermOrdersDelete)
+}
+
+func PostPaymentHandler(w http.ResponseWriter, r *http.Request) {
+	var payment models.Payment
+	if err := decodeJSONBody(r, &payment); err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	id := r.PathValue("id")
+	payment, err := OrderService.AddPayment(r.Context(), id, payment)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, payment)
+	slog.Info("Recorded payment", "ID", id, "payment", payment.ID, "tender", payment.Tender, "amount", payment.Amount)
+}
+
+func DeletePaymentHandler(w http.ResponseWriter, r *http.Request) {
+	id, paymentID := r.PathValue("id"), r.PathValue("payment")
+	order, err := OrderService.RefundPayment(r.Context(), id, paymentID)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, order)
+	slog.Info("Refunded payment", "ID", id, "payment", paymentID)
+}
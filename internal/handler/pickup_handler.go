@@ -0,0 +1,74 @@
+package handler
+
+import (
+	"hot-coffee1/internal/service"
+	"hot-coffee1/models"
+	"log/slog"
+	"net/http"
+)
+
+var PickupService = service.NewPickupService()
+
+func PickupEndpoints(mux *http.ServeMux) {
+	handleV1(mux, http.MethodGet, "/pickup-schedule", GetPickupScheduleHandler, service.PermMenuRead)
+	handleV1(mux, http.MethodPut, "/pickup-schedule", PutPickupScheduleHandler, service.PermMenuAdmin)
+	handleV1(mux, http.MethodGet, "/pickup-slots", GetPickupSlotsHandler, service.PermOrdersRead)
+	handleV1(mux, http.MethodPost, "/orders/{id}/release", PostOrderReleaseHandler, service.PermOrdersWrite)
+	handleV1(mux, http.MethodGet, "/inventory/reservations", GetInventoryReservationsHandler, service.PermInventoryRead)
+}
+
+func GetPickupScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	schedule, err := PickupService.GetPickupSchedule()
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, schedule)
+}
+
+func PutPickupScheduleHandler(w http.ResponseWriter, r *http.Request) {
+	var schedule models.PickupSchedule
+	if err := decodeJSONBody(r, &schedule); err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	schedule, err := PickupService.SetPickupSchedule(r.Context(), schedule)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, schedule)
+	slog.Info("Updated pickup schedule", "slot_minutes", schedule.SlotMinutes, "slot_capacity", schedule.SlotCapacity, "lead_minutes", *schedule.LeadMinutes)
+}
+
+func GetPickupSlotsHandler(w http.ResponseWriter, r *http.Request) {
+	slots, err := PickupService.GetPickupSlots(r.URL.Query().Get("date"))
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, slots)
+}
+
+func PostOrderReleaseHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	order, err := OrderService.ReleaseOrder(r.Context(), id)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, order)
+	slog.Info("Released order", "ID", id, "pickup_at", order.PickupAt)
+}
+
+func GetInventoryReservationsHandler(w http.ResponseWriter, r *http.Request) {
+	reservations, err := PickupService.GetInventoryReservations()
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, reservations)
+}
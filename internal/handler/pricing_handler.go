@@ -0,0 +1,82 @@
+package handler
+
+import (
+	"hot-coffee1/internal/service"
+	"hot-coffee1/models"
+	"log/slog"
+	"net/http"
+)
+
+var PricingRuleService = service.NewPricingRuleService()
+
+func PricingRuleEndpoints(mux *http.ServeMux) {
+	handleV1(mux, http.MethodPost, "/pricing-rules", PostPricingRuleHandler, service.PermMenuAdmin)
+	handleV1(mux, http.MethodGet, "/pricing-rules", GetAllPricingRulesHandler, service.PermMenuRead)
+	handleV1(mux, http.MethodGet, "/pricing-rules/{id}", GetPricingRuleByIDHandler, service.PermMenuRead)
+	handleV1(mux, http.MethodPut, "/pricing-rules/{id}", PutPricingRuleHandler, service.PermMenuAdmin)
+	handleV1(mux, http.MethodDelete, "/pricing-rules/{id}", DeletePricingRuleHandler, service.PermMenuAdmin)
+}
+
+func PostPricingRuleHandler(w http.ResponseWriter, r *http.Request) {
+	var rule models.PricingRule
+	if err := decodeJSONBody(r, &rule); err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	rule, err := PricingRuleService.AddNewPricingRule(r.Context(), rule)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, rule)
+	slog.Info("Added pricing rule", "ID", rule.ID, "name", rule.Name)
+}
+
+func GetAllPricingRulesHandler(w http.ResponseWriter, r *http.Request) {
+	rules, err := PricingRuleService.GetAllPricingRules()
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, rules)
+}
+
+func GetPricingRuleByIDHandler(w http.ResponseWriter, r *http.Request) {
+	rule, err := PricingRuleService.GetPricingRuleByID(r.PathValue("id"))
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, rule)
+}
+
+func PutPricingRuleHandler(w http.ResponseWriter, r *http.Request) {
+	var rule models.PricingRule
+	if err := decodeJSONBody(r, &rule); err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	rule.ID = r.PathValue("id")
+	rule, err := PricingRuleService.ModifyPricingRule(r.Context(), rule)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, rule)
+	slog.Info("Updated pricing rule", "ID", rule.ID)
+}
+
+func DeletePricingRuleHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if err := PricingRuleService.DeletePricingRule(r.Context(), id); err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	slog.Info("Deleted pricing rule", "ID", id)
+}
@@ -0,0 +1,118 @@
+package handler
+
+import (
+	"hot-coffee1/internal/service"
+	"hot-coffee1/models"
+	"log/slog"
+	"net/http"
+)
+
+var PromoCodeService = service.NewPromoCodeService()
+
+type DiscountRequest struct {
+	Code string `json:"code"`
+}
+
+func PromoCodeEndpoints(mux *http.ServeMux) {
+	handleV1(mux, http.MethodPost, "/promo-codes", PostPromoCodeHandler, service.PermMenuAdmin)
+	handleV1(mux, http.MethodGet, "/promo-codes", GetAllPromoCodesHandler, service.PermMenuAdmin)
+	handleV1(mux, http.MethodGet, "/promo-codes/{code}", GetPromoCodeHandler, service.PermMenuAdmin)
+	handleV1(mux, http.MethodPut, "/promo-codes/{code}", PutPromoCodeHandler, service.PermMenuAdmin)
+	handleV1(mux, http.MethodDelete, "/promo-codes/{code}", DeletePromoCodeHandler, service.PermMenuAdmin)
+	handleV1(mux, http.MethodPost, "/orders/{id}/discounts", PostOrderDiscountHandler, service.PermOrdersWrite)
+	handleV1(mux, http.MethodDelete, "/orders/{id}/discounts/{code}", DeleteOrderDiscountHandler, service.PermOrdersWrite)
+}
+
+func PostPromoCodeHandler(w http.ResponseWriter, r *http.Request) {
+	var promo models.PromoCode
+	if err := decodeJSONBody(r, &promo); err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	promo, err := PromoCodeService.AddNewPromoCode(r.Context(), promo)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, promo)
+	slog.Info("Added promo code", "code", promo.Code)
+}
+
+func GetAllPromoCodesHandler(w http.ResponseWriter, r *http.Request) {
+	codes, err := PromoCodeService.GetAllPromoCodes()
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, codes)
+}
+
+func GetPromoCodeHandler(w http.ResponseWriter, r *http.Request) {
+	promo, err := PromoCodeService.GetPromoCode(r.PathValue("code"))
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, promo)
+}
+
+func PutPromoCodeHandler(w http.ResponseWriter, r *http.Request) {
+	var promo models.PromoCode
+	if err := decodeJSONBody(r, &promo); err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	promo.Code = r.PathValue("code")
+	promo, err := PromoCodeService.ModifyPromoCode(r.Context(), promo)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, promo)
+	slog.Info("Updated promo code", "code", promo.Code)
+}
+
+func DeletePromoCodeHandler(w http.ResponseWriter, r *http.Request) {
+	code := r.PathValue("code")
+	if err := PromoCodeService.DeletePromoCode(r.Context(), code); err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	slog.Info("Deleted promo code", "code", code)
+}
+
+func PostOrderDiscountHandler(w http.ResponseWriter, r *http.Request) {
+	var req DiscountRequest
+	if err := decodeJSONBody(r, &req); err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	id := r.PathValue("id")
+	order, err := OrderService.ApplyDiscount(r.Context(), id, req.Code)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, order)
+	slog.Info("Applied discount", "ID", id, "code", req.Code)
+}
+
+func DeleteOrderDiscountHandler(w http.ResponseWriter, r *http.Request) {
+	id, code := r.PathValue("id"), r.PathValue("code")
+	order, err := OrderService.RemoveDiscount(r.Context(), id, code)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, order)
+	slog.Info("Removed discount", "ID", id, "code", code)
+}
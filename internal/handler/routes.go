@@ -0,0 +1,171 @@
+package handler
+
+import (
+	"fmt"
+	"hot-coffee1/internal/service"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	APIv1Prefix = "/api/v1"
+	APIv2Prefix = "/api/v2"
+)
+
+// Unversioned routes stay reachable as aliases of /api/v1 until the sunset
+// date. Clients are told through Deprecation (RFC 9745), Sunset (RFC 8594)
+// and a successor-version link.
+var (
+	LegacyDeprecatedAt = time.Date(2026, time.October, 18, 0, 0, 0, 0, time.UTC)
+	LegacySunsetAt     = time.Date(2027, time.April, 30, 0, 0, 0, 0, time.UTC)
+)
+
+// Route is one registered endpoint. Permission is what a principal needs to
+// call it; an empty permission on a non-public route only requires
+// authentication.
+type Route struct {
+	Method     string
+	Path       string
+	Permission service.Permission
+	Deprecated bool
+	Public     bool
+}
+
+var (
+	routesMu        sync.Mutex
+	routes          []Route
+	routesByPattern = map[string]Route{}
+)
+
+var allMethods = []string{
+	http.MethodGet, http.MethodHead, http.MethodPost, http.MethodPut,
+	http.MethodPatch, http.MethodDelete, http.MethodOptions,
+}
+
+// AllEndpoints mounts every route of the server on mux.
+func AllEndpoints(mux *http.ServeMux) {
+	InventoryEndpoints(mux)
+	MenuEndpoints(mux)
+	CategoryEndpoints(mux)
+	PricingRuleEndpoints(mux)
+	PromoCodeEndpoints(mux)
+	TaxEndpoints(mux)
+	OrderEndpoints(mux)
+	PaymentEndpoints(mux)
+	GiftCardEndpoints(mux)
+	VoucherEndpoints(mux)
+	TipEndpoints(mux)
+	PickupEndpoints(mux)
+	CustomerEndpoints(mux)
+	LoyaltyEndpoints(mux)
+	AggregationEndpoints(mux)
+	MenuV2Endpoints(mux)
+	OrderV2Endpoints(mux)
+	AuthEndpoints(mux)
+	AuditEndpoints(mux)
+	WebhookEndpoints(mux)
+	AlertEndpoints(mux)
+	OpenAPIEndpoints(mux)
+}
+
+// handle mounts a v1 route under /api/v1 together with its deprecated
+// unversioned alias. Routes added after versioning use handleV1 instead.
+func handle(mux *http.ServeMux, method, path string, h http.HandlerFunc, perm service.Permission) {
+	mount(mux, Route{Method: method, Path: APIv1Prefix + path, Permission: perm}, h)
+	mount(mux, Route{Method: method, Path: path, Permission: perm, Deprecated: true}, deprecated(h))
+}
+
+func handleV1(mux *http.ServeMux, method, path string, h http.HandlerFunc, perm service.Permission) {
+	mount(mux, Route{Method: method, Path: APIv1Prefix + path, Permission: perm}, h)
+}
+
+// handleV2 mounts a route that only exists in /api/v2. Its handler is
+// expected to translate v2 DTOs onto the same services v1 uses.
+func handleV2(mux *http.ServeMux, method, path string, h http.HandlerFunc, perm service.Permission) {
+	mount(mux, Route{Method: method, Path: APIv2Prefix + path, Permission: perm}, h)
+}
+
+// handleRoot mounts routes that are not part of a versioned API, such as
+// the documentation itself.
+func handleRoot(mux *http.ServeMux, method, path string, h http.HandlerFunc) {
+	mount(mux, Route{Method: method, Path: path, Public: true}, h)
+}
+
+// handlePublic mounts a v1 route reachable without credentials.
+func handlePublic(mux *http.ServeMux, method, path string, h http.HandlerFunc) {
+	mount(mux, Route{Method: method, Path: APIv1Prefix + path, Public: true}, h)
+}
+
+// mount registers h for "METHOD path" and its trailing-slash twin, and
+// remembers the route so documentation and middleware can look it up. The
+// twin is anchored with {$} so it does not swallow the whole subtree.
+func mount(mux *http.ServeMux, route Route, h http.HandlerFunc) {
+	patterns := []string{route.Method + " " + route.Path, route.Method + " " + route.Path + "/{$}"}
+	for _, pattern := range patterns {
+		mux.HandleFunc(pattern, h)
+	}
+
+	routesMu.Lock()
+	defer routesMu.Unlock()
+	routes = append(routes, route)
+	for _, pattern := range patterns {
+		routesByPattern[pattern] = route
+	}
+}
+
+// routeFor finds the registered route the mux would dispatch r to.
+func routeFor(mux *http.ServeMux, r *http.Request) (Route, bool) {
+	_, pattern := mux.Handler(r)
+	routesMu.Lock()
+	defer routesMu.Unlock()
+	route, ok := routesByPattern[pattern]
+	return route, ok
+}
+
+func deprecated(h http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Deprecation", fmt.Sprintf("@%d", LegacyDeprecatedAt.Unix()))
+		w.Header().Set("Sunset", LegacySunsetAt.UTC().Format(http.TimeFormat))
+		w.Header().Set("Link", fmt.Sprintf("<%s%s>; rel=\"successor-version\"", APIv1Prefix, r.URL.Path))
+		h(w, r)
+	}
+}
+
+func RegisteredRoutes() []Route {
+	routesMu.Lock()
+	defer routesMu.Unlock()
+	return append([]Route(nil), routes...)
+}
+
+// WithFallback answers requests no route matches with JSON errors: 405 with
+// an Allow header when the path exists under other methods, 404 otherwise.
+func WithFallback(mux *http.ServeMux) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if _, pattern := mux.Handler(r); pattern != "" {
+			mux.ServeHTTP(w, r)
+			return
+		}
+
+		if allowed := allowedMethods(mux, r); len(allowed) > 0 {
+			w.Header().Set("Allow", strings.Join(allowed, ", "))
+			ErrorResponse(w, fmt.Sprintf("method %s is not allowed on %s", r.Method, r.URL.Path), http.StatusMethodNotAllowed)
+			return
+		}
+
+		ErrorResponse(w, fmt.Sprintf("no route for %s", r.URL.Path), http.StatusNotFound)
+	})
+}
+
+func allowedMethods(mux *http.ServeMux, r *http.Request) []string {
+	var allowed []string
+	for _, method := range allMethods {
+		probe := r.Clone(r.Context())
+		probe.Method = method
+		if _, pattern := mux.Handler(probe); pattern != "" {
+			allowed = append(allowed, method)
+		}
+	}
+	return allowed
+}
@@ -0,0 +1,129 @@
+package handler
+
+import (
+	"encoding/json"
+	"fmt"
+	"hot-coffee1/internal/service"
+	"log/slog"
+	"net/http"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const streamHeartbeat = 15 * time.Second
+
+// GetOrderStreamHandler streams order events as Server-Sent Events. Clients
+// resume with Last-Event-ID (or ?last_event_id= for the first connect); when
+// the events since then are no longer retained a "reset" event tells them to
+// reload GET /orders.
+func GetOrderStreamHandler(w http.ResponseWriter, r *http.Request) {
+	afterID, resume, err := lastEventID(r)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	statuses := queryList(r, "status")
+
+	if !resume {
+		afterID = service.Events.LastID()
+	}
+	sub, missed, complete := service.Events.Subscribe(afterID)
+	defer sub.Close()
+
+	w.Header().Set("Content-Type", "text/event-stream")
+	w.Header().Set("Cache-Control", "no-cache")
+	w.Header().Set("Connection", "keep-alive")
+	w.Header().Set("X-Accel-Buffering", "no")
+	w.WriteHeader(http.StatusOK)
+
+	rc := http.NewResponseController(w)
+	fmt.Fprint(w, "retry: 3000\n\n")
+	if !complete {
+		fmt.Fprintf(w, "event: reset\ndata: {\"last_event_id\":%d}\n\n", service.Events.LastID())
+	}
+	for _, event := range missed {
+		if err := writeOrderEvent(w, event, statuses); err != nil {
+			return
+		}
+	}
+	if err := rc.Flush(); err != nil {
+		slog.Error("Streaming is not supported by the connection", "error", err)
+		return
+	}
+
+	heartbeat := time.NewTicker(streamHeartbeat)
+	defer heartbeat.Stop()
+	for {
+		select {
+		case <-r.Context().Done():
+			return
+		case <-heartbeat.C:
+			fmt.Fprint(w, ": ping\n\n")
+		case event, ok := <-sub.Events():
+			if !ok {
+				// Dropped for falling behind; the client reconnects with its
+				// Last-Event-ID and catches up from the history.
+				return
+			}
+			if err := writeOrderEvent(w, event, statuses); err != nil {
+				return
+			}
+		}
+		if err := rc.Flush(); err != nil {
+			return
+		}
+	}
+}
+
+// The status filter applies to created and modified orders. Status changes,
+// closes and deletes are always sent so displays can drop orders that left
+// the statuses they show.
+func writeOrderEvent(w http.ResponseWriter, event service.Event, statuses []string) error {
+	if !service.MatchesType(event.Type, "order.*") {
+		return nil
+	}
+	if len(statuses) > 0 && (event.Type == service.EventOrderCreated || event.Type == service.EventOrderModified) &&
+		!slices.Contains(statuses, strings.ToLower(event.Status)) {
+		return nil
+	}
+
+	data, err := json.Marshal(event)
+	if err != nil {
+		return err
+	}
+	_, err = fmt.Fprintf(w, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+	return err
+}
+
+func lastEventID(r *http.Request) (int64, bool, error) {
+	value := r.Header.Get("Last-Event-ID")
+	field := service.Pointer("Last-Event-ID")
+	if value == "" {
+		value, field = r.URL.Query().Get("last_event_id"), service.Pointer("last_event_id")
+	}
+	if value == "" {
+		return 0, false, nil
+	}
+	id, err := strconv.ParseInt(value, 10, 64)
+	if err != nil || id < 0 {
+		v := &service.ValidationError{}
+		v.Add(field, "%q is not an event ID", value)
+		return 0, false, v
+	}
+	return id, true, nil
+}
+
+// queryList accepts both ?status=open&status=closed and ?status=open,closed.
+func queryList(r *http.Request, name string) []string {
+	var values []string
+	for _, value := range r.URL.Query()[name] {
+		for _, part := range strings.Split(value, ",") {
+			if part = strings.ToLower(strings.TrimSpace(part)); part != "" {
+				values = append(values, part)
+			}
+		}
+	}
+	return values
+}
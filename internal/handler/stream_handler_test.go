@@ -0,0 +1,48 @@
+package handler
+
+import (
+	"errors"
+	"hot-coffee1/internal/service"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestLastEventID(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		query  string
+		id     int64
+		ok     bool
+		field  string
+	}{
+		{"none", "", "", 0, false, ""},
+		{"header", "42", "", 42, true, ""},
+		{"header wins over the query", "42", "7", 42, true, ""},
+		{"query", "", "7", 7, true, ""},
+		{"bad header", "abc", "", 0, false, "/Last-Event-ID"},
+		{"negative query", "", "-1", 0, false, "/last_event_id"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			r := httptest.NewRequest("GET", "/api/v1/orders/stream?last_event_id="+tt.query, nil)
+			if tt.header != "" {
+				r.Header.Set("Last-Event-ID", tt.header)
+			}
+
+			id, ok, err := lastEventID(r)
+			var validationErr *service.ValidationError
+			switch {
+			case tt.field == "" && err != nil:
+				t.Fatal(err)
+			case tt.field != "" && !errors.As(err, &validationErr):
+				t.Fatalf("err = %v, want a validation error", err)
+			case tt.field != "" && validationErr.Violations[0].Field != tt.field:
+				t.Errorf("field = %q, want %q", validationErr.Violations[0].Field, tt.field)
+			}
+			if id != tt.id || ok != tt.ok {
+				t.Errorf("got %d, %v; want %d, %v", id, ok, tt.id, tt.ok)
+			}
+		})
+	}
+}
@@ -0,0 +1,41 @@
+package handler
+
+import (
+	"hot-coffee1/internal/service"
+	"hot-coffee1/models"
+	"log/slog"
+	"net/http"
+)
+
+var TaxService = service.NewTaxService()
+
+func TaxEndpoints(mux *http.ServeMux) {
+	handleV1(mux, http.MethodGet, "/tax", GetTaxConfigHandler, service.PermMenuRead)
+	handleV1(mux, http.MethodPut, "/tax", PutTaxConfigHandler, service.PermMenuAdmin)
+}
+
+func GetTaxConfigHandler(w http.ResponseWriter, r *http.Request) {
+	config, err := TaxService.GetTaxConfig()
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, config)
+}
+
+func PutTaxConfigHandler(w http.ResponseWriter, r *http.Request) {
+	var config models.TaxConfig
+	if err := decodeJSONBody(r, &config); err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	config, err := TaxService.SetTaxConfig(r.Context(), config)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, config)
+	slog.Info("Updated tax configuration", "rates", len(config.Rates), "inclusive", config.Inclusive)
+}
@@ -0,0 +1,101 @@
+package handler
+
+import (
+	"hot-coffee1/internal/service"
+	"hot-coffee1/models"
+	"log/slog"
+	"net/http"
+)
+
+var TipService = service.NewTipService()
+
+func TipEndpoints(mux *http.ServeMux) {
+	handleV1(mux, http.MethodPut, "/orders/{id}/tip", PutTipHandler, service.PermOrdersWrite)
+	handleV1(mux, http.MethodDelete, "/orders/{id}/tip", DeleteTipHandler, service.PermOrdersWrite)
+	handleV1(mux, http.MethodGet, "/service-charge", GetServiceChargeHandler, service.PermMenuRead)
+	handleV1(mux, http.MethodPut, "/service-charge", PutServiceChargeHandler, service.PermMenuAdmin)
+	handleV1(mux, http.MethodGet, "/reports/tips", GetTipReportHandler, service.PermReportsRead)
+	handleV1(mux, http.MethodGet, "/reports/tip-pool", GetTipPoolHandler, service.PermReportsRead)
+}
+
+func PutTipHandler(w http.ResponseWriter, r *http.Request) {
+	var tip models.OrderTip
+	if err := decodeJSONBody(r, &tip); err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	id := r.PathValue("id")
+	order, err := OrderService.SetTip(r.Context(), id, tip)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, order)
+	slog.Info("Set tip", "ID", id, "tip", order.Tip.Amount, "staff", order.Tip.Staff)
+}
+
+func DeleteTipHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	order, err := OrderService.RemoveTip(r.Context(), id)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, order)
+	slog.Info("Removed tip", "ID", id)
+}
+
+func GetServiceChargeHandler(w http.ResponseWriter, r *http.Request) {
+	charge, err := TipService.GetServiceCharge()
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, charge)
+}
+
+func PutServiceChargeHandler(w http.ResponseWriter, r *http.Request) {
+	var charge models.ServiceCharge
+	if err := decodeJSONBody(r, &charge); err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	charge, err := TipService.SetServiceCharge(r.Context(), charge)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, charge)
+	slog.Info("Updated service charge", "percent", charge.Percent, "min_party_size", charge.MinPartySize)
+}
+
+func GetTipReportHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	report, err := TipService.GetTipReport(query.Get("from"), query.Get("to"))
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, report)
+}
+
+func GetTipPoolHandler(w http.ResponseWriter, r *http.Request) {
+	query := r.URL.Query()
+	hours, err := service.ParseHours(query.Get("hours"))
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	pool, err := TipService.GetTipPool(query.Get("from"), query.Get("to"), hours)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, pool)
+}
@@ -0,0 +1,393 @@
+package handler
+
+import (
+	"cmp"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hot-coffee1/internal/service"
+	"hot-coffee1/models"
+	"log/slog"
+	"net/http"
+	"strings"
+)
+
+// The v2 shapes only live in the handler layer; they are translated onto the
+// same models and services that v1 uses. Amounts are integers in the minor
+// unit of Currency (cents for USD, yen for JPY).
+
+type MenuItemV2 struct {
+	ID           string         `json:"id"`
+	Name         string         `json:"name"`
+	Description  string         `json:"description"`
+	PriceMinor   int64          `json:"price_minor"`
+	Currency     string         `json:"currency"`
+	Recipe       []RecipeLineV2 `json:"recipe"`
+	CategoryID   string         `json:"category_id,omitempty"`
+	Tags         []string       `json:"tags,omitempty"`
+	Images       []string       `json:"images,omitempty"`
+	DisplayOrder int            `json:"display_order,omitempty"`
+}
+
+type RecipeLineV2 struct {
+	IngredientID string  `json:"ingredient_id"`
+	Quantity     float64 `json:"quantity"`
+}
+
+type OrderV2 struct {
+	ID            string        `json:"id"`
+	Customer      CustomerV2    `json:"customer"`
+	Lines         []OrderLineV2 `json:"lines"`
+	Status        string        `json:"status"`
+	CreatedAt     string        `json:"created_at"`
+	Discounts     []DiscountV2  `json:"discounts,omitempty"`
+	PartySize     int           `json:"party_size,omitempty"`
+	PickupAt      string        `json:"pickup_at,omitempty"`
+	Currency      string        `json:"currency,omitempty"`
+	Totals        *TotalsV2     `json:"totals,omitempty"`
+	LoyaltyEarned int           `json:"loyalty_earned,omitempty"`
+}
+
+type TotalsV2 struct {
+	SubtotalMinor      int64 `json:"subtotal_minor"`
+	DiscountsMinor     int64 `json:"discounts_minor"`
+	NetMinor           int64 `json:"net_minor"`
+	TaxMinor           int64 `json:"tax_minor"`
+	TotalMinor         int64 `json:"total_minor"`
+	PaidMinor          int64 `json:"paid_minor"`
+	DueMinor           int64 `json:"due_minor"`
+	ServiceChargeMinor int64 `json:"service_charge_minor,omitempty"`
+	TipMinor           int64 `json:"tip_minor,omitempty"`
+	TaxInclusive       bool  `json:"tax_inclusive"`
+}
+
+type DiscountV2 struct {
+	Code        string `json:"code"`
+	Kind        string `json:"kind,omitempty"`
+	Description string `json:"description,omitempty"`
+	Points      int    `json:"points,omitempty"`
+	AmountMinor int64  `json:"amount_minor,omitempty"`
+}
+
+type CustomerV2 struct {
+	ID   string `json:"id,omitempty"`
+	Name string `json:"name"`
+}
+
+type OrderLineV2 struct {
+	ProductID      string `json:"product_id"`
+	Quantity       int    `json:"quantity"`
+	Status         string `json:"status,omitempty"`
+	Name           string `json:"name,omitempty"`
+	UnitPriceMinor int64  `json:"unit_price_minor,omitempty"`
+	ListPriceMinor int64  `json:"list_price_minor,omitempty"`
+	AppliedRule    string `json:"applied_rule,omitempty"`
+}
+
+func MenuV2Endpoints(mux *http.ServeMux) {
+	handleV2(mux, http.MethodPost, "/menu", PostMenuV2Handler, service.PermMenuAdmin)
+	handleV2(mux, http.MethodGet, "/menu", GetAllMenuV2Handler, service.PermMenuRead)
+	handleV2(mux, http.MethodGet, "/menu/{id}", GetMenuByIDV2Handler, service.PermMenuRead)
+	handleV2(mux, http.MethodPut, "/menu/{id}", PutMenuV2Handler, service.PermMenuAdmin)
+	handleV2(mux, http.MethodDelete, "/menu/{id}", DeleteMenuByIDHandler, service.PermMenuAdmin)
+}
+
+func OrderV2Endpoints(mux *http.ServeMux) {
+	handleV2(mux, http.MethodPost, "/orders", PostOrderV2Handler, service.PermOrdersWrite)
+	handleV2(mux, http.MethodGet, "/orders", GetAllOrdersV2Handler, service.PermOrdersRead)
+	handleV2(mux, http.MethodGet, "/orders/{id}", GetOrderByIDV2Handler, service.PermOrdersRead)
+	handleV2(mux, http.MethodPut, "/orders/{id}", PutOrderV2Handler, service.PermOrdersWrite)
+	handleV2(mux, http.MethodDelete, "/orders/{id}", DeleteOrderByIDHandler, service.PermOrdersDelete)
+	handleV2(mux, http.MethodPost, "/orders/{id}/close", PostOrderCloserHandler, service.PermOrdersWrite)
+}
+
+func menuItemToV2(item models.MenuItem) MenuItemV2 {
+	dto := MenuItemV2{
+		ID:           item.ID,
+		Name:         item.Name,
+		Description:  item.Description,
+		PriceMinor:   item.Price.Amount,
+		Currency:     item.Price.Currency,
+		Recipe:       []RecipeLineV2{},
+		CategoryID:   item.CategoryID,
+		Tags:         item.Tags,
+		Images:       item.Images,
+		DisplayOrder: item.DisplayOrder,
+	}
+	for _, ingredient := range item.Ingredients {
+		dto.Recipe = append(dto.Recipe, RecipeLineV2(ingredient))
+	}
+	return dto
+}
+
+func menuItemFromV2(dto MenuItemV2) models.MenuItem {
+	item := models.MenuItem{
+		ID:           dto.ID,
+		Name:         dto.Name,
+		Description:  dto.Description,
+		Price:        models.Money{Amount: dto.PriceMinor, Currency: cmp.Or(dto.Currency, models.DefaultCurrency)},
+		CategoryID:   dto.CategoryID,
+		Tags:         dto.Tags,
+		Images:       dto.Images,
+		DisplayOrder: dto.DisplayOrder,
+	}
+	for _, line := range dto.Recipe {
+		item.Ingredients = append(item.Ingredients, models.MenuItemIngredient(line))
+	}
+	return item
+}
+
+func orderToV2(order models.Order) OrderV2 {
+	dto := OrderV2{
+		ID:            order.ID,
+		Customer:      CustomerV2{ID: order.CustomerID, Name: order.CustomerName},
+		Lines:         []OrderLineV2{},
+		Status:        order.Status,
+		CreatedAt:     order.CreatedAt,
+		PartySize:     order.PartySize,
+		PickupAt:      order.PickupAt,
+		LoyaltyEarned: order.LoyaltyEarned,
+	}
+	for _, item := range order.Items {
+		dto.Lines = append(dto.Lines, OrderLineV2{
+			ProductID:      item.ProductID,
+			Quantity:       item.Quantity,
+			Status:         item.Status,
+			Name:           item.Name,
+			UnitPriceMinor: item.UnitPrice.Amount,
+			ListPriceMinor: item.ListPrice.Amount,
+			AppliedRule:    item.AppliedRule,
+		})
+	}
+	for _, discount := range order.Discounts {
+		dto.Discounts = append(dto.Discounts, DiscountV2{
+			Code:        discount.Code,
+			Kind:        discount.Kind,
+			Description: discount.Description,
+			Points:      discount.Points,
+			AmountMinor: discount.Amount.Amount,
+		})
+	}
+	if totals := order.Totals; totals != nil {
+		dto.Currency = totals.Total.Currency
+		dto.Totals = &TotalsV2{
+			SubtotalMinor:      totals.Subtotal.Amount,
+			DiscountsMinor:     totals.Discounts.Amount,
+			NetMinor:           totals.Net.Amount,
+			TaxMinor:           totals.Tax.Amount,
+			TotalMinor:         totals.Total.Amount,
+			PaidMinor:          totals.Paid.Amount,
+			DueMinor:           totals.Due.Amount,
+			ServiceChargeMinor: totals.ServiceCharge.Amount,
+			TipMinor:           totals.Tip.Amount,
+			TaxInclusive:       totals.TaxInclusive,
+		}
+	}
+	return dto
+}
+
+func orderFromV2(dto OrderV2) models.Order {
+	order := models.Order{
+		ID:           dto.ID,
+		CustomerName: dto.Customer.Name,
+		CustomerID:   dto.Customer.ID,
+		Status:       dto.Status,
+		CreatedAt:    dto.CreatedAt,
+		PartySize:    dto.PartySize,
+		PickupAt:     dto.PickupAt,
+	}
+	for _, line := range dto.Lines {
+		order.Items = append(order.Items, models.OrderItem{ProductID: line.ProductID, Quantity: line.Quantity, Status: line.Status})
+	}
+	for _, discount := range dto.Discounts {
+		order.Discounts = append(order.Discounts, models.OrderDiscount{Code: discount.Code, Points: discount.Points})
+	}
+	return order
+}
+
+type fieldRename struct{ v1, v2 string }
+
+// The services point violations at v1 fields; these map them onto the v2
+// body. More specific pointers come first.
+var (
+	menuFieldsV2 = []fieldRename{
+		{"/price/currency", "/currency"},
+		{"/price", "/price_minor"},
+		{"/ingredients", "/recipe"},
+		{"/product_id", "/id"},
+	}
+	orderFieldsV2 = []fieldRename{
+		{"/customer_name", "/customer/name"},
+		{"/customer_id", "/customer/id"},
+		{"/items", "/lines"},
+		{"/order_id", "/id"},
+	}
+)
+
+// renameViolations rewrites the fields of a validation error in place so
+// they point into a v2 body; other errors are returned as they are.
+func renameViolations(err error, renames []fieldRename) error {
+	var validationErr *service.ValidationError
+	if !errors.As(err, &validationErr) {
+		return err
+	}
+	for i, violation := range validationErr.Violations {
+		for _, rename := range renames {
+			if rest, ok := strings.CutPrefix(violation.Field, rename.v1); ok && (rest == "" || rest[0] == '/') {
+				validationErr.Violations[i].Field = rename.v2 + rest
+				break
+			}
+		}
+	}
+	return err
+}
+
+func decodeJSONBody(r *http.Request, v any) error {
+	if r.Header.Get("Content-Type") != "application/json" {
+		return ErrUnsupportedContentType
+	}
+	if err := json.NewDecoder(r.Body).Decode(v); err != nil {
+		return fmt.Errorf("%w: invalid JSON payload", service.ErrMalformedContent)
+	}
+	return nil
+}
+
+func writeJSON(w http.ResponseWriter, statusCode int, v any) {
+	jsonData, err := json.MarshalIndent(v, "", "    ")
+	if err != nil {
+		ErrorResponse(w, "Failed to encode response", http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(statusCode)
+	if _, err = w.Write(jsonData); err != nil {
+		slog.Error("Failed to write response", "error", err)
+	}
+}
+
+func GetAllMenuV2Handler(w http.ResponseWriter, r *http.Request) {
+	menu, err := MenuService.GetAllMenu()
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	dtos := make([]MenuItemV2, 0, len(menu))
+	for _, item := range menu {
+		dtos = append(dtos, menuItemToV2(item))
+	}
+	writeJSON(w, http.StatusOK, dtos)
+}
+
+func GetMenuByIDV2Handler(w http.ResponseWriter, r *http.Request) {
+	item, err := MenuService.GetMenuByID(r.PathValue("id"))
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, menuItemToV2(item))
+}
+
+func PostMenuV2Handler(w http.ResponseWriter, r *http.Request) {
+	var dto MenuItemV2
+	if err := decodeJSONBody(r, &dto); err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	item := menuItemFromV2(dto)
+	if err := MenuService.AddNewMenuItem(r.Context(), item); err != nil {
+		WriteError(w, renameViolations(err, menuFieldsV2))
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, menuItemToV2(item))
+	slog.Info("Created menu item", "ID", item.ID, "api", "v2")
+}
+
+func PutMenuV2Handler(w http.ResponseWriter, r *http.Request) {
+	var dto MenuItemV2
+	if err := decodeJSONBody(r, &dto); err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	if dto.ID != r.PathValue("id") {
+		v := &service.ValidationError{}
+		v.Add(service.Pointer("id"), "product ID does not match id")
+		WriteError(w, v)
+		return
+	}
+
+	item := menuItemFromV2(dto)
+	if err := MenuService.ModifyMenuItem(r.Context(), item); err != nil {
+		WriteError(w, renameViolations(err, menuFieldsV2))
+		return
+	}
+
+	writeJSON(w, http.StatusOK, menuItemToV2(item))
+	slog.Info("Updated menu item", "ID", item.ID, "api", "v2")
+}
+
+func GetAllOrdersV2Handler(w http.ResponseWriter, r *http.Request) {
+	orders, err := OrderService.GetAllOrders()
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	dtos := make([]OrderV2, 0, len(orders))
+	for _, order := range orders {
+		dtos = append(dtos, orderToV2(order))
+	}
+	writeJSON(w, http.StatusOK, dtos)
+}
+
+func GetOrderByIDV2Handler(w http.ResponseWriter, r *http.Request) {
+	order, err := OrderService.GetOrderByID(r.PathValue("id"))
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, orderToV2(order))
+}
+
+func PostOrderV2Handler(w http.ResponseWriter, r *http.Request) {
+	var dto OrderV2
+	if err := decodeJSONBody(r, &dto); err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	order, err := OrderService.AddNewOrder(r.Context(), orderFromV2(dto))
+	if err != nil {
+		WriteError(w, renameViolations(err, orderFieldsV2))
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, orderToV2(order))
+	slog.Info("Added new order", "ID", order.ID, "api", "v2")
+}
+
+func PutOrderV2Handler(w http.ResponseWriter, r *http.Request) {
+	var dto OrderV2
+	if err := decodeJSONBody(r, &dto); err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	id := r.PathValue("id")
+	if err := OrderService.ModifyOrder(r.Context(), orderFromV2(dto), id); err != nil {
+		WriteError(w, renameViolations(err, orderFieldsV2))
+		return
+	}
+
+	order, err := OrderService.GetOrderByID(id)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, orderToV2(order))
+	slog.Info("Updated order", "ID", id, "api", "v2")
+}
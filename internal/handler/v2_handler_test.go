@@ -0,0 +1,56 @@
+package handler
+
+import (
+	"errors"
+	"fmt"
+	"hot-coffee1/internal/service"
+	"slices"
+	"testing"
+)
+
+func TestRenameViolations(t *testing.T) {
+	tests := []struct {
+		name    string
+		renames []fieldRename
+		fields  []string
+		want    []string
+	}{
+		{"menu", menuFieldsV2,
+			[]string{"/price", "/price/currency", "/ingredients/0/quantity", "/product_id", "/name"},
+			[]string{"/price_minor", "/currency", "/recipe/0/quantity", "/id", "/name"}},
+		{"order", orderFieldsV2,
+			[]string{"/customer_name", "/items/0/quantity", "/items", "/customer_id", "/pickup_at"},
+			[]string{"/customer/name", "/lines/0/quantity", "/lines", "/customer/id", "/pickup_at"}},
+		{"only whole tokens", orderFieldsV2,
+			[]string{"/items_total", "/order_idx"},
+			[]string{"/items_total", "/order_idx"}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			v := &service.ValidationError{}
+			for _, field := range tt.fields {
+				v.Add(field, "invalid")
+			}
+			err := renameViolations(fmt.Errorf("wrapped: %w", v), tt.renames)
+
+			var got *service.ValidationError
+			if !errors.As(err, &got) {
+				t.Fatalf("err = %v, want the validation error back", err)
+			}
+			fields := make([]string, 0, len(got.Violations))
+			for _, violation := range got.Violations {
+				fields = append(fields, violation.Field)
+			}
+			if !slices.Equal(fields, tt.want) {
+				t.Errorf("fields = %v, want %v", fields, tt.want)
+			}
+		})
+	}
+}
+
+func TestRenameViolationsLeavesOtherErrors(t *testing.T) {
+	err := fmt.Errorf("order order1 %w", service.ErrNotFound)
+	if got := renameViolations(err, orderFieldsV2); got != err {
+		t.Errorf("renameViolations changed %v into %v", err, got)
+	}
+}
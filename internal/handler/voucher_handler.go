@@ -0,0 +1,51 @@
+package handler
+
+import (
+	"hot-coffee1/internal/service"
+	"hot-coffee1/models"
+	"log/slog"
+	"net/http"
+)
+
+var VoucherService = service.NewVoucherService()
+
+func VoucherEndpoints(mux *http.ServeMux) {
+	handleV1(mux, http.MethodPost, "/vouchers", PostVoucherHandler, service.PermGiftCardsAdmin)
+	handleV1(mux, http.MethodGet, "/vouchers", GetAllVouchersHandler, service.PermOrdersRead)
+	handleV1(mux, http.MethodGet, "/vouchers/{code}", GetVoucherHandler, service.PermOrdersRead)
+}
+
+func PostVoucherHandler(w http.ResponseWriter, r *http.Request) {
+	var voucher models.Voucher
+	if err := decodeJSONBody(r, &voucher); err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	voucher, err := VoucherService.IssueVoucher(r.Context(), voucher)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, voucher)
+	slog.Info("Issued voucher", "code", voucher.Code, "value", voucher.Value)
+}
+
+func GetAllVouchersHandler(w http.ResponseWriter, r *http.Request) {
+	vouchers, err := VoucherService.GetAllVouchers()
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, vouchers)
+}
+
+func GetVoucherHandler(w http.ResponseWriter, r *http.Request) {
+	voucher, err := VoucherService.GetVoucher(r.PathValue("code"))
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, voucher)
+}
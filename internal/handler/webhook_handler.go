@@ -0,0 +1,170 @@
+package handler
+
+import (
+	"hot-coffee1/internal/service"
+	"hot-coffee1/models"
+	"log/slog"
+	"net/http"
+)
+
+var WebhookService = service.NewWebhookService()
+
+type WebhookRequest struct {
+	URL    string   `json:"url"`
+	Events []string `json:"events"`
+	Secret string   `json:"secret,omitempty"`
+	Active *bool    `json:"active,omitempty"`
+}
+
+type WebhookResponse struct {
+	ID        string   `json:"id"`
+	URL       string   `json:"url"`
+	Events    []string `json:"events"`
+	Active    bool     `json:"active"`
+	CreatedAt string   `json:"created_at"`
+	Secret    string   `json:"secret,omitempty"`
+}
+
+func WebhookEndpoints(mux *http.ServeMux) {
+	handleV1(mux, http.MethodPost, "/webhooks", PostWebhookHandler, service.PermWebhooksAdmin)
+	handleV1(mux, http.MethodGet, "/webhooks", GetAllWebhooksHandler, service.PermWebhooksAdmin)
+	handleV1(mux, http.MethodGet, "/webhooks/{id}", GetWebhookByIDHandler, service.PermWebhooksAdmin)
+	handleV1(mux, http.MethodPut, "/webhooks/{id}", PutWebhookHandler, service.PermWebhooksAdmin)
+	handleV1(mux, http.MethodDelete, "/webhooks/{id}", DeleteWebhookHandler, service.PermWebhooksAdmin)
+	handleV1(mux, http.MethodGet, "/webhooks/{id}/deliveries", GetWebhookDeliveriesHandler, service.PermWebhooksAdmin)
+	handleV1(mux, http.MethodPost, "/webhooks/{id}/deliveries/{delivery}/replay", PostReplayDeliveryHandler, service.PermWebhooksAdmin)
+}
+
+func parseWebhookRequest(r *http.Request) (WebhookRequest, error) {
+	var req WebhookRequest
+	err := decodeBody(r, &req, func() {
+		req = WebhookRequest{URL: r.FormValue("url"), Secret: r.FormValue("secret"), Events: formList(r.FormValue("events"))}
+		if active := r.FormValue("active"); active != "" {
+			value := active == "true"
+			req.Active = &value
+		}
+	})
+	return req, err
+}
+
+// webhook builds the webhook the request describes; active is used when the
+// request leaves it out.
+func (req WebhookRequest) webhook(active bool) models.Webhook {
+	webhook := models.Webhook{URL: req.URL, Events: req.Events, Secret: req.Secret, Active: active}
+	if req.Active != nil {
+		webhook.Active = *req.Active
+	}
+	return webhook
+}
+
+func PostWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	req, err := parseWebhookRequest(r)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	webhook, err := WebhookService.AddNewWebhook(r.Context(), req.webhook(true))
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusCreated, webhookToResponse(webhook, true))
+	slog.Info("Added webhook", "ID", webhook.ID, "url", webhook.URL)
+}
+
+func GetAllWebhooksHandler(w http.ResponseWriter, r *http.Request) {
+	webhooks, err := WebhookService.GetAllWebhooks()
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	response := make([]WebhookResponse, 0, len(webhooks))
+	for _, webhook := range webhooks {
+		response = append(response, webhookToResponse(webhook, false))
+	}
+	writeJSON(w, http.StatusOK, response)
+}
+
+func GetWebhookByIDHandler(w http.ResponseWriter, r *http.Request) {
+	webhook, err := WebhookService.GetWebhookByID(r.PathValue("id"))
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, webhookToResponse(webhook, false))
+}
+
+func PutWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	req, err := parseWebhookRequest(r)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	// Без поля active webhook остаётся включённым или выключенным как был
+	existing, err := WebhookService.GetWebhookByID(r.PathValue("id"))
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	webhook := req.webhook(existing.Active)
+	rotated := webhook.Secret != ""
+	webhook.ID = existing.ID
+	webhook, err = WebhookService.ModifyWebhook(r.Context(), webhook)
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusOK, webhookToResponse(webhook, rotated))
+	slog.Info("Updated webhook", "ID", webhook.ID)
+}
+
+func DeleteWebhookHandler(w http.ResponseWriter, r *http.Request) {
+	id := r.PathValue("id")
+	if err := WebhookService.DeleteWebhook(r.Context(), id); err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+	slog.Info("Deleted webhook", "ID", id)
+}
+
+func GetWebhookDeliveriesHandler(w http.ResponseWriter, r *http.Request) {
+	deliveries, err := WebhookService.GetDeliveries(r.PathValue("id"), r.URL.Query().Get("status"))
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+	writeJSON(w, http.StatusOK, deliveries)
+}
+
+func PostReplayDeliveryHandler(w http.ResponseWriter, r *http.Request) {
+	delivery, err := WebhookService.ReplayDelivery(r.Context(), r.PathValue("id"), r.PathValue("delivery"))
+	if err != nil {
+		WriteError(w, err)
+		return
+	}
+
+	writeJSON(w, http.StatusAccepted, delivery)
+	slog.Info("Replaying webhook delivery", "ID", delivery.ReplayOf, "replay", delivery.ID)
+}
+
+// The secret is only echoed when the caller just chose or received it.
+func webhookToResponse(webhook models.Webhook, withSecret bool) WebhookResponse {
+	response := WebhookResponse{
+		ID:        webhook.ID,
+		URL:       webhook.URL,
+		Events:    webhook.Events,
+		Active:    webhook.Active,
+		CreatedAt: webhook.CreatedAt,
+	}
+	if withSecret {
+		response.Secret = webhook.Secret
+	}
+	return response
+}
@@ -0,0 +1,27 @@
+package handler
+
+import "testing"
+
+func TestWebhookRequestActive(t *testing.T) {
+	on, off := true, false
+	tests := []struct {
+		name     string
+		active   *bool
+		fallback bool
+		want     bool
+	}{
+		{"create without active", nil, true, true},
+		{"update keeps a disabled webhook disabled", nil, false, false},
+		{"update keeps an enabled webhook enabled", nil, true, true},
+		{"update enables", &on, false, true},
+		{"update disables", &off, true, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := WebhookRequest{URL: "https://example.com/hook", Events: []string{"order.*"}, Active: tt.active}
+			if got := req.webhook(tt.fallback).Active; got != tt.want {
+				t.Errorf("active = %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
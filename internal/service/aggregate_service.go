@@ -2,7 +2,9 @@ package service
 
 import (
 	"errors"
+	"fmt"
 	"hot-coffee1/models"
+	"log/slog"
 	"sort"
 	"strings"
 )
@@ -14,7 +16,8 @@ var (
 
 func GetTotalSales() (models.TotalSales, error) {
 	m := NewMenuService()
-	totalSales := models.TotalSales{}
+	zero := models.NewMoney(0)
+	totalSales := models.TotalSales{Amount: zero, Discounts: zero, NetSales: zero, Tax: zero, ServiceCharges: zero, Tips: zero, GrandTotal: zero}
 	ordersStruct := Order{}
 
 	// Загружаем кеш заказов
@@ -23,12 +26,6 @@ func GetTotalSales() (models.TotalSales, error) {
 		return totalSales, err
 	}
 
-	// Загружаем кеш меню
-	err = m.LoadMenuCache()
-	if err != nil {
-		return totalSales, err
-	}
-
 	// Проверка: если нет заказов — ошибка
 	if len(ordersStruct.cacheOrders) == 0 {
 		return totalSales, ErrOrderNotRead
@@ -39,22 +36,79 @@ func GetTotalSales() (models.TotalSales, error) {
 		status := strings.ToLower(order.Status) // Приводим к нижнему регистру
 
 		if status == "closed" {
-			for _, product := range order.Items {
-				// Валидация
-				if err = validateAggregation(order); err != nil {
-					return totalSales, err
-				}
+			// Валидация
+			if err = validateAggregation(order); err != nil {
+				return totalSales, err
+			}
 
-				// Получаем данные продукта из меню
-				menu, errMenu := m.GetMenuByID(product.ProductID)
-				if errMenu != nil {
-					return models.TotalSales{}, errMenu
+			if order.Totals != nil {
+				// Итоги посчитаны при закрытии заказа
+				err := errors.Join(
+					addMoney(&totalSales.Amount, order.Totals.Subtotal),
+					addMoney(&totalSales.NetSales, order.Totals.Net),
+					addMoney(&totalSales.Tax, order.Totals.Tax),
+					// Сервисный сбор и чаевые — не продажи, но входят в итог
+					addMoney(&totalSales.ServiceCharges, order.Totals.ServiceCharge),
+					addMoney(&totalSales.Tips, order.Totals.Tip),
+					addMoney(&totalSales.GrandTotal, order.Totals.Total),
+				)
+				for _, tax := range order.Totals.Taxes {
+					if totalSales.TaxByRate == nil {
+						totalSales.TaxByRate = make(map[string]models.Money)
+					}
+					byRate := totalSales.TaxByRate[tax.RateID]
+					err = errors.Join(err, addMoney(&byRate, tax.Amount))
+					totalSales.TaxByRate[tax.RateID] = byRate
+				}
+				if err != nil {
+					return models.TotalSales{}, fmt.Errorf("order %s: %w", order.ID, err)
 				}
+			} else {
+				// Старые заказы без итогов считаются без налога
+				var subtotal models.Money
+				for _, product := range order.Items {
+					// Цена берётся из строки заказа; строки без снимка цены
+					// (до migrate) — из истории цен, пока позиция есть в меню
+					price := product.UnitPrice
+					if product.Name == "" && price.IsZero() {
+						menu, errMenu := m.GetMenuByID(product.ProductID)
+						if errors.Is(errMenu, ErrNotFound) {
+							slog.Warn("Order line without a price", "ID", order.ID, "product_id", product.ProductID)
+							continue
+						} else if errMenu != nil {
+							return models.TotalSales{}, errMenu
+						}
+						price = priceAt(menu, order.CreatedAt)
+					}
+					if err := addMoney(&subtotal, price.Mul(product.Quantity)); err != nil {
+						return models.TotalSales{}, fmt.Errorf("order %s: %w", order.ID, err)
+					}
+				}
+				discounts, err := orderDiscountTotal(order)
+				if err != nil {
+					return models.TotalSales{}, fmt.Errorf("order %s: %w", order.ID, err)
+				}
+				net, err := subtotal.Sub(discounts)
+				if err == nil {
+					err = errors.Join(addMoney(&totalSales.Amount, subtotal), addMoney(&totalSales.NetSales, net), addMoney(&totalSales.GrandTotal, net))
+				}
+				if err != nil {
+					return models.TotalSales{}, fmt.Errorf("order %s: %w", order.ID, err)
+				}
+			}
 
-				// Увеличиваем итоговую сумму
-				totalSales.Amount += float64(product.Quantity) * menu.Price
+			// Скидки по промокодам считаем отдельно от валовых продаж
+			for _, discount := range order.Discounts {
+				if totalSales.DiscountsByCode == nil {
+					totalSales.DiscountsByCode = make(map[string]models.Money)
+				}
+				byCode := totalSales.DiscountsByCode[discount.Code]
+				if err := errors.Join(addMoney(&totalSales.Discounts, discount.Amount), addMoney(&byCode, discount.Amount)); err != nil {
+					return models.TotalSales{}, fmt.Errorf("order %s: %w", order.ID, err)
+				}
+				totalSales.DiscountsByCode[discount.Code] = byCode
 			}
-		} else if status != "open" && status != "closed" {
+		} else if status != "open" && status != "ready" && status != "closed" && status != OrderScheduled {
 			return models.TotalSales{}, errors.New("order is not closed")
 		}
 	}
@@ -73,6 +127,7 @@ func GetPopularItems() ([]models.PopularItem, error) {
 	}
 
 	sumProdID := map[string]int{}
+	lines := map[string]models.OrderItem{}
 
 	for _, order := range allOrders {
 		status := strings.ToLower(order.Status) // ✅ Учитываем регистр
@@ -86,20 +141,25 @@ func GetPopularItems() ([]models.PopularItem, error) {
 					return nil, errors.New("quantity is <= 0")
 				}
 				sumProdID[product.ProductID] += product.Quantity
+				if product.Name != "" {
+					lines[product.ProductID] = product
+				}
 			}
-		} else if status != "open" && status != "closed" {
+		} else if status != "open" && status != "ready" && status != "closed" && status != OrderScheduled {
 			return nil, errors.New("order has unknown status")
 		}
 	}
 
-	return GetTopItemsByQuantity(sumProdID, 3), nil
+	return GetTopItemsByQuantity(sumProdID, lines, 3), nil
 }
 
-func GetTopItemsByQuantity(productQuantities map[string]int, topN int) []models.PopularItem {
+// GetTopItemsByQuantity describes the topN items from the menu; items taken
+// off the menu since are described by what was stored on their order lines.
+func GetTopItemsByQuantity(productQuantities map[string]int, lines map[string]models.OrderItem, topN int) []models.PopularItem {
 	m := NewMenuService()
 	var quantities []models.OrderItem
 	for id, quantity := range productQuantities {
-		quantities = append(quantities, models.OrderItem{id, quantity})
+		quantities = append(quantities, models.OrderItem{ProductID: id, Quantity: quantity})
 	}
 
 	sort.Slice(quantities, func(i, j int) bool {
@@ -109,8 +169,10 @@ func GetTopItemsByQuantity(productQuantities map[string]int, topN int) []models.
 	var topItems []models.PopularItem
 	for i := 0; i < len(quantities) && i < topN; i++ {
 		menu, menuErr := m.GetMenuByID(quantities[i].ProductID)
-		if menuErr != nil {
-			return []models.PopularItem{}
+		if line, ok := lines[quantities[i].ProductID]; menuErr != nil && ok {
+			menu = models.MenuItem{ID: line.ProductID, Name: line.Name, Price: line.UnitPrice}
+		} else if menuErr != nil {
+			continue
 		}
 		topItems = append(topItems, models.PopularItem{
 			Quantity:    quantities[i].Quantity,
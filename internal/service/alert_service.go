@@ -0,0 +1,161 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hot-coffee1/internal/dal"
+	"hot-coffee1/models"
+	"log/slog"
+	"slices"
+	"sync"
+	"time"
+)
+
+var ErrAlertNotRead = errors.New("alerts were not read")
+
+const (
+	AlertLowStock   = "low_stock"
+	AlertOutOfStock = "out_of_stock"
+)
+
+const (
+	AlertOpen         = "open"
+	AlertAcknowledged = "acknowledged"
+	AlertResolved     = "resolved"
+)
+
+// alertsMu keeps the evaluation of concurrent deductions from raising the
+// same alert twice.
+var alertsMu sync.Mutex
+
+type Alerts struct{}
+
+type AlertService interface {
+	GetAlerts(status, ingredientID string) ([]models.Alert, error)
+	AcknowledgeAlert(ctx context.Context, id string) (models.Alert, error)
+}
+
+func NewAlertService() AlertService {
+	return &Alerts{}
+}
+
+// GetAlerts returns alerts newest first. status "active" matches open and
+// acknowledged alerts.
+func (a *Alerts) GetAlerts(status, ingredientID string) ([]models.Alert, error) {
+	alerts, err := dal.NewAlertRepository().ReadAlerts()
+	if err != nil {
+		return nil, errors.Join(ErrAlertNotRead, err)
+	}
+
+	result := []models.Alert{}
+	for i := len(alerts) - 1; i >= 0; i-- {
+		alert := alerts[i]
+		if ingredientID != "" && alert.IngredientID != ingredientID {
+			continue
+		}
+		if status == "active" && alert.Status == AlertResolved || status != "" && status != "active" && alert.Status != status {
+			continue
+		}
+		result = append(result, alert)
+	}
+	return result, nil
+}
+
+func (a *Alerts) AcknowledgeAlert(ctx context.Context, id string) (models.Alert, error) {
+	alertsMu.Lock()
+	defer alertsMu.Unlock()
+
+	repo := dal.NewAlertRepository()
+	alerts, err := repo.ReadAlerts()
+	if err != nil {
+		return models.Alert{}, errors.Join(ErrAlertNotRead, err)
+	}
+	index := slices.IndexFunc(alerts, func(alert models.Alert) bool { return alert.ID == id })
+	if index < 0 {
+		return models.Alert{}, fmt.Errorf("alert %s %w", id, ErrNotFound)
+	}
+	if alerts[index].Status != AlertOpen {
+		return models.Alert{}, fmt.Errorf("%w: alert %s is already %s", ErrConflict, id, alerts[index].Status)
+	}
+
+	before := alerts[index]
+	alerts[index].Status = AlertAcknowledged
+	alerts[index].AcknowledgedAt = time.Now().Format(time.DateTime)
+	alerts[index].AcknowledgedBy = actorName(ctx)
+	if err := repo.WriteAlerts(alerts); err != nil {
+		return models.Alert{}, err
+	}
+	recordAudit(ctx, "alert", id, "acknowledge", before, alerts[index])
+	return alerts[index], nil
+}
+
+func stockLevel(item models.InventoryItem) string {
+	switch {
+	case item.Quantity <= 0:
+		return AlertOutOfStock
+	case item.LowStockThreshold > 0 && item.Quantity <= item.LowStockThreshold:
+		return AlertLowStock
+	}
+	return ""
+}
+
+// evaluateStockAlerts runs after every stock change. An ingredient has at
+// most one active alert: it is kept while the level stays the same,
+// resolved when stock recovers and replaced when the level changes. Only
+// new alerts are sent to the notifiers.
+func evaluateStockAlerts(item models.InventoryItem) {
+	alertsMu.Lock()
+	defer alertsMu.Unlock()
+
+	repo := dal.NewAlertRepository()
+	alerts, err := repo.ReadAlerts()
+	if err != nil {
+		recordAlertError(item, err)
+		return
+	}
+
+	level := stockLevel(item)
+	now := time.Now().Format(time.DateTime)
+	changed := false
+	for i := range alerts {
+		if alerts[i].IngredientID != item.IngredientID || alerts[i].Status == AlertResolved {
+			continue
+		}
+		if alerts[i].Kind == level {
+			return
+		}
+		alerts[i].Status, alerts[i].ResolvedAt = AlertResolved, now
+		changed = true
+	}
+
+	var raised *models.Alert
+	if level != "" {
+		alerts = append(alerts, models.Alert{
+			ID:           randomHex(6),
+			IngredientID: item.IngredientID,
+			Kind:         level,
+			Quantity:     item.Quantity,
+			Threshold:    item.LowStockThreshold,
+			Unit:         item.Unit,
+			Status:       AlertOpen,
+			CreatedAt:    now,
+		})
+		raised = &alerts[len(alerts)-1]
+		changed = true
+	}
+	if !changed {
+		return
+	}
+	if err := repo.WriteAlerts(alerts); err != nil {
+		recordAlertError(item, err)
+		return
+	}
+	if raised != nil {
+		notifyAlert(*raised, item)
+	}
+}
+
+func recordAlertError(item models.InventoryItem, err error) {
+	slog.Error("Failed to evaluate stock alerts", "ingredient", item.IngredientID, "error", err)
+}
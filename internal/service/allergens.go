@@ -0,0 +1,123 @@
+package service
+
+import (
+	"errors"
+	"hot-coffee1/internal/dal"
+	"hot-coffee1/models"
+	"slices"
+)
+
+// Allergens are the fourteen that EU food law requires us to declare.
+var Allergens = []string{
+	"celery", "crustaceans", "dairy", "eggs", "fish", "gluten", "lupin",
+	"molluscs", "mustard", "nuts", "peanuts", "sesame", "soy", "sulphites",
+}
+
+// dietaryExclusions lists, per dietary flag, the allergens an item carrying
+// that flag or tag must not contain.
+var dietaryExclusions = map[string][]string{
+	"vegan":       {"dairy", "eggs", "fish", "crustaceans", "molluscs"},
+	"vegetarian":  {"fish", "crustaceans", "molluscs"},
+	"gluten_free": {"gluten"},
+	"dairy_free":  {"dairy"},
+	"nut_free":    {"nuts", "peanuts"},
+}
+
+func isDietaryFlag(flag string) bool {
+	_, ok := dietaryExclusions[flag]
+	return ok
+}
+
+// menuAllergens derives the allergens of a menu item from its ingredients,
+// and the dietary flags every one of its ingredients carries. Ingredients
+// missing from the inventory contribute no flags.
+func menuAllergens(item models.MenuItem, inventory map[string]models.InventoryItem) (allergens, dietary []string) {
+	allergens, dietary = []string{}, []string{}
+	for j, ingredient := range item.Ingredients {
+		stock := inventory[ingredient.IngredientID]
+		for _, allergen := range stock.Allergens {
+			if !slices.Contains(allergens, allergen) {
+				allergens = append(allergens, allergen)
+			}
+		}
+		if j == 0 {
+			dietary = slices.Clone(stock.Dietary)
+		} else {
+			dietary = slices.DeleteFunc(dietary, func(flag string) bool { return !slices.Contains(stock.Dietary, flag) })
+		}
+	}
+	// An ingredient flagged wrongly cannot make the item claim a diet its
+	// allergens contradict.
+	dietary = slices.DeleteFunc(dietary, func(flag string) bool { return dietaryConflict(flag, allergens) != "" })
+	slices.Sort(allergens)
+	slices.Sort(dietary)
+	return allergens, dietary
+}
+
+// dietaryConflict returns the first allergen that rules out flag.
+func dietaryConflict(flag string, allergens []string) string {
+	for _, allergen := range dietaryExclusions[flag] {
+		if slices.Contains(allergens, allergen) {
+			return allergen
+		}
+	}
+	return ""
+}
+
+func validateAllergenFlags(v *ValidationError, item models.InventoryItem) {
+	for j, allergen := range item.Allergens {
+		if !slices.Contains(Allergens, allergen) {
+			v.Add(Pointer("allergens", j), "unknown allergen %s", allergen)
+		} else if slices.Index(item.Allergens, allergen) < j {
+			v.Add(Pointer("allergens", j), "duplicated allergen %s", allergen)
+		}
+	}
+	for j, flag := range item.Dietary {
+		if !isDietaryFlag(flag) {
+			v.Add(Pointer("dietary", j), "unknown dietary flag %s", flag)
+		} else if slices.Index(item.Dietary, flag) < j {
+			v.Add(Pointer("dietary", j), "duplicated dietary flag %s", flag)
+		} else if allergen := dietaryConflict(flag, item.Allergens); allergen != "" {
+			v.Add(Pointer("dietary", j), "%s ingredient cannot contain %s", flag, allergen)
+		}
+	}
+}
+
+// validateDietaryTags rejects dietary tags, such as "vegan", on menu items
+// whose ingredients contain an allergen the diet excludes.
+func validateDietaryTags(v *ValidationError, item models.MenuItem, inventory map[string]models.InventoryItem) {
+	allergens, _ := menuAllergens(item, inventory)
+	for j, tag := range item.Tags {
+		if allergen := dietaryConflict(tag, allergens); allergen != "" {
+			v.Add(Pointer("tags", j), "item tagged %s contains %s", tag, allergen)
+		}
+	}
+}
+
+// validateIngredientDiets keeps a changed ingredient from contradicting the
+// dietary tags of the menu items that use it.
+func validateIngredientDiets(item models.InventoryItem) error {
+	menu, err := dal.NewMenuRepository().ReadMenu()
+	if err != nil {
+		return errors.Join(ErrMenuNotRead, err)
+	}
+	inventory, err := readInventoryByID()
+	if err != nil {
+		return err
+	}
+	inventory[item.IngredientID] = item
+
+	v := &ValidationError{}
+	for _, menuItem := range menu {
+		if !slices.ContainsFunc(menuItem.Ingredients, func(ingredient models.MenuItemIngredient) bool { return ingredient.IngredientID == item.IngredientID }) {
+			continue
+		}
+		allergens, _ := menuAllergens(menuItem, inventory)
+		for _, tag := range menuItem.Tags {
+			if allergen := dietaryConflict(tag, allergens); allergen != "" {
+				v.Add(Pointer("allergens"), "menu item %s is tagged %s and would contain %s", menuItem.ID, tag, allergen)
+			}
+		}
+	}
+	return v.Err()
+}
@@ -0,0 +1,276 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hot-coffee1/internal/config"
+	"hot-coffee1/internal/dal"
+	"hot-coffee1/models"
+	"log/slog"
+	"slices"
+	"sort"
+	"sync"
+	"time"
+)
+
+var ErrAuditNotRead = errors.New("audit log was not read")
+
+const (
+	AuditCreate = "create"
+	AuditModify = "modify"
+	AuditDelete = "delete"
+	AuditClose  = "close"
+	AuditDeduct = "deduct"
+)
+
+// auditMu keeps seq and prev_hash consistent between concurrent appends.
+var auditMu sync.Mutex
+
+type AuditFilter struct {
+	Entity   string
+	EntityID string
+	Actor    string
+	Action   string
+	From     string
+	To       string
+}
+
+type AuditVerification struct {
+	Valid    bool   `json:"valid"`
+	Entries  int    `json:"entries"`
+	BrokenAt int64  `json:"broken_at,omitempty"`
+	Reason   string `json:"reason,omitempty"`
+}
+
+type Audit struct{}
+
+type AuditService interface {
+	GetAuditLog(filter AuditFilter) ([]models.AuditEntry, error)
+	VerifyAuditLog() (AuditVerification, error)
+}
+
+func NewAuditService() AuditService {
+	return &Audit{}
+}
+
+func (a *Audit) GetAuditLog(filter AuditFilter) ([]models.AuditEntry, error) {
+	v := &ValidationError{}
+	from, err := parseAuditBound(filter.From, false)
+	if err != nil {
+		v.Add(Pointer("from"), "%s", err.Error())
+	}
+	to, err := parseAuditBound(filter.To, true)
+	if err != nil {
+		v.Add(Pointer("to"), "%s", err.Error())
+	}
+	if err := v.Err(); err != nil {
+		return nil, err
+	}
+
+	entries, err := dal.NewAuditRepository().ReadAudit()
+	if err != nil {
+		return nil, errors.Join(ErrAuditNotRead, err)
+	}
+
+	result := []models.AuditEntry{}
+	for _, entry := range entries {
+		if filter.Entity != "" && entry.Entity != filter.Entity ||
+			filter.EntityID != "" && entry.EntityID != filter.EntityID ||
+			filter.Actor != "" && entry.Actor != filter.Actor ||
+			filter.Action != "" && entry.Action != filter.Action {
+			continue
+		}
+		at, err := time.ParseInLocation(time.DateTime, entry.Timestamp, time.Local)
+		if err != nil {
+			continue
+		}
+		if !from.IsZero() && at.Before(from) || !to.IsZero() && !at.Before(to) {
+			continue
+		}
+		result = append(result, entry)
+	}
+	return result, nil
+}
+
+// VerifyAuditLog walks the hash chain and reports the first entry that was
+// altered, removed or inserted out of order.
+func (a *Audit) VerifyAuditLog() (AuditVerification, error) {
+	entries, err := dal.NewAuditRepository().ReadAudit()
+	if err != nil {
+		return AuditVerification{}, errors.Join(ErrAuditNotRead, err)
+	}
+
+	prevHash := ""
+	for i, entry := range entries {
+		result := AuditVerification{Entries: len(entries), BrokenAt: entry.Seq}
+		switch {
+		case entry.Seq != int64(i+1):
+			result.Reason = fmt.Sprintf("expected seq %d, found %d", i+1, entry.Seq)
+		case entry.PrevHash != prevHash:
+			result.Reason = "prev_hash does not match the previous entry"
+		case !hmac.Equal([]byte(entry.Hash), []byte(hashAuditEntry(entry))):
+			result.Reason = "hash does not match the entry contents"
+		}
+		if result.Reason != "" {
+			return result, nil
+		}
+		prevHash = entry.Hash
+	}
+	return AuditVerification{Valid: true, Entries: len(entries)}, nil
+}
+
+// recordAudit appends a mutation to the audit log. before and after are the
+// entity as it was and as it is now; either is nil for creates and deletes.
+// The mutation has already been saved, so a failure here is only logged.
+func recordAudit(ctx context.Context, entity, id, action string, before, after any) {
+	if err := appendAudit(ctx, entity, id, action, before, after); err != nil {
+		slog.Error("Failed to write audit entry", "entity", entity, "ID", id, "action", action, "error", err)
+	}
+}
+
+func appendAudit(ctx context.Context, entity, id, action string, before, after any) error {
+	entry := models.AuditEntry{
+		Timestamp: time.Now().Format(time.DateTime),
+		Actor:     actorName(ctx),
+		Entity:    entity,
+		EntityID:  id,
+		Action:    action,
+	}
+
+	var err error
+	if before != nil {
+		if entry.Before, err = json.Marshal(before); err != nil {
+			return err
+		}
+	}
+	if after != nil {
+		if entry.After, err = json.Marshal(after); err != nil {
+			return err
+		}
+	}
+	if entry.Changes, err = diffJSON(entry.Before, entry.After); err != nil {
+		return err
+	}
+
+	auditMu.Lock()
+	defer auditMu.Unlock()
+
+	repo := dal.NewAuditRepository()
+	entries, err := repo.ReadAudit()
+	if err != nil {
+		return err
+	}
+	entry.Seq = 1
+	if len(entries) > 0 {
+		last := entries[len(entries)-1]
+		entry.Seq, entry.PrevHash = last.Seq+1, last.Hash
+	}
+	entry.Hash = hashAuditEntry(entry)
+	return repo.AppendAudit(entry)
+}
+
+// hashAuditEntry signs an entry with the server secret, so that only the
+// server can write a chain that verifies.
+func hashAuditEntry(entry models.AuditEntry) string {
+	entry.Hash = ""
+	data, _ := json.Marshal(entry)
+	mac := hmac.New(sha256.New, config.GetAuthSecret())
+	mac.Write(data)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// diffJSON lists every leaf that differs between two JSON documents, keyed
+// by its JSON pointer.
+func diffJSON(before, after json.RawMessage) ([]models.AuditChange, error) {
+	oldLeaves, newLeaves := map[string]json.RawMessage{}, map[string]json.RawMessage{}
+	if err := flattenJSON(before, "", oldLeaves); err != nil {
+		return nil, err
+	}
+	if err := flattenJSON(after, "", newLeaves); err != nil {
+		return nil, err
+	}
+
+	fields := make([]string, 0, len(oldLeaves)+len(newLeaves))
+	for field := range oldLeaves {
+		fields = append(fields, field)
+	}
+	for field := range newLeaves {
+		if _, ok := oldLeaves[field]; !ok {
+			fields = append(fields, field)
+		}
+	}
+	sort.Strings(fields)
+
+	var changes []models.AuditChange
+	for _, field := range fields {
+		if !bytes.Equal(oldLeaves[field], newLeaves[field]) {
+			changes = append(changes, models.AuditChange{Field: field, Before: oldLeaves[field], After: newLeaves[field]})
+		}
+	}
+	return changes, nil
+}
+
+func flattenJSON(data json.RawMessage, prefix string, leaves map[string]json.RawMessage) error {
+	if len(data) == 0 {
+		return nil
+	}
+
+	var object map[string]json.RawMessage
+	if json.Unmarshal(data, &object) == nil && len(object) > 0 {
+		keys := make([]string, 0, len(object))
+		for key := range object {
+			keys = append(keys, key)
+		}
+		slices.Sort(keys)
+		for _, key := range keys {
+			if err := flattenJSON(object[key], prefix+Pointer(key), leaves); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var array []json.RawMessage
+	if json.Unmarshal(data, &array) == nil && len(array) > 0 {
+		for i, element := range array {
+			if err := flattenJSON(element, prefix+Pointer(i), leaves); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+
+	var compact bytes.Buffer
+	if err := json.Compact(&compact, data); err != nil {
+		return err
+	}
+	if prefix == "" {
+		prefix = "/"
+	}
+	leaves[prefix] = compact.Bytes()
+	return nil
+}
+
+func parseAuditBound(value string, upper bool) (time.Time, error) {
+	if value == "" {
+		return time.Time{}, nil
+	}
+	if t, err := time.ParseInLocation(time.DateOnly, value, time.Local); err == nil {
+		if upper {
+			t = t.AddDate(0, 0, 1)
+		}
+		return t, nil
+	}
+	for _, layout := range []string{time.DateTime, time.RFC3339} {
+		if t, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return t, nil
+		}
+	}
+	return time.Time{}, fmt.Errorf("%q is not a date (YYYY-MM-DD), date-time (YYYY-MM-DD HH:MM:SS) or RFC 3339 timestamp", value)
+}
@@ -0,0 +1,522 @@
+package service
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hot-coffee1/internal/config"
+	"hot-coffee1/internal/dal"
+	"hot-coffee1/models"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrUnauthorized       = errors.New("authentication required")
+	ErrInvalidCredentials = errors.New("invalid credentials")
+	ErrAuthNotRead        = errors.New("auth storage was not read")
+)
+
+const (
+	passwordIterations = 210000
+	apiKeyPrefix       = "hc"
+)
+
+// Revoked session IDs are kept until the token would have expired anyway.
+var (
+	revokedMu       sync.Mutex
+	revokedSessions = map[string]int64{}
+)
+
+// authMu guards the cached users and keys, which every authenticated
+// request reloads.
+var authMu sync.Mutex
+
+type Auth struct {
+	cacheUsers []models.User
+	cacheKeys  []models.APIKey
+}
+
+type AuthService interface {
+	Login(username, password string) (Session, error)
+	Logout(token string) error
+	Authenticate(credentials string) (Principal, error)
+	GetAllUsers() ([]models.User, error)
+	AddNewUser(ctx context.Context, username, password, role string) error
+	ChangePassword(ctx context.Context, username, password string) error
+	ChangeRole(ctx context.Context, username, role string) error
+	DeleteUser(ctx context.Context, username string) error
+	GetAllAPIKeys() ([]models.APIKey, error)
+	CreateAPIKey(ctx context.Context, name, role string) (string, models.APIKey, error)
+	RevokeAPIKey(ctx context.Context, id string) error
+}
+
+type Session struct {
+	Token     string `json:"token"`
+	ExpiresAt string `json:"expires_at"`
+}
+
+type sessionClaims struct {
+	Subject   string `json:"sub"`
+	SessionID string `json:"sid"`
+	IssuedAt  int64  `json:"iat"`
+	ExpiresAt int64  `json:"exp"`
+}
+
+func NewAuthService() AuthService {
+	return &Auth{}
+}
+
+func (a *Auth) loadUsers() error {
+	users, err := dal.NewUserRepository().ReadUsers()
+	if err != nil {
+		return errors.Join(ErrAuthNotRead, err)
+	}
+	a.cacheUsers = users
+	return nil
+}
+
+func (a *Auth) loadKeys() error {
+	keys, err := dal.NewAPIKeyRepository().ReadAPIKeys()
+	if err != nil {
+		return errors.Join(ErrAuthNotRead, err)
+	}
+	a.cacheKeys = keys
+	return nil
+}
+
+// lookupUser reloads the users and returns a copy of one, so that slow
+// password checks run without authMu.
+func (a *Auth) lookupUser(username string) (models.User, bool, error) {
+	authMu.Lock()
+	defer authMu.Unlock()
+
+	if err := a.loadUsers(); err != nil {
+		return models.User{}, false, err
+	}
+	index := a.findUser(username)
+	if index < 0 {
+		return models.User{}, false, nil
+	}
+	return a.cacheUsers[index], true, nil
+}
+
+func (a *Auth) findUser(username string) int {
+	for i, user := range a.cacheUsers {
+		if user.Username == username {
+			return i
+		}
+	}
+	return -1
+}
+
+func (a *Auth) Login(username, password string) (Session, error) {
+	user, found, err := a.lookupUser(username)
+	if err != nil {
+		return Session{}, err
+	}
+	if !found {
+		// Spend the same time as a real check so usernames cannot be probed.
+		verifyPassword(password, "pbkdf2-sha256$"+strconv.Itoa(passwordIterations)+"$AAAA$AAAA")
+		return Session{}, ErrInvalidCredentials
+	}
+	if !verifyPassword(password, user.PasswordHash) {
+		return Session{}, ErrInvalidCredentials
+	}
+
+	now := time.Now()
+	claims := sessionClaims{
+		Subject:   username,
+		SessionID: randomHex(12),
+		IssuedAt:  now.Unix(),
+		ExpiresAt: now.Add(config.GetSessionTTL()).Unix(),
+	}
+	token, err := signSession(claims)
+	if err != nil {
+		return Session{}, err
+	}
+	return Session{Token: token, ExpiresAt: time.Unix(claims.ExpiresAt, 0).UTC().Format(time.RFC3339)}, nil
+}
+
+func (a *Auth) Logout(token string) error {
+	claims, err := verifySession(token)
+	if err != nil {
+		return err
+	}
+	revokedMu.Lock()
+	defer revokedMu.Unlock()
+	now := time.Now().Unix()
+	for id, exp := range revokedSessions {
+		if exp < now {
+			delete(revokedSessions, id)
+		}
+	}
+	revokedSessions[claims.SessionID] = claims.ExpiresAt
+	return nil
+}
+
+// Authenticate accepts the value of an Authorization header: either
+// "Bearer <session token>" or "ApiKey <key>".
+func (a *Auth) Authenticate(credentials string) (Principal, error) {
+	scheme, value, ok := strings.Cut(strings.TrimSpace(credentials), " ")
+	if !ok || value == "" {
+		return Principal{}, ErrUnauthorized
+	}
+	value = strings.TrimSpace(value)
+
+	switch strings.ToLower(scheme) {
+	case "bearer":
+		claims, err := verifySession(value)
+		if err != nil {
+			return Principal{}, err
+		}
+		user, found, err := a.lookupUser(claims.Subject)
+		if err != nil {
+			return Principal{}, err
+		} else if !found {
+			return Principal{}, fmt.Errorf("%w: user no longer exists", ErrUnauthorized)
+		}
+		// The role is read on every request so demotions apply immediately.
+		return Principal{Kind: PrincipalUser, ID: claims.Subject, Name: claims.Subject, Role: user.Role}, nil
+	case "apikey":
+		return a.authenticateAPIKey(value)
+	}
+	return Principal{}, fmt.Errorf("%w: unsupported authorization scheme %q", ErrUnauthorized, scheme)
+}
+
+func (a *Auth) authenticateAPIKey(key string) (Principal, error) {
+	authMu.Lock()
+	defer authMu.Unlock()
+
+	parts := strings.SplitN(key, "_", 3)
+	if len(parts) != 3 || parts[0] != apiKeyPrefix {
+		return Principal{}, ErrInvalidCredentials
+	}
+	if err := a.loadKeys(); err != nil {
+		return Principal{}, err
+	}
+	for _, stored := range a.cacheKeys {
+		if stored.ID != parts[1] {
+			continue
+		}
+		if subtle.ConstantTimeCompare([]byte(stored.Hash), []byte(hashAPIKey(key))) == 1 {
+			return Principal{Kind: PrincipalAPIKey, ID: stored.ID, Name: stored.Name, Role: stored.Role}, nil
+		}
+	}
+	return Principal{}, ErrInvalidCredentials
+}
+
+func (a *Auth) GetAllUsers() ([]models.User, error) {
+	authMu.Lock()
+	defer authMu.Unlock()
+
+	if err := a.loadUsers(); err != nil {
+		return nil, err
+	}
+	return slices.Clone(a.cacheUsers), nil
+}
+
+func (a *Auth) AddNewUser(ctx context.Context, username, password, role string) error {
+	authMu.Lock()
+	defer authMu.Unlock()
+
+	if err := a.loadUsers(); err != nil {
+		return err
+	}
+	v := &ValidationError{}
+	if username == "" {
+		v.Add(Pointer("username"), "username cannot be empty")
+	}
+	validatePassword(v, password)
+	validateRole(v, role)
+	if err := v.Err(); err != nil {
+		return err
+	}
+	if a.findUser(username) >= 0 {
+		return ErrConflict
+	}
+
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	user := models.User{
+		Username:     username,
+		PasswordHash: hash,
+		Role:         role,
+		CreatedAt:    time.Now().Format(time.DateTime),
+	}
+	a.cacheUsers = append(a.cacheUsers, user)
+	if err := dal.NewUserRepository().WriteUsers(a.cacheUsers); err != nil {
+		return err
+	}
+	recordAudit(ctx, "user", username, AuditCreate, nil, auditedUser(user))
+	return nil
+}
+
+func (a *Auth) ChangePassword(ctx context.Context, username, password string) error {
+	authMu.Lock()
+	defer authMu.Unlock()
+
+	if err := a.loadUsers(); err != nil {
+		return err
+	}
+	index := a.findUser(username)
+	if index < 0 {
+		return fmt.Errorf("user %s %w", username, ErrNotFound)
+	}
+	v := &ValidationError{}
+	validatePassword(v, password)
+	if err := v.Err(); err != nil {
+		return err
+	}
+
+	hash, err := hashPassword(password)
+	if err != nil {
+		return err
+	}
+	a.cacheUsers[index].PasswordHash = hash
+	if err := dal.NewUserRepository().WriteUsers(a.cacheUsers); err != nil {
+		return err
+	}
+	recordAudit(ctx, "user", username, "change_password", nil, nil)
+	return nil
+}
+
+func (a *Auth) ChangeRole(ctx context.Context, username, role string) error {
+	authMu.Lock()
+	defer authMu.Unlock()
+
+	if err := a.loadUsers(); err != nil {
+		return err
+	}
+	index := a.findUser(username)
+	if index < 0 {
+		return fmt.Errorf("user %s %w", username, ErrNotFound)
+	}
+	v := &ValidationError{}
+	validateRole(v, role)
+	if err := v.Err(); err != nil {
+		return err
+	}
+	if a.cacheUsers[index].Role == role {
+		return ErrNothingToModify
+	}
+
+	before := auditedUser(a.cacheUsers[index])
+	a.cacheUsers[index].Role = role
+	if err := dal.NewUserRepository().WriteUsers(a.cacheUsers); err != nil {
+		return err
+	}
+	recordAudit(ctx, "user", username, AuditModify, before, auditedUser(a.cacheUsers[index]))
+	return nil
+}
+
+func (a *Auth) DeleteUser(ctx context.Context, username string) error {
+	authMu.Lock()
+	defer authMu.Unlock()
+
+	if err := a.loadUsers(); err != nil {
+		return err
+	}
+	index := a.findUser(username)
+	if index < 0 {
+		return fmt.Errorf("user %s %w", username, ErrNotFound)
+	}
+	deleted := auditedUser(a.cacheUsers[index])
+	a.cacheUsers = append(a.cacheUsers[:index], a.cacheUsers[index+1:]...)
+	if err := dal.NewUserRepository().WriteUsers(a.cacheUsers); err != nil {
+		return err
+	}
+	recordAudit(ctx, "user", username, AuditDelete, deleted, nil)
+	return nil
+}
+
+func (a *Auth) GetAllAPIKeys() ([]models.APIKey, error) {
+	authMu.Lock()
+	defer authMu.Unlock()
+
+	if err := a.loadKeys(); err != nil {
+		return nil, err
+	}
+	return slices.Clone(a.cacheKeys), nil
+}
+
+// CreateAPIKey returns the plaintext key exactly once; only its hash is
+// stored.
+func (a *Auth) CreateAPIKey(ctx context.Context, name, role string) (string, models.APIKey, error) {
+	authMu.Lock()
+	defer authMu.Unlock()
+
+	if err := a.loadKeys(); err != nil {
+		return "", models.APIKey{}, err
+	}
+	v := &ValidationError{}
+	if name == "" {
+		v.Add(Pointer("name"), "name cannot be empty")
+	}
+	validateRole(v, role)
+	if err := v.Err(); err != nil {
+		return "", models.APIKey{}, err
+	}
+
+	id := randomHex(4)
+	plaintext := apiKeyPrefix + "_" + id + "_" + randomToken(24)
+	key := models.APIKey{
+		ID:        id,
+		Name:      name,
+		Hash:      hashAPIKey(plaintext),
+		Role:      role,
+		CreatedAt: time.Now().Format(time.DateTime),
+	}
+	a.cacheKeys = append(a.cacheKeys, key)
+	if err := dal.NewAPIKeyRepository().WriteAPIKeys(a.cacheKeys); err != nil {
+		return "", models.APIKey{}, err
+	}
+	recordAudit(ctx, "api_key", id, AuditCreate, nil, auditedAPIKey(key))
+	return plaintext, key, nil
+}
+
+func (a *Auth) RevokeAPIKey(ctx context.Context, id string) error {
+	authMu.Lock()
+	defer authMu.Unlock()
+
+	if err := a.loadKeys(); err != nil {
+		return err
+	}
+	for i, key := range a.cacheKeys {
+		if key.ID == id {
+			a.cacheKeys = append(a.cacheKeys[:i], a.cacheKeys[i+1:]...)
+			if err := dal.NewAPIKeyRepository().WriteAPIKeys(a.cacheKeys); err != nil {
+				return err
+			}
+			recordAudit(ctx, "api_key", id, AuditDelete, auditedAPIKey(key), nil)
+			return nil
+		}
+	}
+	return fmt.Errorf("api key %s %w", id, ErrNotFound)
+}
+
+// Credential hashes are kept out of the audit log.
+func auditedUser(user models.User) models.User {
+	user.PasswordHash = ""
+	return user
+}
+
+func auditedAPIKey(key models.APIKey) models.APIKey {
+	key.Hash = ""
+	return key
+}
+
+func validatePassword(v *ValidationError, password string) {
+	if len(password) < 8 {
+		v.Add(Pointer("password"), "password must be at least 8 characters long")
+	}
+}
+
+func hashPassword(password string) (string, error) {
+	salt := make([]byte, 16)
+	if _, err := rand.Read(salt); err != nil {
+		return "", err
+	}
+	key, err := pbkdf2.Key(sha256.New, password, salt, passwordIterations, 32)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("pbkdf2-sha256$%d$%s$%s", passwordIterations,
+		base64.RawStdEncoding.EncodeToString(salt), base64.RawStdEncoding.EncodeToString(key)), nil
+}
+
+func verifyPassword(password, encoded string) bool {
+	parts := strings.Split(encoded, "$")
+	if len(parts) != 4 || parts[0] != "pbkdf2-sha256" {
+		return false
+	}
+	iterations, err := strconv.Atoi(parts[1])
+	if err != nil || iterations < 1 {
+		return false
+	}
+	salt, err := base64.RawStdEncoding.DecodeString(parts[2])
+	if err != nil {
+		return false
+	}
+	want, err := base64.RawStdEncoding.DecodeString(parts[3])
+	if err != nil {
+		return false
+	}
+	got, err := pbkdf2.Key(sha256.New, password, salt, iterations, 32)
+	if err != nil {
+		return false
+	}
+	return subtle.ConstantTimeCompare(got, want) == 1 && len(want) > 0
+}
+
+func hashAPIKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return hex.EncodeToString(sum[:])
+}
+
+// Session tokens are base64url(claims) + "." + base64url(HMAC-SHA256).
+func signSession(claims sessionClaims) (string, error) {
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", err
+	}
+	encoded := base64.RawURLEncoding.EncodeToString(payload)
+	return encoded + "." + sign(encoded), nil
+}
+
+func verifySession(token string) (sessionClaims, error) {
+	var claims sessionClaims
+	encoded, signature, ok := strings.Cut(token, ".")
+	if !ok || !hmac.Equal([]byte(signature), []byte(sign(encoded))) {
+		return claims, fmt.Errorf("%w: invalid session token", ErrUnauthorized)
+	}
+	payload, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return claims, fmt.Errorf("%w: invalid session token", ErrUnauthorized)
+	}
+	if err := json.Unmarshal(payload, &claims); err != nil {
+		return claims, fmt.Errorf("%w: invalid session token", ErrUnauthorized)
+	}
+	if time.Now().Unix() >= claims.ExpiresAt {
+		return claims, fmt.Errorf("%w: session expired", ErrUnauthorized)
+	}
+
+	revokedMu.Lock()
+	_, revoked := revokedSessions[claims.SessionID]
+	revokedMu.Unlock()
+	if revoked {
+		return claims, fmt.Errorf("%w: session was logged out", ErrUnauthorized)
+	}
+	return claims, nil
+}
+
+func sign(data string) string {
+	mac := hmac.New(sha256.New, config.GetAuthSecret())
+	mac.Write([]byte(data))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func randomHex(n int) string {
+	buf := make([]byte, n)
+	rand.Read(buf)
+	return hex.EncodeToString(buf)
+}
+
+func randomToken(n int) string {
+	buf := make([]byte, n)
+	rand.Read(buf)
+	return base64.RawURLEncoding.EncodeToString(buf)
+}
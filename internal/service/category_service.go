@@ -0,0 +1,260 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"hot-coffee1/internal/dal"
+	"hot-coffee1/models"
+	"slices"
+)
+
+var ErrCategoryNotRead = errors.New("categories were not read")
+
+type Categories struct {
+	cacheCategories []models.Category
+}
+
+type CategoryService interface {
+	GetAllCategories() ([]models.Category, error)
+	GetCategoryByID(id string) (models.Category, error)
+	AddNewCategory(ctx context.Context, category models.Category) error
+	ModifyCategory(ctx context.Context, category models.Category) error
+	DeleteCategory(ctx context.Context, id string) error
+	GetMenuTree() (models.MenuTree, error)
+}
+
+func NewCategoryService() CategoryService {
+	return &Categories{cacheCategories: []models.Category{}}
+}
+
+func (c *Categories) loadCategories() error {
+	categories, err := dal.NewCategoryRepository().ReadCategories()
+	if err != nil {
+		return errors.Join(ErrCategoryNotRead, err)
+	}
+	c.cacheCategories = categories
+	return nil
+}
+
+func (c *Categories) findCategory(id string) int {
+	return slices.IndexFunc(c.cacheCategories, func(category models.Category) bool { return category.ID == id })
+}
+
+// GetAllCategories returns the categories in display order.
+func (c *Categories) GetAllCategories() ([]models.Category, error) {
+	if err := c.loadCategories(); err != nil {
+		return nil, err
+	}
+	categories := slices.Clone(c.cacheCategories)
+	slices.SortStableFunc(categories, compareCategories)
+	return categories, nil
+}
+
+func (c *Categories) GetCategoryByID(id string) (models.Category, error) {
+	if err := c.loadCategories(); err != nil {
+		return models.Category{}, err
+	}
+	index := c.findCategory(id)
+	if index < 0 {
+		return models.Category{}, fmt.Errorf("category %s %w", id, ErrNotFound)
+	}
+	return c.cacheCategories[index], nil
+}
+
+func (c *Categories) AddNewCategory(ctx context.Context, category models.Category) error {
+	if err := c.loadCategories(); err != nil {
+		return err
+	}
+	if c.findCategory(category.ID) >= 0 {
+		return fmt.Errorf("%w: category %s", ErrConflict, category.ID)
+	}
+	if err := validateCategory(category, c.cacheCategories); err != nil {
+		return err
+	}
+
+	c.cacheCategories = append(c.cacheCategories, category)
+	if err := dal.NewCategoryRepository().WriteCategories(c.cacheCategories); err != nil {
+		return err
+	}
+	recordAudit(ctx, "category", category.ID, AuditCreate, nil, category)
+	return nil
+}
+
+func (c *Categories) ModifyCategory(ctx context.Context, category models.Category) error {
+	if err := c.loadCategories(); err != nil {
+		return err
+	}
+	index := c.findCategory(category.ID)
+	if index < 0 {
+		return fmt.Errorf("category %s %w", category.ID, ErrNotFound)
+	}
+	if c.cacheCategories[index] == category {
+		return ErrNothingToModify
+	}
+
+	before := c.cacheCategories[index]
+	c.cacheCategories[index] = category
+	if err := validateCategory(category, c.cacheCategories); err != nil {
+		return err
+	}
+	if err := dal.NewCategoryRepository().WriteCategories(c.cacheCategories); err != nil {
+		return err
+	}
+	recordAudit(ctx, "category", category.ID, AuditModify, before, category)
+	return nil
+}
+
+// DeleteCategory only removes empty categories: subcategories and menu
+// items have to be moved or deleted first.
+func (c *Categories) DeleteCategory(ctx context.Context, id string) error {
+	if err := c.loadCategories(); err != nil {
+		return err
+	}
+	index := c.findCategory(id)
+	if index < 0 {
+		return fmt.Errorf("category %s %w", id, ErrNotFound)
+	}
+	for _, category := range c.cacheCategories {
+		if category.ParentID == id {
+			return fmt.Errorf("%w: category %s has subcategory %s", ErrConflict, id, category.ID)
+		}
+	}
+	menu, err := NewMenuService().GetAllMenu()
+	if err != nil {
+		return err
+	}
+	for _, item := range menu {
+		if item.CategoryID == id {
+			return fmt.Errorf("%w: category %s still has menu item %s", ErrConflict, id, item.ID)
+		}
+	}
+
+	deleted := c.cacheCategories[index]
+	c.cacheCategories = slices.Delete(c.cacheCategories, index, index+1)
+	if err := dal.NewCategoryRepository().WriteCategories(c.cacheCategories); err != nil {
+		return err
+	}
+	recordAudit(ctx, "category", id, AuditDelete, deleted, nil)
+	return nil
+}
+
+// GetMenuTree nests categories under their parents and puts every menu item,
+// with its availability, under its category. Categories are sorted by
+// sort_order and items by display_order, both falling back to the name.
+func (c *Categories) GetMenuTree() (models.MenuTree, error) {
+	categories, err := c.GetAllCategories()
+	if err != nil {
+		return models.MenuTree{}, err
+	}
+	menu, err := NewMenuService().GetMenuAvailability(MenuFilter{})
+	if err != nil {
+		return models.MenuTree{}, err
+	}
+	sortMenuItems(menu)
+
+	tree := models.MenuTree{Categories: []models.MenuTreeNode{}, Uncategorized: []models.MenuItem{}}
+	for _, item := range menu {
+		if item.CategoryID == "" {
+			tree.Uncategorized = append(tree.Uncategorized, item)
+		}
+	}
+	tree.Categories = menuTreeNodes("", categories, menu)
+	return tree, nil
+}
+
+func menuTreeNodes(parentID string, categories []models.Category, menu []models.MenuItem) []models.MenuTreeNode {
+	nodes := []models.MenuTreeNode{}
+	for _, category := range categories {
+		if category.ParentID != parentID {
+			continue
+		}
+		node := models.MenuTreeNode{Category: category, Items: []models.MenuItem{}}
+		for _, item := range menu {
+			if item.CategoryID == category.ID {
+				node.Items = append(node.Items, item)
+			}
+		}
+		node.Subcategories = menuTreeNodes(category.ID, categories, menu)
+		nodes = append(nodes, node)
+	}
+	return nodes
+}
+
+// categoryWithSubcategories returns id and the IDs of every category nested
+// below it.
+func categoryWithSubcategories(id string) (map[string]bool, error) {
+	categories, err := dal.NewCategoryRepository().ReadCategories()
+	if err != nil {
+		return nil, errors.Join(ErrCategoryNotRead, err)
+	}
+	if !slices.ContainsFunc(categories, func(category models.Category) bool { return category.ID == id }) {
+		return nil, fmt.Errorf("category %s %w", id, ErrNotFound)
+	}
+
+	ids := map[string]bool{id: true}
+	for added := true; added; {
+		added = false
+		for _, category := range categories {
+			if ids[category.ParentID] && !ids[category.ID] {
+				ids[category.ID], added = true, true
+			}
+		}
+	}
+	return ids, nil
+}
+
+func validateCategory(category models.Category, categories []models.Category) error {
+	v := &ValidationError{}
+	if category.ID == "" {
+		v.Add(Pointer("category_id"), "category ID cannot be empty")
+	}
+	if category.Name == "" {
+		v.Add(Pointer("name"), "name cannot be empty")
+	}
+	if category.ParentID == "" {
+		return v.Err()
+	}
+
+	parents := make(map[string]string, len(categories))
+	for _, c := range categories {
+		parents[c.ID] = c.ParentID
+	}
+	if _, exists := parents[category.ParentID]; !exists {
+		v.Add(Pointer("parent_id"), "parent category %s does not exist", category.ParentID)
+		return v.Err()
+	}
+	// Walking up from the new parent must never reach the category itself.
+	for parent, depth := category.ParentID, 0; parent != "" && depth <= len(categories); parent, depth = parents[parent], depth+1 {
+		if parent == category.ID {
+			v.Add(Pointer("parent_id"), "category %s cannot be nested inside itself", category.ID)
+			break
+		}
+	}
+	return v.Err()
+}
+
+func validateMenuCategory(v *ValidationError, item models.MenuItem) error {
+	if item.CategoryID == "" {
+		return nil
+	}
+	categories, err := dal.NewCategoryRepository().ReadCategories()
+	if err != nil {
+		return errors.Join(ErrCategoryNotRead, err)
+	}
+	if !slices.ContainsFunc(categories, func(category models.Category) bool { return category.ID == item.CategoryID }) {
+		v.Add(Pointer("category_id"), "category %s does not exist", item.CategoryID)
+	}
+	return nil
+}
+
+func compareCategories(a, b models.Category) int {
+	return cmp.Or(cmp.Compare(a.SortOrder, b.SortOrder), cmp.Compare(a.Name, b.Name))
+}
+
+func sortMenuItems(menu []models.MenuItem) {
+	slices.SortStableFunc(menu, func(a, b models.MenuItem) int {
+		return cmp.Or(cmp.Compare(a.DisplayOrder, b.DisplayOrder), cmp.Compare(a.Name, b.Name))
+	})
+}
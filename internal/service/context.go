@@ -0,0 +1,43 @@
+package service
+
+import "context"
+
+const (
+	PrincipalUser   = "user"
+	PrincipalAPIKey = "api_key"
+	PrincipalCLI    = "cli"
+)
+
+// Principal is whoever a request was authenticated as: a staff member
+// logged in with a session token or a device using an API key. Management
+// commands run from the shell act as a cli principal.
+type Principal struct {
+	Kind string `json:"kind"`
+	Name string `json:"name"`
+	ID   string `json:"id"`
+	Role string `json:"role"`
+}
+
+func (p Principal) String() string {
+	return p.Kind + ":" + p.ID
+}
+
+type principalKey struct{}
+
+func WithPrincipal(ctx context.Context, p Principal) context.Context {
+	return context.WithValue(ctx, principalKey{}, p)
+}
+
+func PrincipalFrom(ctx context.Context) (Principal, bool) {
+	p, ok := ctx.Value(principalKey{}).(Principal)
+	return p, ok
+}
+
+// actorName is how changes made under ctx are attributed; work the server
+// does on its own counts as "system".
+func actorName(ctx context.Context) string {
+	if principal, ok := PrincipalFrom(ctx); ok {
+		return principal.String()
+	}
+	return "system"
+}
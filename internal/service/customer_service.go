@@ -0,0 +1,303 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"hot-coffee1/internal/dal"
+	"hot-coffee1/models"
+	"net/mail"
+	"slices"
+	"strings"
+	"time"
+)
+
+var ErrCustomerNotRead = errors.New("customers were not read")
+
+type Customers struct {
+	cacheCustomers []models.Customer
+}
+
+type CustomerService interface {
+	GetAllCustomers(query string) ([]models.Customer, error)
+	GetCustomerByID(id string) (models.Customer, error)
+	AddNewCustomer(ctx context.Context, customer models.Customer) (models.Customer, error)
+	ModifyCustomer(ctx context.Context, customer models.Customer) (models.Customer, error)
+	DeleteCustomer(ctx context.Context, id string) error
+	GetCustomerOrders(id string) ([]models.Order, error)
+	ReorderLast(ctx context.Context, id, orderID string) (models.Order, error)
+}
+
+func NewCustomerService() CustomerService {
+	return &Customers{cacheCustomers: []models.Customer{}}
+}
+
+func (c *Customers) loadCustomers() error {
+	customers, err := dal.NewCustomerRepository().ReadCustomers()
+	if err != nil {
+		return errors.Join(ErrCustomerNotRead, err)
+	}
+	c.cacheCustomers = customers
+	return nil
+}
+
+func (c *Customers) findCustomer(id string) int {
+	return slices.IndexFunc(c.cacheCustomers, func(customer models.Customer) bool { return customer.ID == id })
+}
+
+// GetAllCustomers returns customers by name. query matches part of the
+// name, the phone number or the email.
+func (c *Customers) GetAllCustomers(query string) ([]models.Customer, error) {
+	if err := c.loadCustomers(); err != nil {
+		return nil, err
+	}
+	query = strings.ToLower(strings.TrimSpace(query))
+	customers := []models.Customer{}
+	for _, customer := range c.cacheCustomers {
+		if query != "" &&
+			!strings.Contains(strings.ToLower(customer.Name), query) &&
+			!strings.Contains(customer.Email, query) &&
+			(normalizePhone(query) == "" || !strings.Contains(customer.Phone, normalizePhone(query))) {
+			continue
+		}
+		customers = append(customers, customer)
+	}
+	slices.SortStableFunc(customers, func(a, b models.Customer) int {
+		return cmp.Or(cmp.Compare(strings.ToLower(a.Name), strings.ToLower(b.Name)), cmp.Compare(a.ID, b.ID))
+	})
+	return customers, nil
+}
+
+func (c *Customers) GetCustomerByID(id string) (models.Customer, error) {
+	if err := c.loadCustomers(); err != nil {
+		return models.Customer{}, err
+	}
+	index := c.findCustomer(id)
+	if index < 0 {
+		return models.Customer{}, fmt.Errorf("customer %s %w", id, ErrNotFound)
+	}
+	return c.cacheCustomers[index], nil
+}
+
+func (c *Customers) AddNewCustomer(ctx context.Context, customer models.Customer) (models.Customer, error) {
+	if err := c.loadCustomers(); err != nil {
+		return models.Customer{}, err
+	}
+	if customer.ID == "" {
+		customer.ID = randomHex(6)
+	}
+	if c.findCustomer(customer.ID) >= 0 {
+		return models.Customer{}, fmt.Errorf("%w: customer %s", ErrConflict, customer.ID)
+	}
+	customer.CreatedAt = time.Now().Format(time.DateTime)
+	customer, err := validateCustomer(customer, c.cacheCustomers)
+	if err != nil {
+		return models.Customer{}, err
+	}
+
+	c.cacheCustomers = append(c.cacheCustomers, customer)
+	if err := dal.NewCustomerRepository().WriteCustomers(c.cacheCustomers); err != nil {
+		return models.Customer{}, err
+	}
+	recordAudit(ctx, "customer", customer.ID, AuditCreate, nil, customer)
+	return customer, nil
+}
+
+func (c *Customers) ModifyCustomer(ctx context.Context, customer models.Customer) (models.Customer, error) {
+	if err := c.loadCustomers(); err != nil {
+		return models.Customer{}, err
+	}
+	index := c.findCustomer(customer.ID)
+	if index < 0 {
+		return models.Customer{}, fmt.Errorf("customer %s %w", customer.ID, ErrNotFound)
+	}
+	before := c.cacheCustomers[index]
+	customer.CreatedAt = before.CreatedAt
+
+	others := slices.Delete(slices.Clone(c.cacheCustomers), index, index+1)
+	customer, err := validateCustomer(customer, others)
+	if err != nil {
+		return models.Customer{}, err
+	}
+	if customer.Name == before.Name && customer.Phone == before.Phone && customer.Email == before.Email &&
+		customer.Notes == before.Notes && slices.Equal(customer.Preferences, before.Preferences) {
+		return models.Customer{}, ErrNothingToModify
+	}
+
+	c.cacheCustomers[index] = customer
+	if err := dal.NewCustomerRepository().WriteCustomers(c.cacheCustomers); err != nil {
+		return models.Customer{}, err
+	}
+	recordAudit(ctx, "customer", customer.ID, AuditModify, before, customer)
+	return customer, nil
+}
+
+// DeleteCustomer removes the profile. Past orders keep the customer's name;
+// orders that are still in progress have to be finished first.
+func (c *Customers) DeleteCustomer(ctx context.Context, id string) error {
+	if err := c.loadCustomers(); err != nil {
+		return err
+	}
+	index := c.findCustomer(id)
+	if index < 0 {
+		return fmt.Errorf("customer %s %w", id, ErrNotFound)
+	}
+	orders, err := NewOrderService().GetAllOrders()
+	if err != nil {
+		return err
+	}
+	for _, order := range orders {
+		if order.CustomerID == id && strings.ToLower(order.Status) != "closed" {
+			return fmt.Errorf("%w: customer %s has order %s in progress", ErrConflict, id, order.ID)
+		}
+	}
+
+	deleted := c.cacheCustomers[index]
+	c.cacheCustomers = slices.Delete(c.cacheCustomers, index, index+1)
+	if err := dal.NewCustomerRepository().WriteCustomers(c.cacheCustomers); err != nil {
+		return err
+	}
+	recordAudit(ctx, "customer", id, AuditDelete, deleted, nil)
+	return nil
+}
+
+// GetCustomerOrders returns the orders of a customer, newest first.
+func (c *Customers) GetCustomerOrders(id string) ([]models.Order, error) {
+	if _, err := c.GetCustomerByID(id); err != nil {
+		return nil, err
+	}
+	orders, err := NewOrderService().GetAllOrders()
+	if err != nil {
+		return nil, err
+	}
+	history := []models.Order{}
+	for _, order := range orders {
+		if order.CustomerID == id {
+			history = append(history, order)
+		}
+	}
+	slices.SortFunc(history, func(a, b models.Order) int {
+		// order10 comes after order9
+		return cmp.Or(cmp.Compare(b.CreatedAt, a.CreatedAt), cmp.Compare(len(b.ID), len(a.ID)), cmp.Compare(b.ID, a.ID))
+	})
+	return history, nil
+}
+
+// ReorderLast places a new open order with the items and party size of the
+// customer's latest order, or of orderID when it is given. Prices,
+// availability and promo codes are worked out again.
+func (c *Customers) ReorderLast(ctx context.Context, id, orderID string) (models.Order, error) {
+	customer, err := c.GetCustomerByID(id)
+	if err != nil {
+		return models.Order{}, err
+	}
+	history, err := c.GetCustomerOrders(id)
+	if err != nil {
+		return models.Order{}, err
+	}
+	index := 0
+	if orderID != "" {
+		index = slices.IndexFunc(history, func(order models.Order) bool { return order.ID == orderID })
+	}
+	if len(history) == 0 || index < 0 {
+		return models.Order{}, fmt.Errorf("previous order of customer %s %w", id, ErrNotFound)
+	}
+
+	previous := history[index]
+	order := models.Order{CustomerID: customer.ID, CustomerName: customer.Name, PartySize: previous.PartySize}
+	for _, item := range previous.Items {
+		order.Items = append(order.Items, models.OrderItem{ProductID: item.ProductID, Quantity: item.Quantity})
+	}
+	return NewOrderService().AddNewOrder(ctx, order)
+}
+
+// linkCustomer checks the customer an order is linked to and fills in the
+// customer's name when the order has none.
+func linkCustomer(order *models.Order) error {
+	if order.CustomerID == "" {
+		return nil
+	}
+	customer, err := NewCustomerService().GetCustomerByID(order.CustomerID)
+	if errors.Is(err, ErrNotFound) {
+		v := &ValidationError{}
+		v.Add(Pointer("customer_id"), "%s", err.Error())
+		return v.Err()
+	} else if err != nil {
+		return err
+	}
+	if order.CustomerName == "" {
+		order.CustomerName = customer.Name
+	}
+	return nil
+}
+
+func validateCustomer(customer models.Customer, others []models.Customer) (models.Customer, error) {
+	v := &ValidationError{}
+	customer.Name = strings.TrimSpace(customer.Name)
+	if customer.Name == "" {
+		v.Add(Pointer("name"), "name cannot be empty")
+	}
+	if strings.ContainsAny(customer.ID, "/?#") {
+		v.Add(Pointer("customer_id"), "customer ID cannot contain /, ? or #")
+	}
+
+	if customer.Phone != "" {
+		phone := normalizePhone(customer.Phone)
+		if digits := strings.TrimPrefix(phone, "+"); len(digits) < 7 || len(digits) > 15 || strings.ContainsFunc(customer.Phone, isForeignPhoneRune) {
+			v.Add(Pointer("phone"), "phone number has to have 7 to 15 digits")
+		}
+		customer.Phone = phone
+	}
+	if customer.Email != "" {
+		address, err := mail.ParseAddress(customer.Email)
+		if err != nil || address.Address != strings.TrimSpace(customer.Email) {
+			v.Add(Pointer("email"), "email is not a valid address")
+		}
+		customer.Email = strings.ToLower(strings.TrimSpace(customer.Email))
+	}
+	if customer.Phone == "" && customer.Email == "" {
+		v.Add(Pointer("phone"), "phone or email is required to recognize the customer")
+	}
+	for i, preference := range customer.Preferences {
+		if strings.TrimSpace(preference) == "" {
+			v.Add(Pointer("preferences", i), "preference cannot be empty")
+		}
+	}
+	if err := v.Err(); err != nil {
+		return models.Customer{}, err
+	}
+
+	for _, other := range others {
+		if customer.Phone != "" && other.Phone == customer.Phone {
+			return models.Customer{}, fmt.Errorf("%w: phone %s belongs to customer %s", ErrConflict, customer.Phone, other.ID)
+		}
+		if customer.Email != "" && other.Email == customer.Email {
+			return models.Customer{}, fmt.Errorf("%w: email %s belongs to customer %s", ErrConflict, customer.Email, other.ID)
+		}
+	}
+	return customer, nil
+}
+
+// normalizePhone keeps the digits of a phone number and a leading +, so
+// "+1 (555) 010-2030" and "+15550102030" are the same customer.
+func normalizePhone(phone string) string {
+	phone = strings.TrimSpace(phone)
+	var normalized strings.Builder
+	if strings.HasPrefix(phone, "+") {
+		normalized.WriteByte('+')
+	}
+	for _, r := range phone {
+		if r >= '0' && r <= '9' {
+			normalized.WriteRune(r)
+		}
+	}
+	if normalized.Len() <= 1 {
+		return ""
+	}
+	return normalized.String()
+}
+
+func isForeignPhoneRune(r rune) bool {
+	return !strings.ContainsRune("0123456789+-() .", r)
+}
@@ -0,0 +1,138 @@
+package service
+
+import (
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	EventOrderCreated        = "order.created"
+	EventOrderModified       = "order.modified"
+	EventOrderStatusChanged  = "order.status_changed"
+	EventOrderClosed         = "order.closed"
+	EventOrderDeleted        = "order.deleted"
+	EventOrderItemStarted    = "order.item_started"
+	EventOrderItemDone       = "order.item_done"
+	EventInventoryLowStock   = "inventory." + AlertLowStock
+	EventInventoryOutOfStock = "inventory." + AlertOutOfStock
+)
+
+// Event is something that happened in the services. Subject is the ID of
+// the entity it happened to and Item, when set, a line within it. IDs
+// increase by one per published event for the lifetime of the process.
+type Event struct {
+	ID      int64  `json:"id"`
+	Type    string `json:"type"`
+	Subject string `json:"subject"`
+	Item    string `json:"item,omitempty"`
+	Status  string `json:"status,omitempty"`
+	Time    string `json:"time"`
+	Data    any    `json:"data,omitempty"`
+}
+
+// Events is the process-wide bus the services publish to.
+var Events = NewEventBus(1024)
+
+// EventBus fans published events out to subscribers and keeps the latest
+// ones so subscribers that reconnect can catch up.
+type EventBus struct {
+	mu          sync.Mutex
+	lastID      int64
+	history     []Event
+	size        int
+	subscribers map[*Subscription]struct{}
+}
+
+type Subscription struct {
+	bus    *EventBus
+	events chan Event
+}
+
+func NewEventBus(size int) *EventBus {
+	return &EventBus{size: size, subscribers: map[*Subscription]struct{}{}}
+}
+
+// Publish assigns the event its ID and time and delivers it.
+func (b *EventBus) Publish(event Event) Event {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	b.lastID++
+	event.ID = b.lastID
+	event.Time = time.Now().Format(time.DateTime)
+	b.history = append(b.history, event)
+	if len(b.history) > b.size {
+		b.history = b.history[len(b.history)-b.size:]
+	}
+
+	for sub := range b.subscribers {
+		select {
+		case sub.events <- event:
+		default:
+			// A subscriber that cannot keep up is dropped; it can resume from
+			// the last event it saw.
+			b.unsubscribe(sub)
+		}
+	}
+	return event
+}
+
+// Subscribe returns the retained events published after afterID and a
+// subscription for everything that follows. complete is false when events
+// after afterID have already been discarded or afterID was never issued,
+// in which case the subscriber should reload its state.
+func (b *EventBus) Subscribe(afterID int64) (sub *Subscription, missed []Event, complete bool) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	complete = afterID <= b.lastID
+	if len(b.history) > 0 && afterID < b.history[0].ID-1 {
+		complete = false
+	}
+	for _, event := range b.history {
+		if event.ID > afterID {
+			missed = append(missed, event)
+		}
+	}
+
+	sub = &Subscription{bus: b, events: make(chan Event, 64)}
+	b.subscribers[sub] = struct{}{}
+	return sub, missed, complete
+}
+
+func (b *EventBus) LastID() int64 {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	return b.lastID
+}
+
+func (b *EventBus) unsubscribe(sub *Subscription) {
+	if _, ok := b.subscribers[sub]; ok {
+		delete(b.subscribers, sub)
+		close(sub.events)
+	}
+}
+
+// Events is closed when the subscription ends, either through Close or
+// because the subscriber fell behind.
+func (s *Subscription) Events() <-chan Event {
+	return s.events
+}
+
+func (s *Subscription) Close() {
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	s.bus.unsubscribe(s)
+}
+
+// MatchesType reports whether eventType equals one of the patterns or falls
+// under one ending in ".*".
+func MatchesType(eventType string, patterns ...string) bool {
+	for _, pattern := range patterns {
+		if prefix, ok := strings.CutSuffix(pattern, "*"); ok && strings.HasPrefix(eventType, prefix) || pattern == eventType {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,269 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hot-coffee1/internal/dal"
+	"hot-coffee1/models"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrGiftCardNotRead = errors.New("gift cards were not read")
+
+const (
+	GiftCardIssue  = "issue"
+	GiftCardReload = "reload"
+	GiftCardRedeem = "redeem"
+	GiftCardRefund = "refund"
+)
+
+// giftCardMu serialises balance changes so a card cannot be spent twice.
+var giftCardMu sync.Mutex
+
+type GiftCards struct{}
+
+type GiftCardService interface {
+	GetAllGiftCards() ([]models.GiftCard, error)
+	GetGiftCard(code string) (models.GiftCard, error)
+	IssueGiftCard(ctx context.Context, card models.GiftCard) (models.GiftCard, error)
+	ReloadGiftCard(ctx context.Context, code string, amount models.Money) (models.GiftCard, error)
+	GetGiftCardTransactions(code string) ([]models.GiftCardTransaction, error)
+}
+
+func NewGiftCardService() GiftCardService {
+	return &GiftCards{}
+}
+
+func normalizeGiftCardCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+func findGiftCard(cards []models.GiftCard, code string) int {
+	return slices.IndexFunc(cards, func(card models.GiftCard) bool { return card.Code == code })
+}
+
+func readGiftCards() ([]models.GiftCard, error) {
+	cards, err := dal.NewGiftCardRepository().ReadGiftCards()
+	if err != nil {
+		return nil, errors.Join(ErrGiftCardNotRead, err)
+	}
+	return cards, nil
+}
+
+func (g *GiftCards) GetAllGiftCards() ([]models.GiftCard, error) {
+	cards, err := readGiftCards()
+	if err != nil {
+		return nil, err
+	}
+	if cards == nil {
+		cards = []models.GiftCard{}
+	}
+	return cards, nil
+}
+
+// GetGiftCard is the balance inquiry.
+func (g *GiftCards) GetGiftCard(code string) (models.GiftCard, error) {
+	cards, err := readGiftCards()
+	if err != nil {
+		return models.GiftCard{}, err
+	}
+	code = normalizeGiftCardCode(code)
+	index := findGiftCard(cards, code)
+	if index < 0 {
+		return models.GiftCard{}, fmt.Errorf("gift card %s %w", code, ErrNotFound)
+	}
+	return cards[index], nil
+}
+
+// IssueGiftCard creates a card with its opening balance. A code is made up
+// when none is given.
+func (g *GiftCards) IssueGiftCard(ctx context.Context, card models.GiftCard) (models.GiftCard, error) {
+	v := &ValidationError{}
+	validatePrice(v, Pointer("balance"), card.Balance)
+	if card.CustomerID != "" {
+		if _, err := NewCustomerService().GetCustomerByID(card.CustomerID); errors.Is(err, ErrNotFound) {
+			v.Add(Pointer("customer_id"), "%s", err.Error())
+		} else if err != nil {
+			return models.GiftCard{}, err
+		}
+	}
+	card.Code = normalizeGiftCardCode(card.Code)
+	if strings.ContainsAny(card.Code, "/?# ") {
+		v.Add(Pointer("code"), "code cannot contain spaces, /, ? or #")
+	}
+	if err := v.Err(); err != nil {
+		return models.GiftCard{}, err
+	}
+
+	giftCardMu.Lock()
+	defer giftCardMu.Unlock()
+
+	cards, err := readGiftCards()
+	if err != nil {
+		return models.GiftCard{}, err
+	}
+	if card.Code == "" {
+		for card.Code == "" || findGiftCard(cards, card.Code) >= 0 {
+			card.Code = strings.ToUpper(randomHex(8))
+		}
+	}
+	if findGiftCard(cards, card.Code) >= 0 {
+		return models.GiftCard{}, fmt.Errorf("%w: gift card %s", ErrConflict, card.Code)
+	}
+
+	amount := card.Balance
+	card.Balance = models.NewMoney(0)
+	card.Active = true
+	card.CreatedAt = time.Now().Format(time.DateTime)
+	card.CreatedBy = actorName(ctx)
+	cards = append(cards, card)
+	card, err = moveGiftCard(ctx, cards, card.Code, GiftCardIssue, amount, "", "")
+	if err != nil {
+		return models.GiftCard{}, err
+	}
+	recordAudit(ctx, "gift_card", card.Code, AuditCreate, nil, card)
+	return card, nil
+}
+
+func (g *GiftCards) ReloadGiftCard(ctx context.Context, code string, amount models.Money) (models.GiftCard, error) {
+	v := &ValidationError{}
+	validatePrice(v, Pointer("amount"), amount)
+	if err := v.Err(); err != nil {
+		return models.GiftCard{}, err
+	}
+
+	giftCardMu.Lock()
+	defer giftCardMu.Unlock()
+
+	cards, err := readGiftCards()
+	if err != nil {
+		return models.GiftCard{}, err
+	}
+	code = normalizeGiftCardCode(code)
+	index := findGiftCard(cards, code)
+	if index < 0 {
+		return models.GiftCard{}, fmt.Errorf("gift card %s %w", code, ErrNotFound)
+	}
+	if !cards[index].Active {
+		return models.GiftCard{}, fmt.Errorf("%w: gift card %s is not active", ErrConflict, code)
+	}
+
+	before := cards[index]
+	card, err := moveGiftCard(ctx, cards, code, GiftCardReload, amount, "", "")
+	if err != nil {
+		return models.GiftCard{}, err
+	}
+	recordAudit(ctx, "gift_card", code, GiftCardReload, before, card)
+	return card, nil
+}
+
+// GetGiftCardTransactions returns the ledger of a card, newest first.
+func (g *GiftCards) GetGiftCardTransactions(code string) ([]models.GiftCardTransaction, error) {
+	code = normalizeGiftCardCode(code)
+	if _, err := g.GetGiftCard(code); err != nil {
+		return nil, err
+	}
+	ledger, err := dal.NewGiftCardRepository().ReadGiftCardLedger()
+	if err != nil {
+		return nil, errors.Join(ErrGiftCardNotRead, err)
+	}
+	transactions := []models.GiftCardTransaction{}
+	for i := len(ledger) - 1; i >= 0; i-- {
+		if ledger[i].Code == code {
+			transactions = append(transactions, ledger[i])
+		}
+	}
+	return transactions, nil
+}
+
+// moveGiftCard adds amount, negative when the card pays, to the balance of
+// a card in cards and saves the cards with the transaction. The caller
+// holds giftCardMu.
+func moveGiftCard(ctx context.Context, cards []models.GiftCard, code, kind string, amount models.Money, orderID, paymentID string) (models.GiftCard, error) {
+	index := findGiftCard(cards, code)
+	if index < 0 {
+		return models.GiftCard{}, fmt.Errorf("gift card %s %w", code, ErrNotFound)
+	}
+	balance, err := cards[index].Balance.Add(amount)
+	if err != nil {
+		return models.GiftCard{}, err
+	}
+	if balance.Amount < 0 {
+		return models.GiftCard{}, fmt.Errorf("%w: gift card %s has %s left", ErrPaymentDeclined, code, cards[index].Balance)
+	}
+
+	repo := dal.NewGiftCardRepository()
+	ledger, err := repo.ReadGiftCardLedger()
+	if err != nil {
+		return models.GiftCard{}, errors.Join(ErrGiftCardNotRead, err)
+	}
+	cards[index].Balance = balance
+	ledger = append(ledger, models.GiftCardTransaction{
+		ID:        randomHex(6),
+		Code:      code,
+		Kind:      kind,
+		Amount:    amount,
+		Balance:   balance,
+		OrderID:   orderID,
+		PaymentID: paymentID,
+		CreatedAt: time.Now().Format(time.DateTime),
+		CreatedBy: actorName(ctx),
+	})
+	if err := repo.WriteGiftCardsWithLedger(cards, ledger); err != nil {
+		return models.GiftCard{}, err
+	}
+	return cards[index], nil
+}
+
+// giftCardAmount is what a gift card pays when the payment names no
+// amount: what is due, or the card's balance when that is less. Unknown
+// and empty cards are left for redeemGiftCard to decline.
+func giftCardAmount(code string, due models.Money) models.Money {
+	card, err := NewGiftCardService().GetGiftCard(code)
+	if err != nil || card.Balance.Amount <= 0 {
+		return models.Money{}
+	}
+	amount, err := minMoney(card.Balance, due)
+	if err != nil {
+		return models.Money{}
+	}
+	return amount
+}
+
+// redeemGiftCard takes a payment from the card in its reference.
+func redeemGiftCard(ctx context.Context, payment *models.Payment, orderID string) error {
+	giftCardMu.Lock()
+	defer giftCardMu.Unlock()
+
+	cards, err := readGiftCards()
+	if err != nil {
+		return err
+	}
+	payment.Reference = normalizeGiftCardCode(payment.Reference)
+	index := findGiftCard(cards, payment.Reference)
+	switch {
+	case index < 0:
+		return fmt.Errorf("%w: gift card %s does not exist", ErrPaymentDeclined, payment.Reference)
+	case !cards[index].Active:
+		return fmt.Errorf("%w: gift card %s is not active", ErrPaymentDeclined, payment.Reference)
+	}
+	_, err = moveGiftCard(ctx, cards, payment.Reference, GiftCardRedeem, models.NewMoney(-payment.Amount.Amount), orderID, payment.ID)
+	return err
+}
+
+// refundGiftCard puts a refunded payment back on its card.
+func refundGiftCard(ctx context.Context, payment models.Payment, orderID string) error {
+	giftCardMu.Lock()
+	defer giftCardMu.Unlock()
+
+	cards, err := readGiftCards()
+	if err != nil {
+		return err
+	}
+	_, err = moveGiftCard(ctx, cards, payment.Reference, GiftCardRefund, payment.Amount, orderID, payment.ID)
+	return err
+}
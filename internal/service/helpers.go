@@ -2,63 +2,124 @@ package service
 
 import (
 	"errors"
-	"fmt"
 	"hot-coffee1/models"
+	"slices"
 )
 
 var (
-	ErrNotExists        = errors.New("resource not found")
-	ErrIDNotExist       = errors.New("item with this id does not exists")
-	ErrZeroLengthID     = errors.New("item cant have 0 length id")
-	ErrConflict         = errors.New("item with this ID already exists")
-	ErrInventoryNotRead = errors.New("inventory was not read")
-	ErrMenuNotRead      = errors.New("menu was not read")
-	ErrOrderNotRead     = errors.New("orders were not read")
-	ErrNothingToModify  = errors.New("nothing to modify")
-	ErrMalformedContent = errors.New("malformed content")
-	ErrNotFound         = errors.New("not found")
+	ErrNotExists         = errors.New("resource not found")
+	ErrIDNotExist        = errors.New("item with this id does not exists")
+	ErrZeroLengthID      = errors.New("item cant have 0 length id")
+	ErrConflict          = errors.New("item with this ID already exists")
+	ErrInventoryNotRead  = errors.New("inventory was not read")
+	ErrMenuNotRead       = errors.New("menu was not read")
+	ErrOrderNotRead      = errors.New("orders were not read")
+	ErrNothingToModify   = errors.New("nothing to modify")
+	ErrMalformedContent  = errors.New("malformed content")
+	ErrNotFound          = errors.New("not found")
+	ErrInsufficientStock = errors.New("not enough stock")
+	ErrOrderClosed       = errors.New("order is already closed")
+	ErrItemUnavailable   = errors.New("menu item is unavailable")
 )
 
 func validatePostInventory(item models.InventoryItem) error {
+	v := &ValidationError{}
 	if item.IngredientID == "" {
-		return errors.New("ingredient ID cannot be empty")
-	} else if item.Quantity < 0 {
-		return errors.New("quantity cannot be negative")
-	} else if item.Unit == "" {
-		return errors.New("unit cannot be empty")
-	} else if item.Name == "" {
-		return errors.New("name cannot be empty")
+		v.Add(Pointer("ingredient_id"), "ingredient ID cannot be empty")
 	}
+	if item.Name == "" {
+		v.Add(Pointer("name"), "name cannot be empty")
+	}
+	if item.Quantity < 0 {
+		v.Add(Pointer("quantity"), "quantity cannot be negative")
+	}
+	if item.Unit == "" {
+		v.Add(Pointer("unit"), "unit cannot be empty")
+	}
+	if item.LowStockThreshold < 0 {
+		v.Add(Pointer("low_stock_threshold"), "low stock threshold cannot be negative")
+	}
+	validateAllergenFlags(v, item)
+	if n := item.Nutrition; n != nil {
+		values := []struct {
+			field string
+			value float64
+		}{{"calories_kcal", n.Calories}, {"sugar_g", n.Sugar}, {"fat_g", n.Fat}, {"protein_g", n.Protein}, {"caffeine_mg", n.Caffeine}}
+		for _, value := range values {
+			if value.value < 0 {
+				v.Add(Pointer("nutrition", value.field), "%s cannot be negative", value.field)
+			}
+		}
+	}
+	return v.Err()
+}
 
-	return nil
+// validatePrice checks that a price is positive and in the shop currency.
+func validatePrice(v *ValidationError, field string, price models.Money) {
+	if price.Amount <= 0 {
+		v.Add(field, "price cannot be negative or zero")
+	}
+	if price.Currency != models.DefaultCurrency {
+		v.Add(field+"/currency", "currency has to be %s", models.DefaultCurrency)
+	}
 }
 
 func validatePostMenu(item models.MenuItem) error {
+	v := &ValidationError{}
 	if item.ID == "" {
-		return errors.New("product ID cannot be empty")
-	} else if item.Price <= 0 {
-		return errors.New("price cannot be negative or zero")
-	} else if item.Description == "" {
-		return errors.New("description cannot be empty")
-	} else if item.Name == "" {
-		return errors.New("name cannot be empty")
-	} else if len(item.Ingredients) < 1 {
-		return errors.New("number of ingredients cannot be less than 1")
+		v.Add(Pointer("product_id"), "product ID cannot be empty")
 	}
-	return nil
+	if item.Name == "" {
+		v.Add(Pointer("name"), "name cannot be empty")
+	}
+	if item.Description == "" {
+		v.Add(Pointer("description"), "description cannot be empty")
+	}
+	validatePrice(v, Pointer("price"), item.Price)
+	if len(item.Ingredients) < 1 {
+		v.Add(Pointer("ingredients"), "number of ingredients cannot be less than 1")
+	}
+	validatePostMenuIngredients(v, item.Ingredients)
+	for j, tag := range item.Tags {
+		if tag == "" {
+			v.Add(Pointer("tags", j), "tag cannot be empty")
+		} else if slices.Index(item.Tags, tag) < j {
+			v.Add(Pointer("tags", j), "duplicated tag %s", tag)
+		}
+	}
+	for j, image := range item.Images {
+		if image == "" {
+			v.Add(Pointer("images", j), "image reference cannot be empty")
+		}
+	}
+	return v.Err()
 }
 
-func validatePostMenuIngredients(Ingredients []models.MenuItemIngredient) error {
+func validatePostMenuIngredients(v *ValidationError, Ingredients []models.MenuItemIngredient) {
 	takenIDMenuInventory := make(map[string]int)
 	for j, val := range Ingredients {
-		if _, exists := takenIDMenuInventory[val.IngredientID]; exists {
-			return errors.New("duplicated ingredient ID")
+		if val.IngredientID == "" {
+			v.Add(Pointer("ingredients", j, "ingredient_id"), "ingredient ID cannot be empty")
+		} else if _, exists := takenIDMenuInventory[val.IngredientID]; exists {
+			v.Add(Pointer("ingredients", j, "ingredient_id"), "duplicated ingredient ID %s", val.IngredientID)
 		}
 		takenIDMenuInventory[val.IngredientID] = j
 
 		if val.Quantity < 0 {
-			return fmt.Errorf("item with quantity %v is less than 0", val.Quantity)
+			v.Add(Pointer("ingredients", j, "quantity"), "item with quantity %v is less than 0", val.Quantity)
+		}
+	}
+}
+
+// addMoney adds amounts to total, stopping at the first one in another
+// currency.
+func addMoney(total *models.Money, amounts ...models.Money) error {
+	for _, amount := range amounts {
+		sum, err := total.Add(amount)
+		if err != nil {
+			return err
 		}
+		*total = sum
 	}
 	return nil
 }
@@ -1,10 +1,12 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"hot-coffee1/internal/dal"
 	"hot-coffee1/models"
+	"slices"
 )
 
 type Inventory struct {
@@ -16,10 +18,10 @@ type InventoryService interface {
 	LoadInventoryCache() error
 	GetAllInventory() ([]models.InventoryItem, error)
 	GetInventoryByID(id string) (models.InventoryItem, error)
-	AddNewInventoryItem(item models.InventoryItem) error
-	DeleteInventoryItem(id string) error
-	ModifyInventoryItem(item models.InventoryItem) error
-	DeductInventoryItem(ID string, quantity float64) error
+	AddNewInventoryItem(ctx context.Context, item models.InventoryItem) error
+	DeleteInventoryItem(ctx context.Context, id string) error
+	ModifyInventoryItem(ctx context.Context, item models.InventoryItem) error
+	DeductInventoryItem(ctx context.Context, ID string, quantity float64) error
 }
 
 func NewInventoryService() InventoryService {
@@ -64,12 +66,12 @@ func (i *Inventory) GetInventoryByID(id string) (models.InventoryItem, error) {
 	}
 	index, exists := i.takenIDInventory[id]
 	if !exists || index < 0 || index >= len(i.cacheInventory) {
-		return models.InventoryItem{}, fmt.Errorf("item with ingredient ID=%s not found", id)
+		return models.InventoryItem{}, fmt.Errorf("item with ingredient ID=%s %w", id, ErrNotFound)
 	}
 	return i.cacheInventory[index], nil
 }
 
-func (i *Inventory) AddNewInventoryItem(item models.InventoryItem) error {
+func (i *Inventory) AddNewInventoryItem(ctx context.Context, item models.InventoryItem) error {
 	err := i.LoadInventoryCache()
 	if err != nil {
 		return err
@@ -84,50 +86,60 @@ func (i *Inventory) AddNewInventoryItem(item models.InventoryItem) error {
 	if err := dal.NewInventoryRepository().WriteInventory(i.cacheInventory); err != nil {
 		return errors.New("failed to save inventory item")
 	}
+	recordAudit(ctx, "inventory", item.IngredientID, AuditCreate, nil, item)
+	evaluateStockAlerts(item)
 	return nil
 }
 
-func (i *Inventory) DeleteInventoryItem(id string) error {
+func (i *Inventory) DeleteInventoryItem(ctx context.Context, id string) error {
 	err := i.LoadInventoryCache()
 	if err != nil {
 		return err
 	}
 	index, exists := i.takenIDInventory[id]
 	if !exists || index < 0 || index >= len(i.cacheInventory) {
-		return fmt.Errorf("item with ingredient ID=%s not found", id)
+		return fmt.Errorf("item with ingredient ID=%s %w", id, ErrNotFound)
 	}
+	deleted := i.cacheInventory[index]
 	i.cacheInventory = append(i.cacheInventory[:index], i.cacheInventory[index+1:]...)
 	err = dal.NewInventoryRepository().WriteInventory(i.cacheInventory)
 	if err != nil {
 		return err
 	}
+	recordAudit(ctx, "inventory", id, AuditDelete, deleted, nil)
 	return nil
 }
 
-func (i *Inventory) ModifyInventoryItem(item models.InventoryItem) error {
+func (i *Inventory) ModifyInventoryItem(ctx context.Context, item models.InventoryItem) error {
 	err := i.LoadInventoryCache()
 	if err != nil {
 		return err
 	}
 	index, exists := i.takenIDInventory[item.IngredientID]
 	if !exists || index < 0 || index >= len(i.cacheInventory) {
-		return fmt.Errorf("item with ingredient ID=%s not found", item.IngredientID)
+		return fmt.Errorf("item with ingredient ID=%s %w", item.IngredientID, ErrNotFound)
 	}
 	if err := validatePostInventory(item); err != nil {
 		return err
 	}
-	if i.cacheInventory[index] == item {
+	if err := validateIngredientDiets(item); err != nil {
+		return err
+	}
+	if areInventoryItemsEqual(i.cacheInventory[index], item) {
 		return ErrNothingToModify
 	}
+	before := i.cacheInventory[index]
 	i.cacheInventory[index] = item
 	err = dal.NewInventoryRepository().WriteInventory(i.cacheInventory)
 	if err != nil {
 		return err
 	}
+	recordAudit(ctx, "inventory", item.IngredientID, AuditModify, before, item)
+	evaluateStockAlerts(item)
 	return nil
 }
 
-func (i *Inventory) DeductInventoryItem(ID string, quantity float64) error {
+func (i *Inventory) DeductInventoryItem(ctx context.Context, ID string, quantity float64) error {
 	err := i.LoadInventoryCache()
 	if err != nil {
 		return err
@@ -140,11 +152,25 @@ func (i *Inventory) DeductInventoryItem(ID string, quantity float64) error {
 	i.cacheInventory[index].Quantity = item.Quantity - quantity
 	if err := validatePostInventory(i.cacheInventory[index]); err != nil {
 		i.cacheInventory[index].Quantity = item.Quantity
-		return errors.New(fmt.Sprintf("not enough quantity of ID=%s, wanted %v, given %v", ID, quantity, item.Quantity))
+		return fmt.Errorf("%w: ID=%s, wanted %v, given %v", ErrInsufficientStock, ID, quantity, item.Quantity)
 	}
 	err = dal.NewInventoryRepository().WriteInventory(i.cacheInventory)
 	if err != nil {
 		return err
 	}
+	recordAudit(ctx, "inventory", ID, AuditDeduct, item, i.cacheInventory[index])
+	evaluateStockAlerts(i.cacheInventory[index])
 	return nil
 }
+
+func areInventoryItemsEqual(a, b models.InventoryItem) bool {
+	return a.IngredientID == b.IngredientID &&
+		a.Name == b.Name &&
+		a.Quantity == b.Quantity &&
+		a.Unit == b.Unit &&
+		a.LowStockThreshold == b.LowStockThreshold &&
+		slices.Equal(a.Allergens, b.Allergens) &&
+		slices.Equal(a.Dietary, b.Dietary) &&
+		(a.Nutrition == nil) == (b.Nutrition == nil) &&
+		(a.Nutrition == nil || *a.Nutrition == *b.Nutrition)
+}
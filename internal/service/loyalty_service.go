@@ -0,0 +1,537 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"hot-coffee1/internal/dal"
+	"hot-coffee1/models"
+	"log/slog"
+	"math"
+	"slices"
+	"sync"
+	"time"
+)
+
+var (
+	ErrLoyaltyNotRead       = errors.New("loyalty program was not read")
+	ErrLoyaltyNotApplicable = errors.New("loyalty reward cannot be redeemed")
+)
+
+const (
+	LoyaltyOff    = "off"
+	LoyaltyPoints = "points"
+	LoyaltyStamps = "stamps"
+)
+
+const (
+	LoyaltyEarn   = "earn"
+	LoyaltyRedeem = "redeem"
+	LoyaltyRefund = "refund"
+	LoyaltyExpire = "expire"
+)
+
+// LoyaltyCode is the discount code that redeems loyalty points when an
+// order is created, e.g. {"code": "LOYALTY", "points": 500}.
+const (
+	LoyaltyCode     = "LOYALTY"
+	DiscountLoyalty = "loyalty"
+)
+
+// loyaltyMu serialises changes to the ledger so a balance cannot be
+// redeemed twice.
+var loyaltyMu sync.Mutex
+
+type Loyalty struct{}
+
+type LoyaltyService interface {
+	GetLoyaltyProgram() (models.LoyaltyProgram, error)
+	SetLoyaltyProgram(ctx context.Context, program models.LoyaltyProgram) (models.LoyaltyProgram, error)
+	GetLoyaltyAccount(customerID string) (models.LoyaltyAccount, error)
+}
+
+func NewLoyaltyService() LoyaltyService {
+	return &Loyalty{}
+}
+
+func (l *Loyalty) GetLoyaltyProgram() (models.LoyaltyProgram, error) {
+	return readLoyaltyProgram()
+}
+
+func (l *Loyalty) SetLoyaltyProgram(ctx context.Context, program models.LoyaltyProgram) (models.LoyaltyProgram, error) {
+	before, err := readLoyaltyProgram()
+	if err != nil {
+		return models.LoyaltyProgram{}, err
+	}
+	if program.Mode == "" {
+		program.Mode = LoyaltyOff
+	}
+	if err := validateLoyaltyProgram(program); err != nil {
+		return models.LoyaltyProgram{}, err
+	}
+
+	if err := dal.NewLoyaltyRepository().WriteLoyaltyProgram(program); err != nil {
+		return models.LoyaltyProgram{}, err
+	}
+	recordAudit(ctx, "loyalty", "program", AuditModify, before, program)
+	return program, nil
+}
+
+// GetLoyaltyAccount returns the balance of a customer in the unit of the
+// current program and the ledger behind it, newest first.
+func (l *Loyalty) GetLoyaltyAccount(customerID string) (models.LoyaltyAccount, error) {
+	if _, err := NewCustomerService().GetCustomerByID(customerID); err != nil {
+		return models.LoyaltyAccount{}, err
+	}
+	program, err := readLoyaltyProgram()
+	if err != nil {
+		return models.LoyaltyAccount{}, err
+	}
+	entries, err := readLoyaltyLedger()
+	if err != nil {
+		return models.LoyaltyAccount{}, err
+	}
+
+	unit := loyaltyUnit(program)
+	account := models.LoyaltyAccount{CustomerID: customerID, Unit: unit, Ledger: []models.LoyaltyEntry{}}
+	for i := len(entries) - 1; i >= 0; i-- {
+		entry := entries[i]
+		if entry.CustomerID != customerID || entry.Unit != unit {
+			continue
+		}
+		account.Balance += entry.Points
+		account.Ledger = append(account.Ledger, entry)
+		if entry.Remaining <= 0 || entry.ExpiresAt == "" {
+			continue
+		}
+		switch {
+		case account.NextExpiry == "" || entry.ExpiresAt < account.NextExpiry:
+			account.NextExpiry, account.Expiring = entry.ExpiresAt, entry.Remaining
+		case entry.ExpiresAt == account.NextExpiry:
+			account.Expiring += entry.Remaining
+		}
+	}
+	switch program.Mode {
+	case LoyaltyPoints:
+		account.Value = program.PointValue.Mul(account.Balance)
+	case LoyaltyStamps:
+		account.Rewards = account.Balance / program.StampsForReward
+	}
+	return account, nil
+}
+
+func readLoyaltyProgram() (models.LoyaltyProgram, error) {
+	program, err := dal.NewLoyaltyRepository().ReadLoyaltyProgram()
+	if err != nil {
+		return models.LoyaltyProgram{}, errors.Join(ErrLoyaltyNotRead, err)
+	}
+	if program.Mode == "" {
+		program.Mode = LoyaltyOff
+	}
+	return program, nil
+}
+
+// readLoyaltyLedger returns the ledger with expired points written off.
+func readLoyaltyLedger() ([]models.LoyaltyEntry, error) {
+	loyaltyMu.Lock()
+	defer loyaltyMu.Unlock()
+
+	entries, err := dal.NewLoyaltyRepository().ReadLoyaltyLedger()
+	if err != nil {
+		return nil, errors.Join(ErrLoyaltyNotRead, err)
+	}
+	entries, expired := expireLoyalty(entries)
+	if expired {
+		if err := dal.NewLoyaltyRepository().WriteLoyaltyLedger(entries); err != nil {
+			return nil, err
+		}
+	}
+	return entries, nil
+}
+
+func loyaltyUnit(program models.LoyaltyProgram) string {
+	if program.Mode == LoyaltyStamps {
+		return LoyaltyStamps
+	}
+	return LoyaltyPoints
+}
+
+// expireLoyalty writes off what is left of earned entries from the day
+// they expire.
+func expireLoyalty(entries []models.LoyaltyEntry) ([]models.LoyaltyEntry, bool) {
+	now := time.Now()
+	today := now.Format(time.DateOnly)
+	expired := false
+	for i, n := 0, len(entries); i < n; i++ {
+		if entries[i].Remaining <= 0 || entries[i].ExpiresAt == "" || entries[i].ExpiresAt > today {
+			continue
+		}
+		remaining := entries[i].Remaining
+		entries[i].Remaining = 0
+		entries = append(entries, models.LoyaltyEntry{
+			ID:         randomHex(6),
+			CustomerID: entries[i].CustomerID,
+			Kind:       LoyaltyExpire,
+			Unit:       entries[i].Unit,
+			Points:     -remaining,
+			Sources:    []models.LoyaltySource{{EntryID: entries[i].ID, Points: remaining}},
+			CreatedAt:  now.Format(time.DateTime),
+		})
+		expired = true
+	}
+	return entries, expired
+}
+
+// loyaltyMatcher tells whether an order line matches targets; empty
+// targets match every line.
+func loyaltyMatcher(targets models.RuleTargets) (func(models.OrderItem) bool, error) {
+	if len(targets.ProductIDs) == 0 && len(targets.Categories) == 0 {
+		return func(models.OrderItem) bool { return true }, nil
+	}
+	categories, err := targetCategories(targets)
+	if err != nil {
+		return nil, err
+	}
+	menu, err := dal.NewMenuRepository().ReadMenu()
+	if err != nil {
+		return nil, errors.Join(ErrMenuNotRead, err)
+	}
+	itemCategories := make(map[string]string, len(menu))
+	for _, item := range menu {
+		itemCategories[item.ID] = item.CategoryID
+	}
+	return func(item models.OrderItem) bool {
+		return slices.Contains(targets.ProductIDs, item.ProductID) || categories[itemCategories[item.ProductID]]
+	}, nil
+}
+
+// redeemLoyalty takes points from the customer's balance and adds them to
+// the order as a LOYALTY discount: their value in points mode, or the
+// cheapest stamp items in stamps mode.
+func redeemLoyalty(order *models.Order, points int) error {
+	if points == 0 {
+		return nil
+	}
+	v := &ValidationError{}
+	if order.CustomerID == "" {
+		v.Add(Pointer("customer_id"), "loyalty points can only be redeemed by a customer")
+	}
+	if points < 0 {
+		v.Add(Pointer("discounts"), "loyalty points cannot be negative")
+	}
+	if err := v.Err(); err != nil {
+		return err
+	}
+
+	program, err := readLoyaltyProgram()
+	if err != nil {
+		return err
+	}
+	discount := models.OrderDiscount{Code: LoyaltyCode, Kind: DiscountLoyalty, Points: points}
+	switch program.Mode {
+	case LoyaltyPoints:
+		if points < program.MinRedeem {
+			return fmt.Errorf("%w: at least %d points have to be redeemed", ErrLoyaltyNotApplicable, program.MinRedeem)
+		}
+		discount.Description = fmt.Sprintf("%d points", points)
+		discount.Amount = program.PointValue.Mul(points)
+		subtotal, err := orderSubtotal(order.Items)
+		if err != nil {
+			return err
+		}
+		discounts, err := orderDiscountTotal(*order)
+		if err != nil {
+			return err
+		}
+		left, err := subtotal.Sub(discounts)
+		if err != nil {
+			return err
+		}
+		if diff, err := discount.Amount.Sub(left); err != nil {
+			return err
+		} else if diff.Amount > 0 {
+			return fmt.Errorf("%w: %d points are worth %s, more than the %s left on the order", ErrLoyaltyNotApplicable, points, discount.Amount, left)
+		}
+	case LoyaltyStamps:
+		if points%program.StampsForReward != 0 {
+			v.Add(Pointer("discounts"), "stamps are redeemed %d at a time", program.StampsForReward)
+			return v.Err()
+		}
+		matches, err := loyaltyMatcher(program.Stamps)
+		if err != nil {
+			return err
+		}
+		var units []models.Money
+		for _, item := range order.Items {
+			if matches(item) {
+				for range item.Quantity {
+					units = append(units, item.UnitPrice)
+				}
+			}
+		}
+		rewards := points / program.StampsForReward
+		if len(units) < rewards {
+			return fmt.Errorf("%w: %d stamps need %d items that can be free, the order has %d", ErrLoyaltyNotApplicable, points, rewards, len(units))
+		}
+		// The cheapest units are the free ones.
+		slices.SortFunc(units, func(a, b models.Money) int { return cmp.Compare(a.Amount, b.Amount) })
+		discount.Amount = models.NewMoney(0)
+		if err := addMoney(&discount.Amount, units[:rewards]...); err != nil {
+			return err
+		}
+		discount.Description = fmt.Sprintf("%d stamps for a free item", points)
+		if rewards > 1 {
+			discount.Description = fmt.Sprintf("%d stamps for %d free items", points, rewards)
+		}
+	default:
+		return fmt.Errorf("%w: the loyalty program is off", ErrLoyaltyNotApplicable)
+	}
+
+	loyaltyMu.Lock()
+	defer loyaltyMu.Unlock()
+
+	repo := dal.NewLoyaltyRepository()
+	entries, err := repo.ReadLoyaltyLedger()
+	if err != nil {
+		return errors.Join(ErrLoyaltyNotRead, err)
+	}
+	entries, _ = expireLoyalty(entries)
+
+	unit := loyaltyUnit(program)
+	balance := 0
+	for _, entry := range entries {
+		if entry.CustomerID == order.CustomerID && entry.Unit == unit {
+			balance += entry.Points
+		}
+	}
+	if balance < points {
+		return fmt.Errorf("%w: customer %s has %d %s", ErrLoyaltyNotApplicable, order.CustomerID, balance, unit)
+	}
+
+	// Redemptions use up the oldest points first.
+	redeem := models.LoyaltyEntry{
+		ID:         randomHex(6),
+		CustomerID: order.CustomerID,
+		Kind:       LoyaltyRedeem,
+		Unit:       unit,
+		Points:     -points,
+		OrderID:    order.ID,
+		CreatedAt:  time.Now().Format(time.DateTime),
+	}
+	for i, left := 0, points; i < len(entries) && left > 0; i++ {
+		if entries[i].CustomerID != order.CustomerID || entries[i].Unit != unit || entries[i].Remaining <= 0 {
+			continue
+		}
+		taken := min(left, entries[i].Remaining)
+		entries[i].Remaining -= taken
+		left -= taken
+		redeem.Sources = append(redeem.Sources, models.LoyaltySource{EntryID: entries[i].ID, Points: taken})
+	}
+	entries = append(entries, redeem)
+	if err := repo.WriteLoyaltyLedger(entries); err != nil {
+		return err
+	}
+	order.Discounts = append(order.Discounts, discount)
+	return nil
+}
+
+// refundLoyalty gives back the points an order redeemed to the entries
+// they came from, so they keep their expiry.
+func refundLoyalty(orderID string) {
+	loyaltyMu.Lock()
+	defer loyaltyMu.Unlock()
+
+	repo := dal.NewLoyaltyRepository()
+	entries, err := repo.ReadLoyaltyLedger()
+	if err != nil {
+		slog.Error("Failed to refund loyalty points", "order", orderID, "error", err)
+		return
+	}
+	if slices.ContainsFunc(entries, func(entry models.LoyaltyEntry) bool {
+		return entry.OrderID == orderID && entry.Kind == LoyaltyRefund
+	}) {
+		return
+	}
+
+	refunded := false
+	for _, redeem := range entries {
+		if redeem.OrderID != orderID || redeem.Kind != LoyaltyRedeem {
+			continue
+		}
+		for _, source := range redeem.Sources {
+			if index := slices.IndexFunc(entries, func(entry models.LoyaltyEntry) bool { return entry.ID == source.EntryID }); index >= 0 {
+				entries[index].Remaining += source.Points
+			}
+		}
+		entries = append(entries, models.LoyaltyEntry{
+			ID:         randomHex(6),
+			CustomerID: redeem.CustomerID,
+			Kind:       LoyaltyRefund,
+			Unit:       redeem.Unit,
+			Points:     -redeem.Points,
+			OrderID:    orderID,
+			Sources:    redeem.Sources,
+			CreatedAt:  time.Now().Format(time.DateTime),
+		})
+		refunded = true
+	}
+	if !refunded {
+		return
+	}
+	// Points given back after their expiry are written off again.
+	entries, _ = expireLoyalty(entries)
+	if err := repo.WriteLoyaltyLedger(entries); err != nil {
+		slog.Error("Failed to refund loyalty points", "order", orderID, "error", err)
+	}
+}
+
+// releaseDiscounts gives back the promo code uses and loyalty points of
+// discounts that were taken off an order or of an order that did not go
+// through.
+func releaseDiscounts(orderID string, discounts []models.OrderDiscount) {
+	releasePromoCodes(discounts)
+	if slices.ContainsFunc(discounts, func(discount models.OrderDiscount) bool { return discount.Kind == DiscountLoyalty }) {
+		refundLoyalty(orderID)
+	}
+}
+
+// loyaltyEarned works out what a closed order earns its customer. Units
+// made free with stamps do not earn stamps.
+func loyaltyEarned(order models.Order) (int, error) {
+	if order.CustomerID == "" || order.Totals == nil {
+		return 0, nil
+	}
+	program, err := readLoyaltyProgram()
+	if err != nil {
+		return 0, err
+	}
+
+	earned := 0
+	switch program.Mode {
+	case LoyaltyPoints:
+		earned = int(math.Floor(order.Totals.Net.Float()*program.PointsPerUnit + 1e-9))
+		for _, rule := range program.Rules {
+			matches, err := loyaltyMatcher(rule.Targets)
+			if err != nil {
+				return 0, err
+			}
+			units := 0
+			for _, item := range order.Items {
+				if matches(item) {
+					units += item.Quantity
+				}
+			}
+			earned += units * rule.PointsPerItem
+			if units > 0 && rule.BonusPoints > 0 && order.Totals.Net.Amount >= rule.MinSpend.Amount {
+				earned += rule.BonusPoints
+			}
+		}
+	case LoyaltyStamps:
+		matches, err := loyaltyMatcher(program.Stamps)
+		if err != nil {
+			return 0, err
+		}
+		for _, item := range order.Items {
+			if matches(item) {
+				earned += item.Quantity
+			}
+		}
+		for _, discount := range order.Discounts {
+			if discount.Kind == DiscountLoyalty {
+				earned -= discount.Points / program.StampsForReward
+			}
+		}
+	}
+	return max(earned, 0), nil
+}
+
+// recordLoyaltyEarned credits what a closed order earned. The order is
+// already closed, so a failure is only logged.
+func recordLoyaltyEarned(order models.Order) {
+	if order.LoyaltyEarned <= 0 {
+		return
+	}
+	program, err := readLoyaltyProgram()
+	if err != nil {
+		slog.Error("Failed to record loyalty points", "order", order.ID, "error", err)
+		return
+	}
+
+	loyaltyMu.Lock()
+	defer loyaltyMu.Unlock()
+
+	repo := dal.NewLoyaltyRepository()
+	entries, err := repo.ReadLoyaltyLedger()
+	if err != nil {
+		slog.Error("Failed to record loyalty points", "order", order.ID, "error", err)
+		return
+	}
+	// Заказ начисляет баллы один раз, даже если его закрыли повторно
+	if slices.ContainsFunc(entries, func(entry models.LoyaltyEntry) bool {
+		return entry.Kind == LoyaltyEarn && entry.OrderID == order.ID
+	}) {
+		return
+	}
+	now := time.Now()
+	entry := models.LoyaltyEntry{
+		ID:         randomHex(6),
+		CustomerID: order.CustomerID,
+		Kind:       LoyaltyEarn,
+		Unit:       loyaltyUnit(program),
+		Points:     order.LoyaltyEarned,
+		Remaining:  order.LoyaltyEarned,
+		OrderID:    order.ID,
+		CreatedAt:  now.Format(time.DateTime),
+	}
+	if program.ExpiryDays > 0 {
+		entry.ExpiresAt = now.AddDate(0, 0, program.ExpiryDays).Format(time.DateOnly)
+	}
+	if err := repo.WriteLoyaltyLedger(append(entries, entry)); err != nil {
+		slog.Error("Failed to record loyalty points", "order", order.ID, "error", err)
+	}
+}
+
+func validateLoyaltyProgram(program models.LoyaltyProgram) error {
+	v := &ValidationError{}
+	switch program.Mode {
+	case LoyaltyOff:
+	case LoyaltyPoints:
+		if program.PointsPerUnit < 0 {
+			v.Add(Pointer("points_per_unit"), "points_per_unit cannot be negative")
+		}
+		if program.PointsPerUnit == 0 && len(program.Rules) == 0 {
+			v.Add(Pointer("points_per_unit"), "points_per_unit or rules are needed to earn points")
+		}
+		validatePrice(v, Pointer("point_value"), program.PointValue)
+		if program.MinRedeem < 0 {
+			v.Add(Pointer("min_redeem"), "min_redeem cannot be negative")
+		}
+		for i, rule := range program.Rules {
+			if rule.Name == "" {
+				v.Add(Pointer("rules", i, "name"), "name cannot be empty")
+			}
+			if rule.PointsPerItem < 0 || rule.BonusPoints < 0 || rule.MinSpend.Amount < 0 {
+				v.Add(Pointer("rules", i), "points and min_spend cannot be negative")
+			} else if rule.PointsPerItem == 0 && rule.BonusPoints == 0 {
+				v.Add(Pointer("rules", i), "rule gives no points")
+			}
+			if err := validateRuleTargets(v, rule.Targets, "rules", i, "targets"); err != nil {
+				return err
+			}
+		}
+	case LoyaltyStamps:
+		if program.StampsForReward < 1 {
+			v.Add(Pointer("stamps_for_reward"), "stamps_for_reward has to be at least 1")
+		}
+		if err := validateRuleTargets(v, program.Stamps, "stamps"); err != nil {
+			return err
+		}
+	default:
+		v.Add(Pointer("mode"), "mode has to be %q, %q or %q", LoyaltyOff, LoyaltyPoints, LoyaltyStamps)
+	}
+	if program.ExpiryDays < 0 {
+		v.Add(Pointer("expiry_days"), "expiry_days cannot be negative")
+	}
+	return v.Err()
+}
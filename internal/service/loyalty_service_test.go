@@ -0,0 +1,27 @@
+package service
+
+import (
+	"hot-coffee1/internal/dal"
+	"hot-coffee1/models"
+	"testing"
+)
+
+func TestRecordLoyaltyEarnedOncePerOrder(t *testing.T) {
+	resetData(t)
+	program := models.LoyaltyProgram{Mode: LoyaltyPoints, PointsPerUnit: 10, PointValue: models.NewMoney(1)}
+	if err := dal.NewLoyaltyRepository().WriteLoyaltyProgram(program); err != nil {
+		t.Fatal(err)
+	}
+
+	order := models.Order{ID: "order1", CustomerID: "c1", LoyaltyEarned: 35}
+	recordLoyaltyEarned(order)
+	recordLoyaltyEarned(order)
+
+	entries, err := dal.NewLoyaltyRepository().ReadLoyaltyLedger()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Points != 35 {
+		t.Fatalf("ledger = %+v, want one earn of 35", entries)
+	}
+}
@@ -0,0 +1,68 @@
+package service
+
+import (
+	"hot-coffee1/internal/config"
+	"log"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+// seedFiles are copied from the repository's data directory before every
+// test that calls resetData.
+var seedFiles = []string{"menu_items.json", "inventory.json"}
+
+var seedDir, dataDir string
+
+func TestMain(m *testing.M) {
+	root, err := os.Getwd()
+	if err != nil {
+		log.Fatal(err)
+	}
+	seedDir = filepath.Join(root, "..", "..", "data")
+
+	// The storage directory has to be inside the working directory.
+	work, err := os.MkdirTemp("", "hot-coffee-service")
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := os.Chdir(work); err != nil {
+		log.Fatal(err)
+	}
+	os.Args = append(os.Args, "--dir", "data")
+	if err := config.ConfigLoad(); err != nil {
+		log.Fatal(err)
+	}
+	dataDir = config.GetStoragePath()
+
+	code := m.Run()
+	os.RemoveAll(work)
+	os.Exit(code)
+}
+
+// resetData empties the storage directory and copies the seed menu and
+// inventory back in.
+func resetData(t *testing.T) {
+	t.Helper()
+	entries, err := os.ReadDir(dataDir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, entry := range entries {
+		if entry.Name() == "auth_secret" {
+			continue
+		}
+		if err := os.Remove(filepath.Join(dataDir, entry.Name())); err != nil {
+			t.Fatal(err)
+		}
+	}
+	for _, name := range seedFiles {
+		data, err := os.ReadFile(filepath.Join(seedDir, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dataDir, name), data, 0o644); err != nil {
+			t.Fatal(err)
+		}
+	}
+}
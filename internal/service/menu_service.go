@@ -1,25 +1,48 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"hot-coffee1/internal/dal"
 	"hot-coffee1/models"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"time"
 )
 
+// menuMu serialises the methods that read, change and write back the menu,
+// request handlers and the price activation alike.
+var menuMu sync.Mutex
+
 type Menu struct {
 	cacheMenu   []models.MenuItem
 	takenIDMenu map[string]int
 }
 
+// MenuFilter narrows the menu to a category, including its subcategories,
+// and/or a tag, and drops items containing any of ExcludeAllergens.
+type MenuFilter struct {
+	CategoryID       string
+	Tag              string
+	ExcludeAllergens []string
+}
+
 type MenuService interface {
 	LoadMenuCache() error
 	GetAllMenu() ([]models.MenuItem, error)
 	GetMenuByID(id string) (models.MenuItem, error)
-	DeleteMenuItem(id string) error
-	AddNewMenuItem(item models.MenuItem) error
-	ModifyMenuItem(item models.MenuItem) error
-	DeductMenuProduct(ID string, quantity float64) error
+	GetMenuAvailability(filter MenuFilter) ([]models.MenuItem, error)
+	GetMenuItemAvailability(id string) (models.MenuItem, error)
+	SetEightySixed(ctx context.Context, id string, eightySixed bool) (models.MenuItem, error)
+	SchedulePrice(ctx context.Context, productID string, change models.PriceChange) (models.PriceChange, error)
+	CancelScheduledPrice(ctx context.Context, productID, changeID string) error
+	DeleteMenuItem(ctx context.Context, id string) error
+	AddNewMenuItem(ctx context.Context, item models.MenuItem) error
+	ModifyMenuItem(ctx context.Context, item models.MenuItem) error
+	DeductMenuProduct(ctx context.Context, ID string, quantity float64) error
 }
 
 func NewMenuService() MenuService {
@@ -29,10 +52,31 @@ func NewMenuService() MenuService {
 	}
 }
 
+// readMenu reads the menu with the scheduled prices that are due applied
+// in memory; only LoadMenuCache writes them back.
+func readMenu() ([]models.MenuItem, error) {
+	menu, err := dal.NewMenuRepository().ReadMenu()
+	if err != nil {
+		return nil, errors.Join(ErrMenuNotRead, err)
+	}
+	activateScheduledPrices(menu)
+	return menu, nil
+}
+
+// LoadMenuCache loads the menu for a change and saves the scheduled prices
+// that took effect since it was last written. The caller holds menuMu.
 func (m *Menu) LoadMenuCache() error {
 	menu, err := dal.NewMenuRepository().ReadMenu()
 	if err != nil {
-		return errors.Join(ErrInventoryNotRead, err)
+		return errors.Join(ErrMenuNotRead, err)
+	}
+	if changed := activateScheduledPrices(menu); len(changed) > 0 {
+		if err := dal.NewMenuRepository().WriteMenu(menu); err != nil {
+			return err
+		}
+		for i, before := range changed {
+			recordAudit(context.Background(), "menu", menu[i].ID, "price_change", before, menu[i])
+		}
 	}
 	m.cacheMenu = menu
 	m.takenIDMenu = make(map[string]int)
@@ -46,55 +90,56 @@ func (m *Menu) LoadMenuCache() error {
 		if err != nil {
 			return errors.Join(ErrConflict, err)
 		}
-		err = validatePostMenuIngredients(val.Ingredients)
-		if err != nil {
-			return errors.Join(ErrConflict, err)
-		}
 		m.takenIDMenu[val.ID] = i
 	}
 	return nil
 }
 
+// GetAllMenu and GetMenuByID only read, so they leave the cache to the
+// methods that change the menu.
 func (m *Menu) GetAllMenu() ([]models.MenuItem, error) {
-	err := m.LoadMenuCache()
-	if err != nil {
-		return nil, err
-	}
-	return m.cacheMenu, nil
+	return readMenu()
 }
 
 func (m *Menu) GetMenuByID(id string) (models.MenuItem, error) {
-	err := m.LoadMenuCache()
+	menu, err := readMenu()
 	if err != nil {
 		return models.MenuItem{}, err
 	}
-	index, exists := m.takenIDMenu[id]
-	if !exists || index < 0 || index >= len(m.cacheMenu) {
-		return models.MenuItem{}, fmt.Errorf("item with product ID=%s not found", id)
+	index := slices.IndexFunc(menu, func(item models.MenuItem) bool { return item.ID == id })
+	if index < 0 {
+		return models.MenuItem{}, fmt.Errorf("item with product ID=%s %w", id, ErrNotFound)
 	}
-
-	return m.cacheMenu[index], nil
+	return menu[index], nil
 }
 
-func (m *Menu) DeleteMenuItem(id string) error {
+func (m *Menu) DeleteMenuItem(ctx context.Context, id string) error {
+	menuMu.Lock()
+	defer menuMu.Unlock()
+
 	err := m.LoadMenuCache()
 	if err != nil {
 		return err
 	}
 	index, exists := m.takenIDMenu[id]
 	if !exists || index < 0 || index >= len(m.cacheMenu) {
-		return fmt.Errorf("item with product ID=%s not found", id)
+		return fmt.Errorf("item with product ID=%s %w", id, ErrNotFound)
 	}
+	deleted := m.cacheMenu[index]
 	m.cacheMenu = append(m.cacheMenu[:index], m.cacheMenu[index+1:]...)
 
 	err = dal.NewMenuRepository().WriteMenu(m.cacheMenu)
 	if err != nil {
 		return err
 	}
+	recordAudit(ctx, "menu", id, AuditDelete, deleted, nil)
 	return nil
 }
 
-func (m *Menu) AddNewMenuItem(item models.MenuItem) error {
+func (m *Menu) AddNewMenuItem(ctx context.Context, item models.MenuItem) error {
+	menuMu.Lock()
+	defer menuMu.Unlock()
+
 	err := m.LoadMenuCache()
 	if err != nil {
 		return err
@@ -102,56 +147,69 @@ func (m *Menu) AddNewMenuItem(item models.MenuItem) error {
 	if _, exists := m.takenIDMenu[item.ID]; exists {
 		return ErrConflict
 	}
-	if err = validatePostMenu(item); err != nil {
-		return err
-	}
-	err = validatePostMenuIngredients(item.Ingredients)
-	if err != nil {
+	if err = validateMenuItem(item); err != nil {
 		return err
 	}
+	clearComputedFields(&item)
+	item.PriceHistory = []models.PriceChange{{Price: item.Price, EffectiveFrom: time.Now().Format(time.DateTime), ChangedBy: actorName(ctx)}}
+	item.ScheduledPrices = nil
 
 	m.cacheMenu = append(m.cacheMenu, item)
 	if err := dal.NewMenuRepository().WriteMenu(m.cacheMenu); err != nil {
 		return errors.New("failed to save menu item")
 	}
+	recordAudit(ctx, "menu", item.ID, AuditCreate, nil, item)
 
 	return nil
 }
 
-func (m *Menu) ModifyMenuItem(item models.MenuItem) error {
+func (m *Menu) ModifyMenuItem(ctx context.Context, item models.MenuItem) error {
+	menuMu.Lock()
+	defer menuMu.Unlock()
+
 	err := m.LoadMenuCache()
 	if err != nil {
 		return err
 	}
 	index, exists := m.takenIDMenu[item.ID]
 	if !exists || index < 0 || index >= len(m.cacheMenu) {
-		return fmt.Errorf("item with product ID=%s not found", item.ID)
+		return fmt.Errorf("item with product ID=%s %w", item.ID, ErrNotFound)
 	}
-	if err = validatePostMenu(item); err != nil {
-		return err
-	}
-	err = validatePostMenuIngredients(item.Ingredients)
-	if err != nil {
+	if err = validateMenuItem(item); err != nil {
 		return err
 	}
+	// The 86 flag and scheduled prices have their own endpoints, a full
+	// replace keeps them.
+	item.EightySixed = m.cacheMenu[index].EightySixed
+	clearComputedFields(&item)
+	recordPriceChange(ctx, &item, m.cacheMenu[index])
 
 	if m.cacheMenu[index].Description == item.Description &&
 		m.cacheMenu[index].ID == item.ID &&
 		m.cacheMenu[index].Name == item.Name &&
 		m.cacheMenu[index].Price == item.Price &&
-		areMenuItemIngredientsEqual(m.cacheMenu[index].Ingredients, item.Ingredients) {
+		areMenuItemIngredientsEqual(m.cacheMenu[index].Ingredients, item.Ingredients) &&
+		m.cacheMenu[index].CategoryID == item.CategoryID &&
+		slices.Equal(m.cacheMenu[index].Tags, item.Tags) &&
+		slices.Equal(m.cacheMenu[index].Images, item.Images) &&
+		m.cacheMenu[index].DisplayOrder == item.DisplayOrder {
 		return ErrNothingToModify
 	}
 
+	before := m.cacheMenu[index]
 	m.cacheMenu[index] = item
 	if err := dal.NewMenuRepository().WriteMenu(m.cacheMenu); err != nil {
 		return errors.New("failed to modify menu item")
 	}
+	recordAudit(ctx, "menu", item.ID, AuditModify, before, item)
 
 	return nil
 }
 
-func (m *Menu) DeductMenuProduct(ID string, quantity float64) error {
+func (m *Menu) DeductMenuProduct(ctx context.Context, ID string, quantity float64) error {
+	menuMu.Lock()
+	defer menuMu.Unlock()
+
 	i := NewInventoryService()
 	err := m.LoadMenuCache()
 	if err != nil {
@@ -163,17 +221,13 @@ func (m *Menu) DeductMenuProduct(ID string, quantity float64) error {
 	}
 	index, exists := m.takenIDMenu[item.ID]
 	if !exists || index < 0 || index >= len(m.cacheMenu) {
-		return fmt.Errorf("item with product ID=s%s not found", item.ID)
+		return fmt.Errorf("item with product ID=%s %w", item.ID, ErrNotFound)
 	}
 	if err = validatePostMenu(item); err != nil {
 		return err
 	}
-	err = validatePostMenuIngredients(item.Ingredients)
-	if err != nil {
-		return err
-	}
 	for _, ingredient := range item.Ingredients {
-		if err := i.DeductInventoryItem(ingredient.IngredientID, ingredient.Quantity*quantity); err != nil {
+		if err := i.DeductInventoryItem(ctx, ingredient.IngredientID, ingredient.Quantity*quantity); err != nil {
 			return err
 		}
 	}
@@ -183,6 +237,222 @@ func (m *Menu) DeductMenuProduct(ID string, quantity float64) error {
 	return nil
 }
 
+// GetMenuAvailability returns the menu with the fields computed from
+// inventory: Available and MaxMakeable from the stock minus what open
+// orders still need, Allergens and Dietary from the ingredients. Filtering
+// by category sorts the items by display order.
+func (m *Menu) GetMenuAvailability(filter MenuFilter) ([]models.MenuItem, error) {
+	all, err := readMenu()
+	if err != nil {
+		return nil, err
+	}
+	stock, err := loadMenuStock(all)
+	if err != nil {
+		return nil, err
+	}
+	v := &ValidationError{}
+	for j, allergen := range filter.ExcludeAllergens {
+		if !slices.Contains(Allergens, allergen) {
+			v.Add(Pointer("exclude_allergens", j), "unknown allergen %s", allergen)
+		}
+	}
+	if err := v.Err(); err != nil {
+		return nil, err
+	}
+	var categories map[string]bool
+	if filter.CategoryID != "" {
+		if categories, err = categoryWithSubcategories(filter.CategoryID); err != nil {
+			return nil, err
+		}
+	}
+
+	menu := []models.MenuItem{}
+	for _, item := range all {
+		if categories != nil && !categories[item.CategoryID] {
+			continue
+		}
+		if filter.Tag != "" && !slices.Contains(item.Tags, filter.Tag) {
+			continue
+		}
+		item = stock.annotate(item)
+		if slices.ContainsFunc(item.Allergens, func(allergen string) bool { return slices.Contains(filter.ExcludeAllergens, allergen) }) {
+			continue
+		}
+		menu = append(menu, item)
+	}
+	if categories != nil {
+		sortMenuItems(menu)
+	}
+	return menu, nil
+}
+
+func (m *Menu) GetMenuItemAvailability(id string) (models.MenuItem, error) {
+	item, err := m.GetMenuByID(id)
+	if err != nil {
+		return models.MenuItem{}, err
+	}
+	menu, err := readMenu()
+	if err != nil {
+		return models.MenuItem{}, err
+	}
+	stock, err := loadMenuStock(menu)
+	if err != nil {
+		return models.MenuItem{}, err
+	}
+	return stock.annotate(item), nil
+}
+
+// SetEightySixed marks an item as unavailable ("86") or brings it back.
+func (m *Menu) SetEightySixed(ctx context.Context, id string, eightySixed bool) (models.MenuItem, error) {
+	menuMu.Lock()
+	defer menuMu.Unlock()
+
+	if err := m.LoadMenuCache(); err != nil {
+		return models.MenuItem{}, err
+	}
+	index, exists := m.takenIDMenu[id]
+	if !exists || index < 0 || index >= len(m.cacheMenu) {
+		return models.MenuItem{}, fmt.Errorf("item with product ID=%s %w", id, ErrNotFound)
+	}
+	if m.cacheMenu[index].EightySixed == eightySixed {
+		return models.MenuItem{}, ErrNothingToModify
+	}
+
+	before := m.cacheMenu[index]
+	m.cacheMenu[index].EightySixed = eightySixed
+	if err := dal.NewMenuRepository().WriteMenu(m.cacheMenu); err != nil {
+		return models.MenuItem{}, errors.New("failed to modify menu item")
+	}
+	recordAudit(ctx, "menu", id, AuditModify, before, m.cacheMenu[index])
+	return m.GetMenuItemAvailability(id)
+}
+
+// menuStock holds what the computed menu fields are derived from. available
+// is the inventory per ingredient minus the quantities reserved by orders
+// that are not closed yet; stock is only deducted when an order is closed.
+type menuStock struct {
+	available map[string]float64
+	inventory map[string]models.InventoryItem
+}
+
+func loadMenuStock(menu []models.MenuItem) (menuStock, error) {
+	inventory, err := readInventoryByID()
+	if err != nil {
+		return menuStock{}, err
+	}
+	orders, err := dal.NewOrderRepository().ReadOrder()
+	if err != nil {
+		return menuStock{}, errors.Join(ErrOrderNotRead, err)
+	}
+
+	stock := make(map[string]float64, len(inventory))
+	for _, item := range inventory {
+		stock[item.IngredientID] = item.Quantity
+	}
+	recipes := make(map[string][]models.MenuItemIngredient, len(menu))
+	for _, item := range menu {
+		recipes[item.ID] = item.Ingredients
+	}
+	for _, order := range orders {
+		if strings.EqualFold(order.Status, "closed") {
+			continue
+		}
+		for _, line := range order.Items {
+			for _, ingredient := range recipes[line.ProductID] {
+				stock[ingredient.IngredientID] -= ingredient.Quantity * float64(line.Quantity)
+			}
+		}
+	}
+	return menuStock{available: stock, inventory: inventory}, nil
+}
+
+func readInventoryByID() (map[string]models.InventoryItem, error) {
+	inventory, err := dal.NewInventoryRepository().ReadInventory()
+	if err != nil {
+		return nil, errors.Join(ErrInventoryNotRead, err)
+	}
+	byID := make(map[string]models.InventoryItem, len(inventory))
+	for _, item := range inventory {
+		byID[item.IngredientID] = item
+	}
+	return byID, nil
+}
+
+func (s menuStock) annotate(item models.MenuItem) models.MenuItem {
+	var maxMakeable *int
+	for _, ingredient := range item.Ingredients {
+		if ingredient.Quantity <= 0 {
+			continue
+		}
+		count := int(math.Floor(s.available[ingredient.IngredientID] / ingredient.Quantity))
+		if count < 0 {
+			count = 0
+		}
+		if maxMakeable == nil || count < *maxMakeable {
+			maxMakeable = &count
+		}
+	}
+
+	available := !item.EightySixed && (maxMakeable == nil || *maxMakeable > 0)
+	item.Available, item.MaxMakeable = &available, maxMakeable
+	item.Allergens, item.Dietary = menuAllergens(item, s.inventory)
+	item.Nutrition, item.NutritionMissing = menuNutrition(item, s.inventory)
+	return item
+}
+
+// checkOrderAvailability rejects orders for items that are 86'd or that the
+// stock left after open orders cannot cover.
+func checkOrderAvailability(order models.Order) error {
+	m := NewMenuService()
+	menu, err := m.GetAllMenu()
+	if err != nil {
+		return err
+	}
+	stock, err := loadMenuStock(menu)
+	if err != nil {
+		return err
+	}
+
+	for _, line := range order.Items {
+		index := slices.IndexFunc(menu, func(item models.MenuItem) bool { return item.ID == line.ProductID })
+		if index < 0 {
+			return fmt.Errorf("item with product ID=%s %w", line.ProductID, ErrNotFound)
+		}
+		if menu[index].EightySixed {
+			return fmt.Errorf("%w: %s is 86'd", ErrItemUnavailable, line.ProductID)
+		}
+		for _, ingredient := range menu[index].Ingredients {
+			stock.available[ingredient.IngredientID] -= ingredient.Quantity * float64(line.Quantity)
+			if stock.available[ingredient.IngredientID] < 0 {
+				return fmt.Errorf("%w: not enough %s left for %s after open orders", ErrItemUnavailable, ingredient.IngredientID, line.ProductID)
+			}
+		}
+	}
+	return nil
+}
+
+func clearComputedFields(item *models.MenuItem) {
+	item.Available, item.MaxMakeable = nil, nil
+	item.Allergens, item.Dietary = nil, nil
+	item.Nutrition, item.NutritionMissing = nil, nil
+}
+
+func validateMenuItem(item models.MenuItem) error {
+	if err := validatePostMenu(item); err != nil {
+		return err
+	}
+	v := &ValidationError{}
+	if err := validateMenuCategory(v, item); err != nil {
+		return err
+	}
+	inventory, err := readInventoryByID()
+	if err != nil {
+		return err
+	}
+	validateDietaryTags(v, item, inventory)
+	return v.Err()
+}
+
 func areMenuItemIngredientsEqual(a, b []models.MenuItemIngredient) bool {
 	if len(a) != len(b) {
 		return false
@@ -0,0 +1,98 @@
+package service
+
+import (
+	"errors"
+	"hot-coffee1/internal/dal"
+	"hot-coffee1/models"
+	"slices"
+	"strconv"
+)
+
+// MigrateMoney rewrites the data files that hold amounts. Amounts stored as
+// decimal numbers before Money existed are read in the shop currency and
+// written back as minor units; files already migrated are rewritten
+// unchanged, so it is safe to run more than once. Order lines from before
+// price snapshots get the price they were ordered at, and amount promo codes
+// and pricing rules move their decimal value to amount.
+func MigrateMoney() ([]string, error) {
+	menu, err := dal.NewMenuRepository().ReadMenu()
+	if err != nil {
+		return nil, errors.Join(ErrMenuNotRead, err)
+	}
+	orders, err := dal.NewOrderRepository().ReadOrder()
+	if err != nil {
+		return nil, errors.Join(ErrOrderNotRead, err)
+	}
+	promos, err := dal.NewPromoCodeRepository().ReadPromoCodes()
+	if err != nil {
+		return nil, errors.Join(ErrPromoCodeNotRead, err)
+	}
+	rules, err := dal.NewPricingRuleRepository().ReadPricingRules()
+	if err != nil {
+		return nil, errors.Join(ErrPricingRuleNotRead, err)
+	}
+
+	for i := range promos {
+		if promos[i].Kind == PromoAmount && promos[i].Value != 0 {
+			if promos[i].Amount, err = moneyFromValue(promos[i].Value); err != nil {
+				return nil, err
+			}
+			promos[i].Value = 0
+		}
+	}
+	for i := range rules {
+		effect := &rules[i].Effect
+		if (effect.Kind == EffectAmountOff || effect.Kind == EffectFixedPrice) && effect.Value != 0 {
+			if effect.Amount, err = moneyFromValue(effect.Value); err != nil {
+				return nil, err
+			}
+			effect.Value = 0
+		}
+	}
+
+	// Строки старых заказов получают снимок цены, пока позиция есть в меню,
+	// чтобы отчёты не зависели от меню
+	for i := range orders {
+		for j := range orders[i].Items {
+			line := &orders[i].Items[j]
+			index := slices.IndexFunc(menu, func(item models.MenuItem) bool { return item.ID == line.ProductID })
+			if line.Name != "" || !line.UnitPrice.IsZero() || index < 0 {
+				continue
+			}
+			line.Name, line.UnitPrice = menu[index].Name, priceAt(menu[index], orders[i].CreatedAt)
+		}
+	}
+
+	var files []string
+	if len(menu) > 0 {
+		if err := dal.NewMenuRepository().WriteMenu(menu); err != nil {
+			return files, err
+		}
+		files = append(files, "menu_items.json")
+	}
+	if len(orders) > 0 {
+		if err := dal.NewOrderRepository().WriteOrder(orders); err != nil {
+			return files, err
+		}
+		files = append(files, "orders.json")
+	}
+	if len(promos) > 0 {
+		if err := dal.NewPromoCodeRepository().WritePromoCodes(promos); err != nil {
+			return files, err
+		}
+		files = append(files, "promo_codes.json")
+	}
+	if len(rules) > 0 {
+		if err := dal.NewPricingRuleRepository().WritePricingRules(rules); err != nil {
+			return files, err
+		}
+		files = append(files, "pricing_rules.json")
+	}
+	return files, nil
+}
+
+// moneyFromValue converts a decimal value stored before amounts were Money,
+// using its shortest decimal form so that 2.3 stays 230 cents.
+func moneyFromValue(value float64) (models.Money, error) {
+	return models.ParseMoney(strconv.FormatFloat(value, 'f', -1, 64))
+}
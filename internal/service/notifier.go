@@ -0,0 +1,116 @@
+package service
+
+import (
+	"fmt"
+	"hot-coffee1/internal/config"
+	"hot-coffee1/models"
+	"log/slog"
+	"net/smtp"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Notifier tells someone about a stock alert. Notifiers run in the
+// background, so a slow one never holds up the request that raised the
+// alert.
+type Notifier interface {
+	Name() string
+	Notify(alert models.Alert, item models.InventoryItem) error
+}
+
+var (
+	notifiersMu sync.Mutex
+	notifiers   []Notifier
+)
+
+func RegisterNotifier(n Notifier) {
+	notifiersMu.Lock()
+	defer notifiersMu.Unlock()
+	notifiers = append(notifiers, n)
+}
+
+// ConfigureNotifiers registers the built-in notifiers named by
+// --alert-notifiers.
+func ConfigureNotifiers() {
+	for _, name := range config.GetNotifiers() {
+		switch name {
+		case "log":
+			RegisterNotifier(LogNotifier{})
+		case "webhook":
+			RegisterNotifier(EventNotifier{})
+		case "smtp":
+			relay, from, to := config.GetSMTP()
+			RegisterNotifier(SMTPNotifier{Relay: relay, From: from, To: to})
+		}
+	}
+}
+
+func notifyAlert(alert models.Alert, item models.InventoryItem) {
+	notifiersMu.Lock()
+	registered := append([]Notifier(nil), notifiers...)
+	notifiersMu.Unlock()
+
+	for _, n := range registered {
+		go func() {
+			if err := n.Notify(alert, item); err != nil {
+				slog.Error("Alert notifier failed", "notifier", n.Name(), "alert", alert.ID, "error", err)
+			}
+		}()
+	}
+}
+
+type LogNotifier struct{}
+
+func (LogNotifier) Name() string { return "log" }
+
+func (LogNotifier) Notify(alert models.Alert, item models.InventoryItem) error {
+	slog.Warn("Stock alert", "kind", alert.Kind, "ingredient", item.IngredientID,
+		"quantity", alert.Quantity, "threshold", alert.Threshold, "unit", alert.Unit)
+	return nil
+}
+
+// EventNotifier publishes the alert on the event bus, which is how it
+// reaches webhook subscribers of inventory.low_stock and
+// inventory.out_of_stock.
+type EventNotifier struct{}
+
+func (EventNotifier) Name() string { return "webhook" }
+
+func (EventNotifier) Notify(alert models.Alert, item models.InventoryItem) error {
+	eventType := EventInventoryLowStock
+	if alert.Kind == AlertOutOfStock {
+		eventType = EventInventoryOutOfStock
+	}
+	Events.Publish(Event{Type: eventType, Subject: item.IngredientID, Status: alert.Status, Data: alert})
+	return nil
+}
+
+// SMTPNotifier mails alerts through a local relay without authentication.
+type SMTPNotifier struct {
+	Relay string
+	From  string
+	To    []string
+}
+
+func (SMTPNotifier) Name() string { return "smtp" }
+
+var headerUnsafe = strings.NewReplacer("\r", " ", "\n", " ")
+
+func (n SMTPNotifier) Notify(alert models.Alert, item models.InventoryItem) error {
+	// Имя приходит из API: перевод строки в теме добавил бы свои заголовки
+	name := headerUnsafe.Replace(item.Name)
+	subject := fmt.Sprintf("[hot-coffee] %s: %s", strings.ReplaceAll(alert.Kind, "_", " "), name)
+	body := fmt.Sprintf("%s (%s) is down to %v %s", name, headerUnsafe.Replace(item.IngredientID), alert.Quantity, alert.Unit)
+	if alert.Kind == AlertLowStock {
+		body += fmt.Sprintf(", at or below the threshold of %v %s", alert.Threshold, alert.Unit)
+	}
+	body += ".\r\n\r\nAcknowledge it with POST /api/v1/alerts/" + alert.ID + "/acknowledge.\r\n"
+
+	message := "From: " + n.From + "\r\n" +
+		"To: " + strings.Join(n.To, ", ") + "\r\n" +
+		"Subject: " + subject + "\r\n" +
+		"Date: " + time.Now().Format(time.RFC1123Z) + "\r\n" +
+		"Content-Type: text/plain; charset=utf-8\r\n\r\n" + body
+	return smtp.SendMail(n.Relay, nil, n.From, n.To, []byte(message))
+}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"hot-coffee1/models"
+	"math"
+)
+
+// nutritionBase is how many units of an ingredient its nutrition data
+// describes: 100 for grams and millilitres, one for everything counted.
+func nutritionBase(unit string) float64 {
+	switch unit {
+	case "g", "ml":
+		return 100
+	}
+	return 1
+}
+
+// menuNutrition sums the nutrition of one serving from the recipe
+// quantities. Ingredients without data are returned so the total is not
+// mistaken for a complete one; nil means no ingredient has data.
+func menuNutrition(item models.MenuItem, inventory map[string]models.InventoryItem) (*models.Nutrition, []string) {
+	var total *models.Nutrition
+	var missing []string
+	for _, ingredient := range item.Ingredients {
+		stock, ok := inventory[ingredient.IngredientID]
+		if !ok || stock.Nutrition == nil {
+			missing = append(missing, ingredient.IngredientID)
+			continue
+		}
+		if total == nil {
+			total = &models.Nutrition{}
+		}
+		factor := ingredient.Quantity / nutritionBase(stock.Unit)
+		total.Calories += stock.Nutrition.Calories * factor
+		total.Sugar += stock.Nutrition.Sugar * factor
+		total.Fat += stock.Nutrition.Fat * factor
+		total.Protein += stock.Nutrition.Protein * factor
+		total.Caffeine += stock.Nutrition.Caffeine * factor
+	}
+	if total == nil {
+		return nil, nil
+	}
+
+	round := func(value float64) float64 { return math.Round(value*10) / 10 }
+	total.Calories, total.Sugar, total.Fat = round(total.Calories), round(total.Sugar), round(total.Fat)
+	total.Protein, total.Caffeine = round(total.Protein), round(total.Caffeine)
+	return total, missing
+}
@@ -1,15 +1,30 @@
 package service
 
 import (
+	"context"
 	"errors"
 	"fmt"
 	"hot-coffee1/internal/dal"
 	"hot-coffee1/models"
+	"slices"
 	"strconv"
 	"strings"
+	"sync"
 	"time"
 )
 
+// Preparation status of a single order line. Orders created before lines
+// had a status count as pending.
+const (
+	ItemPending = "pending"
+	ItemStarted = "started"
+	ItemDone    = "done"
+)
+
+// ordersMu serialises the methods that read, change and write back orders,
+// request handlers and the pre-order release alike.
+var ordersMu sync.Mutex
+
 type Order struct {
 	cacheOrders   map[string]models.Order
 	takenIDOrders map[string]int
@@ -18,10 +33,20 @@ type Order struct {
 type OrderService interface {
 	GetAllOrders() ([]models.Order, error)
 	GetOrderByID(ID string) (models.Order, error)
-	AddNewOrder(order models.Order) error
-	CloseOrder(ID string) error
-	DeleteOrder(ID string) error
-	ModifyOrder(order models.Order, ID string) error
+	AddNewOrder(ctx context.Context, order models.Order) (models.Order, error)
+	CloseOrder(ctx context.Context, ID string) error
+	StartOrderItem(ctx context.Context, ID, productID string) (models.Order, error)
+	FinishOrderItem(ctx context.Context, ID, productID string) (models.Order, error)
+	BumpOrder(ctx context.Context, ID string) (models.Order, error)
+	DeleteOrder(ctx context.Context, ID string) error
+	ModifyOrder(ctx context.Context, order models.Order, ID string) error
+	ApplyDiscount(ctx context.Context, ID, code string) (models.Order, error)
+	AddPayment(ctx context.Context, ID string, payment models.Payment) (models.Payment, error)
+	RefundPayment(ctx context.Context, ID, paymentID string) (models.Order, error)
+	SetTip(ctx context.Context, ID string, tip models.OrderTip) (models.Order, error)
+	RemoveTip(ctx context.Context, ID string) (models.Order, error)
+	RemoveDiscount(ctx context.Context, ID, code string) (models.Order, error)
+	ReleaseOrder(ctx context.Context, ID string) (models.Order, error)
 	LoadOrdersCache() error
 }
 
@@ -35,24 +60,31 @@ func NewOrderService() OrderService {
 func (o *Order) findOrderIndexByID(ID string) (int, error) {
 	_, exists := o.takenIDOrders[ID]
 	if !exists {
-		return -1, fmt.Errorf("order with ID %s not found", ID)
+		return -1, fmt.Errorf("order with ID %s %w", ID, ErrNotFound)
 	}
 	return 0, nil
 }
 
-func (o *Order) LoadOrdersCache() error {
+func readOrders() ([]models.Order, error) {
 	orders, err := dal.NewOrderRepository().ReadOrder()
 	if err != nil {
-		return errors.Join(ErrOrderNotRead, err)
+		return nil, errors.Join(ErrOrderNotRead, err)
 	}
+	if err := validateOrders(orders); err != nil {
+		return nil, err
+	}
+	return orders, nil
+}
 
-	o.cacheOrders = make(map[string]models.Order)
-	o.takenIDOrders = make(map[string]int)
-
-	if err = validateOrders(orders); err != nil {
+func (o *Order) LoadOrdersCache() error {
+	orders, err := readOrders()
+	if err != nil {
 		return err
 	}
 
+	o.cacheOrders = make(map[string]models.Order)
+	o.takenIDOrders = make(map[string]int)
+
 	for _, val := range orders {
 		o.cacheOrders[val.ID] = val
 		o.takenIDOrders[val.ID] = 1
@@ -61,40 +93,37 @@ func (o *Order) LoadOrdersCache() error {
 	return nil
 }
 
+// GetAllOrders and GetOrderByID read the file themselves and leave the
+// cache to the methods that change orders.
 func (o *Order) GetAllOrders() ([]models.Order, error) {
-	err := o.LoadOrdersCache()
+	orders, err := readOrders()
 	if err != nil {
 		return nil, err
 	}
-
-	if len(o.cacheOrders) == 0 {
-		return nil, errors.New("no orders in orders storage")
+	if orders == nil {
+		orders = []models.Order{}
 	}
-
-	ordersSlice := make([]models.Order, 0, len(o.cacheOrders))
-	for _, order := range o.cacheOrders {
-		ordersSlice = append(ordersSlice, order)
-	}
-
-	return ordersSlice, nil
+	return orders, nil
 }
 
 func (o *Order) GetOrderByID(ID string) (models.Order, error) {
-	if err := o.LoadOrdersCache(); err != nil {
+	orders, err := readOrders()
+	if err != nil {
 		return models.Order{}, err
 	}
-
-	order, exists := o.cacheOrders[ID]
-	if !exists {
-		return models.Order{}, fmt.Errorf("order with ID %s not found", ID)
+	index := slices.IndexFunc(orders, func(order models.Order) bool { return order.ID == ID })
+	if index < 0 {
+		return models.Order{}, fmt.Errorf("order with ID %s %w", ID, ErrNotFound)
 	}
-
-	return order, nil
+	return orders[index], nil
 }
 
-func (o *Order) AddNewOrder(order models.Order) error {
+func (o *Order) AddNewOrder(ctx context.Context, order models.Order) (models.Order, error) {
+	ordersMu.Lock()
+	defer ordersMu.Unlock()
+
 	if err := o.LoadOrdersCache(); err != nil {
-		return err
+		return models.Order{}, err
 	}
 
 	var lastID int
@@ -103,7 +132,7 @@ func (o *Order) AddNewOrder(order models.Order) error {
 			idStr := ord.ID[5:]
 			idNum, err := strconv.Atoi(idStr)
 			if err != nil {
-				return fmt.Errorf("invalid ID format: %v", err)
+				return models.Order{}, fmt.Errorf("invalid ID format: %v", err)
 			}
 			if idNum > lastID {
 				lastID = idNum
@@ -114,22 +143,63 @@ func (o *Order) AddNewOrder(order models.Order) error {
 	newID := fmt.Sprintf("order%d", lastID+1)
 	order.ID = newID
 
+	// Имя клиента берётся из профиля, поэтому связываем до проверки
+	if err := linkCustomer(&order); err != nil {
+		return models.Order{}, err
+	}
+	if err := validateOrder(order); err != nil {
+		return models.Order{}, err
+	}
 	// ✅ ДОБАВЛЯЕМ ЭТУ ПРОВЕРКУ:
 	for _, item := range order.Items {
 		if err := validateDeductCheckIngredients(item.ProductID, float64(item.Quantity)); err != nil {
-			return err
+			return models.Order{}, err
 		}
 	}
+	if err := checkOrderAvailability(order); err != nil {
+		return models.Order{}, err
+	}
 
-	if err := validateOrder(order); err != nil {
-		return err
+	if err := snapshotPrices(order.Items, nil); err != nil {
+		return models.Order{}, err
 	}
 
 	order.Status = "open"
 	order.CreatedAt = time.Now().Format(time.DateTime)
+	for i := range order.Items {
+		order.Items[i].Status, order.Items[i].StartedAt, order.Items[i].DoneAt = ItemPending, "", ""
+	}
+	if order.PickupAt != "" {
+		if err := schedulePickup(&order, o.cacheOrders); err != nil {
+			return models.Order{}, err
+		}
+	}
 
 	if _, exists := o.takenIDOrders[order.ID]; exists {
-		return ErrConflict
+		return models.Order{}, ErrConflict
+	}
+
+	// Клиент передаёт только коды, суммы считаем сами
+	codes := make([]string, 0, len(order.Discounts))
+	points := 0
+	for _, discount := range order.Discounts {
+		if normalizePromoCode(discount.Code) == LoyaltyCode {
+			points += discount.Points
+			continue
+		}
+		codes = append(codes, discount.Code)
+	}
+	order.Discounts, order.Payments, order.Tip, order.LoyaltyEarned = nil, nil, nil, 0
+	if err := redeemPromoCodes(&order, codes); err != nil {
+		return models.Order{}, err
+	}
+	if err := redeemLoyalty(&order, points); err != nil {
+		releasePromoCodes(order.Discounts)
+		return models.Order{}, err
+	}
+	if err := calculateTotals(&order); err != nil {
+		releaseDiscounts(order.ID, order.Discounts)
+		return models.Order{}, err
 	}
 
 	o.cacheOrders[order.ID] = order
@@ -140,13 +210,19 @@ func (o *Order) AddNewOrder(order models.Order) error {
 	}
 
 	if err := dal.NewOrderRepository().WriteOrder(ordersSlice); err != nil {
-		return err
+		releaseDiscounts(order.ID, order.Discounts)
+		return models.Order{}, err
 	}
+	recordAudit(ctx, "order", order.ID, AuditCreate, nil, order)
+	publishOrder(EventOrderCreated, order)
 
-	return nil
+	return order, nil
 }
 
-func (o *Order) CloseOrder(ID string) error {
+func (o *Order) CloseOrder(ctx context.Context, ID string) error {
+	ordersMu.Lock()
+	defer ordersMu.Unlock()
+
 	m := NewMenuService()
 
 	// ✅ Сначала загружаем кэш
@@ -157,14 +233,19 @@ func (o *Order) CloseOrder(ID string) error {
 	// ✅ Потом ищем заказ
 	order, exists := o.cacheOrders[ID]
 	if !exists {
-		return fmt.Errorf("order with ID %s not found", ID)
+		return fmt.Errorf("order with ID %s %w", ID, ErrNotFound)
+	}
+	before := order
+
+	if strings.EqualFold(order.Status, OrderScheduled) {
+		return errScheduled(order)
 	}
 
 	// ✅ Проверка без учёта регистра
-	if strings.ToLower(order.Status) == "open" {
+	if status := strings.ToLower(order.Status); status == "open" || status == "ready" {
 		order.Status = "closed"
 	} else {
-		return fmt.Errorf("order is already closed")
+		return ErrOrderClosed
 	}
 
 	if err := o.LoadOrdersCache(); err != nil {
@@ -179,11 +260,24 @@ func (o *Order) CloseOrder(ID string) error {
 		return err
 	}
 
+	// Итоги фиксируются по налогам на момент закрытия
+	if err := calculateTotals(&order); err != nil {
+		return err
+	}
+	if order.Totals.Due.Amount > 0 {
+		return fmt.Errorf("%w: %s of %s is still due", ErrOrderNotPaid, order.Totals.Due, order.Totals.Total)
+	}
+	earned, err := loyaltyEarned(order)
+	if err != nil {
+		return err
+	}
+	order.LoyaltyEarned = earned
+
 	for _, product := range order.Items {
 		if err := validateDeductCheckIngredients(product.ProductID, float64(product.Quantity)); err != nil {
 			return err
 		}
-		if err := m.DeductMenuProduct(product.ProductID, float64(product.Quantity)); err != nil {
+		if err := m.DeductMenuProduct(ctx, product.ProductID, float64(product.Quantity)); err != nil {
 			return err
 		}
 	}
@@ -198,19 +292,33 @@ func (o *Order) CloseOrder(ID string) error {
 	if err := dal.NewOrderRepository().WriteOrder(ordersSlice); err != nil {
 		return err
 	}
+	recordLoyaltyEarned(order)
+	recordAudit(ctx, "order", ID, AuditClose, before, order)
+	publishOrder(EventOrderClosed, order)
 
 	return nil
 }
 
-func (o *Order) DeleteOrder(ID string) error {
+func (o *Order) DeleteOrder(ctx context.Context, ID string) error {
+	ordersMu.Lock()
+	defer ordersMu.Unlock()
+
 	if err := o.LoadOrdersCache(); err != nil {
 		return err
 	}
 
 	// Проверяем наличие заказа в карте
-	_, exists := o.cacheOrders[ID]
+	deleted, exists := o.cacheOrders[ID]
 	if !exists {
-		return fmt.Errorf("order with ID %s not found", ID)
+		return fmt.Errorf("order with ID %s %w", ID, ErrNotFound)
+	}
+
+	paid, err := capturedPayments(deleted)
+	if err != nil {
+		return err
+	}
+	if strings.ToLower(deleted.Status) != "closed" && paid.Amount > 0 {
+		return fmt.Errorf("%w: order %s has payments, refund them first", ErrConflict, ID)
 	}
 
 	// Удаляем заказ из карты
@@ -226,11 +334,19 @@ func (o *Order) DeleteOrder(ID string) error {
 	if err := dal.NewOrderRepository().WriteOrder(ordersSlice); err != nil {
 		return err
 	}
+	if strings.ToLower(deleted.Status) != "closed" {
+		releaseDiscounts(ID, deleted.Discounts)
+	}
+	recordAudit(ctx, "order", ID, AuditDelete, deleted, nil)
+	publishOrder(EventOrderDeleted, deleted)
 
 	return nil
 }
 
-func (o *Order) ModifyOrder(order models.Order, ID string) error {
+func (o *Order) ModifyOrder(ctx context.Context, order models.Order, ID string) error {
+	ordersMu.Lock()
+	defer ordersMu.Unlock()
+
 	if err := o.LoadOrdersCache(); err != nil {
 		return err
 	}
@@ -238,17 +354,59 @@ func (o *Order) ModifyOrder(order models.Order, ID string) error {
 	// Проверяем наличие заказа в карте
 	existingOrder, exists := o.cacheOrders[ID]
 	if !exists {
-		return fmt.Errorf("order with ID %s not found", ID)
+		return fmt.Errorf("order with ID %s %w", ID, ErrNotFound)
 	}
 
 	order = orderInit(order, existingOrder)
-	if err := validateModifying(order, existingOrder); err != nil {
+	// ID из пути: по нему заказ исключается из занятости своего слота
+	order.ID = ID
+	// Закрытие только через CloseOrder: там проверка оплаты, списание и события
+	if strings.EqualFold(order.Status, "closed") && !strings.EqualFold(existingOrder.Status, "closed") {
+		return fmt.Errorf("%w: order %s has to be closed with POST /orders/%s/close", ErrConflict, ID, ID)
+	}
+	// Повторное закрытие списало бы склад и начислило баллы ещё раз
+	if strings.EqualFold(existingOrder.Status, "closed") && !strings.EqualFold(order.Status, "closed") {
+		return fmt.Errorf("%w: order %s is closed and cannot be reopened", ErrConflict, ID)
+	}
+	if err := linkCustomer(&order); err != nil {
 		return err
 	}
-
 	if err := validateOrder(order); err != nil {
 		return err
 	}
+	keepItemStatuses(order.Items, existingOrder.Items)
+	if err := snapshotPrices(order.Items, existingOrder.Items); err != nil {
+		return err
+	}
+	// Новое время или новый состав заказа проверяем по расписанию заново
+	itemsChanged := !areOrderItemsEqual(order.Items, existingOrder.Items)
+	switch {
+	case strings.EqualFold(order.Status, "closed"):
+	case order.PickupAt != existingOrder.PickupAt || strings.EqualFold(order.Status, OrderScheduled) && itemsChanged:
+		if err := schedulePickup(&order, o.cacheOrders); err != nil {
+			return err
+		}
+	case order.PickupAt != "" && itemsChanged:
+		// Заказ уже на кухне: время не проверяем, но место в слоте нужно
+		schedule, err := readPickupSchedule()
+		if err != nil {
+			return err
+		}
+		if err := checkPickupCapacity(order, o.cacheOrders, schedule); err != nil {
+			return err
+		}
+	}
+	order.Discounts, order.Payments, order.Tip = existingOrder.Discounts, existingOrder.Payments, existingOrder.Tip
+	order.LoyaltyEarned = existingOrder.LoyaltyEarned
+	if err := calculateDiscounts(&order); err != nil {
+		return err
+	}
+	if err := calculateTotals(&order); err != nil {
+		return err
+	}
+	if err := validateModifying(order, existingOrder); err != nil {
+		return err
+	}
 
 	o.cacheOrders[ID] = order
 
@@ -262,10 +420,262 @@ func (o *Order) ModifyOrder(order models.Order, ID string) error {
 	if err := dal.NewOrderRepository().WriteOrder(ordersSlice); err != nil {
 		return errors.New("failed to modify order")
 	}
+	recordAudit(ctx, "order", ID, AuditModify, existingOrder, order)
+	if order.Status != existingOrder.Status {
+		publishOrder(EventOrderStatusChanged, order)
+	} else {
+		publishOrder(EventOrderModified, order)
+	}
 
 	return nil
 }
 
+// ApplyDiscount redeems a promo code on an order that is not closed yet.
+func (o *Order) ApplyDiscount(ctx context.Context, ID, code string) (models.Order, error) {
+	redeemed := false
+	order, err := o.updateCharges(ctx, ID, "discount_applied", func(order *models.Order) error {
+		if err := redeemPromoCodes(order, []string{code}); err != nil {
+			return err
+		}
+		redeemed = true
+		return nil
+	})
+	if err != nil && redeemed {
+		releasePromoCodes([]models.OrderDiscount{{Code: code}})
+	}
+	return order, err
+}
+
+// RemoveDiscount takes a promo code off an order and gives its use back.
+func (o *Order) RemoveDiscount(ctx context.Context, ID, code string) (models.Order, error) {
+	var removed models.OrderDiscount
+	order, err := o.updateCharges(ctx, ID, "discount_removed", func(order *models.Order) error {
+		code = normalizePromoCode(code)
+		index := slices.IndexFunc(order.Discounts, func(discount models.OrderDiscount) bool { return discount.Code == code })
+		if index < 0 {
+			return fmt.Errorf("discount %s on order %s %w", code, order.ID, ErrNotFound)
+		}
+		removed = order.Discounts[index]
+		order.Discounts = slices.Delete(order.Discounts, index, index+1)
+		return calculateDiscounts(order)
+	})
+	if err != nil {
+		return models.Order{}, err
+	}
+	releaseDiscounts(ID, []models.OrderDiscount{removed})
+	return order, nil
+}
+
+func (o *Order) updateCharges(ctx context.Context, ID, action string, update func(*models.Order) error) (models.Order, error) {
+	ordersMu.Lock()
+	defer ordersMu.Unlock()
+
+	if err := o.LoadOrdersCache(); err != nil {
+		return models.Order{}, err
+	}
+	existingOrder, exists := o.cacheOrders[ID]
+	if !exists {
+		return models.Order{}, fmt.Errorf("order with ID %s %w", ID, ErrNotFound)
+	}
+	if status := strings.ToLower(existingOrder.Status); status != "open" && status != "ready" && status != OrderScheduled {
+		return models.Order{}, ErrOrderClosed
+	}
+
+	order := existingOrder
+	order.Discounts = append([]models.OrderDiscount(nil), existingOrder.Discounts...)
+	if err := update(&order); err != nil {
+		return models.Order{}, err
+	}
+	if err := calculateTotals(&order); err != nil {
+		return models.Order{}, err
+	}
+
+	o.cacheOrders[ID] = order
+	ordersSlice := make([]models.Order, 0, len(o.cacheOrders))
+	for _, v := range o.cacheOrders {
+		ordersSlice = append(ordersSlice, v)
+	}
+	if err := dal.NewOrderRepository().WriteOrder(ordersSlice); err != nil {
+		return models.Order{}, err
+	}
+	recordAudit(ctx, "order", ID, action, existingOrder, order)
+	publishOrder(EventOrderModified, order)
+	return order, nil
+}
+
+// StartOrderItem and FinishOrderItem move one line of an order through
+// preparation. Once every line is done the order becomes ready.
+func (o *Order) StartOrderItem(ctx context.Context, ID, productID string) (models.Order, error) {
+	return o.updatePreparation(ctx, ID, productID, "item_started", EventOrderItemStarted, func(order *models.Order, now string) error {
+		item, err := findOrderItem(order, productID)
+		if err != nil {
+			return err
+		}
+		if item.Status == ItemDone {
+			return fmt.Errorf("%w: item %s is already done", ErrConflict, productID)
+		}
+		if item.Status != ItemStarted {
+			item.Status, item.StartedAt = ItemStarted, now
+		}
+		return nil
+	})
+}
+
+func (o *Order) FinishOrderItem(ctx context.Context, ID, productID string) (models.Order, error) {
+	return o.updatePreparation(ctx, ID, productID, "item_done", EventOrderItemDone, func(order *models.Order, now string) error {
+		item, err := findOrderItem(order, productID)
+		if err != nil {
+			return err
+		}
+		if item.Status == ItemDone {
+			return nil
+		}
+		if item.StartedAt == "" {
+			item.StartedAt = now
+		}
+		item.Status, item.DoneAt = ItemDone, now
+		return nil
+	})
+}
+
+// BumpOrder clears an order off the kitchen display: every line not done
+// yet is marked done and the order becomes ready.
+func (o *Order) BumpOrder(ctx context.Context, ID string) (models.Order, error) {
+	return o.updatePreparation(ctx, ID, "", "bump", "", func(order *models.Order, now string) error {
+		for i := range order.Items {
+			item := &order.Items[i]
+			if item.Status == ItemDone {
+				continue
+			}
+			if item.StartedAt == "" {
+				item.StartedAt = now
+			}
+			item.Status, item.DoneAt = ItemDone, now
+		}
+		return nil
+	})
+}
+
+func (o *Order) updatePreparation(ctx context.Context, ID, productID, action, eventType string, update func(*models.Order, string) error) (models.Order, error) {
+	ordersMu.Lock()
+	defer ordersMu.Unlock()
+
+	if err := o.LoadOrdersCache(); err != nil {
+		return models.Order{}, err
+	}
+	existingOrder, exists := o.cacheOrders[ID]
+	if !exists {
+		return models.Order{}, fmt.Errorf("order with ID %s %w", ID, ErrNotFound)
+	}
+	if strings.EqualFold(existingOrder.Status, OrderScheduled) {
+		return models.Order{}, errScheduled(existingOrder)
+	}
+	if strings.ToLower(existingOrder.Status) != "open" && strings.ToLower(existingOrder.Status) != "ready" {
+		return models.Order{}, ErrOrderClosed
+	}
+
+	order := existingOrder
+	order.Items = append([]models.OrderItem(nil), existingOrder.Items...)
+	if err := update(&order, time.Now().Format(time.DateTime)); err != nil {
+		return models.Order{}, err
+	}
+	allDone := true
+	for _, item := range order.Items {
+		allDone = allDone && item.Status == ItemDone
+	}
+	if allDone {
+		order.Status = "ready"
+	}
+	if areOrderItemsEqual(order.Items, existingOrder.Items) && order.Status == existingOrder.Status {
+		return order, nil
+	}
+
+	o.cacheOrders[ID] = order
+	ordersSlice := make([]models.Order, 0, len(o.cacheOrders))
+	for _, v := range o.cacheOrders {
+		ordersSlice = append(ordersSlice, v)
+	}
+	if err := dal.NewOrderRepository().WriteOrder(ordersSlice); err != nil {
+		return models.Order{}, err
+	}
+	recordAudit(ctx, "order", ID, action, existingOrder, order)
+
+	if eventType != "" {
+		Events.Publish(Event{Type: eventType, Subject: ID, Item: productID, Status: order.Status, Data: order})
+	}
+	if order.Status != existingOrder.Status {
+		publishOrder(EventOrderStatusChanged, order)
+	}
+	return order, nil
+}
+
+func findOrderItem(order *models.Order, productID string) (*models.OrderItem, error) {
+	for i := range order.Items {
+		if order.Items[i].ProductID == productID {
+			return &order.Items[i], nil
+		}
+	}
+	return nil, fmt.Errorf("item %s in order %s %w", productID, order.ID, ErrNotFound)
+}
+
+// keepItemStatuses carries preparation progress over to lines a
+// modification left without a status.
+func keepItemStatuses(items, original []models.OrderItem) {
+	for i := range items {
+		if items[i].Status != "" {
+			continue
+		}
+		for _, old := range original {
+			if old.ProductID == items[i].ProductID {
+				items[i].Status, items[i].StartedAt, items[i].DoneAt = old.Status, old.StartedAt, old.DoneAt
+			}
+		}
+	}
+}
+
+// snapshotPrices sets the name and unit price of each line from the menu,
+// with the pricing rules in effect right now applied. Lines already on the
+// order keep what they were ordered at; whatever the client sent is
+// ignored.
+func snapshotPrices(items, original []models.OrderItem) error {
+	m := NewMenuService()
+	rules, err := activePricingRules(time.Now())
+	if err != nil {
+		return err
+	}
+	for i := range items {
+		old := slices.IndexFunc(original, func(line models.OrderItem) bool {
+			return line.ProductID == items[i].ProductID && line.Name != ""
+		})
+		if old >= 0 {
+			items[i].Name, items[i].UnitPrice = original[old].Name, original[old].UnitPrice
+			items[i].ListPrice, items[i].AppliedRule = original[old].ListPrice, original[old].AppliedRule
+			continue
+		}
+		item, err := m.GetMenuByID(items[i].ProductID)
+		if err != nil {
+			return err
+		}
+		items[i].Name, items[i].UnitPrice, items[i].ListPrice, items[i].AppliedRule = item.Name, item.Price, models.Money{}, ""
+		price, ruleID, err := bestPrice(item, rules)
+		if err != nil {
+			return err
+		}
+		if ruleID != "" {
+			items[i].UnitPrice, items[i].ListPrice, items[i].AppliedRule = price, item.Price, ruleID
+		}
+	}
+	return nil
+}
+
+func isItemStatus(status string) bool {
+	return status == "" || status == ItemPending || status == ItemStarted || status == ItemDone
+}
+
+func publishOrder(eventType string, order models.Order) {
+	Events.Publish(Event{Type: eventType, Subject: order.ID, Status: order.Status, Data: order})
+}
+
 func orderInit(modifiedOrder, originalOrder models.Order) models.Order {
 	if modifiedOrder.CreatedAt == "" {
 		modifiedOrder.CreatedAt = originalOrder.CreatedAt
@@ -275,6 +685,10 @@ func orderInit(modifiedOrder, originalOrder models.Order) models.Order {
 		modifiedOrder.CustomerName = originalOrder.CustomerName
 	}
 
+	if modifiedOrder.CustomerID == "" {
+		modifiedOrder.CustomerID = originalOrder.CustomerID
+	}
+
 	if modifiedOrder.Items == nil {
 		modifiedOrder.Items = originalOrder.Items
 	}
@@ -283,6 +697,14 @@ func orderInit(modifiedOrder, originalOrder models.Order) models.Order {
 		modifiedOrder.Status = originalOrder.Status
 	}
 
+	if modifiedOrder.PartySize == 0 {
+		modifiedOrder.PartySize = originalOrder.PartySize
+	}
+
+	if modifiedOrder.PickupAt == "" {
+		modifiedOrder.PickupAt = originalOrder.PickupAt
+	}
+
 	return modifiedOrder
 }
 
@@ -307,34 +729,41 @@ func validateOrders(Orders []models.Order) error {
 }
 
 func validateOrder(order models.Order) error {
+	v := &ValidationError{}
 	varTakenIdOrder := make(map[string]int)
 	m := NewMenuService()
 	if order.ID < "0" {
-		return errors.New("order ID cannot be negative")
-	} else if len(order.Items) == 0 {
-		return errors.New("empty order")
-	} else if order.CustomerName == "" {
-		return errors.New("customer name cannot be empty")
-	} else if order.Items == nil {
-		return errors.New("empty order")
+		v.Add(Pointer("order_id"), "order ID cannot be negative")
+	}
+	if order.CustomerName == "" {
+		v.Add(Pointer("customer_name"), "customer name cannot be empty")
+	}
+	if len(order.Items) == 0 {
+		v.Add(Pointer("items"), "empty order")
+	}
+	if order.PartySize < 0 {
+		v.Add(Pointer("party_size"), "party size cannot be negative")
 	}
 	for i, item := range order.Items {
-		product, err := m.GetMenuByID(item.ProductID)
-		if err != nil {
-			return err
-		}
 		if _, exists := varTakenIdOrder[item.ProductID]; exists {
-			return errors.New("duplicated products in order")
+			v.Add(Pointer("items", i, "product_id"), "duplicated products in order")
 		}
 		varTakenIdOrder[item.ProductID] = i
 		if item.Quantity <= 0 {
-			return fmt.Errorf("item with quantity %v is less than or equal to 0", item.Quantity)
+			v.Add(Pointer("items", i, "quantity"), "item with quantity %v is less than or equal to 0", item.Quantity)
 		}
-		if err := validatePostMenu(product); err != nil {
+		product, err := m.GetMenuByID(item.ProductID)
+		if errors.Is(err, ErrNotFound) {
+			v.Add(Pointer("items", i, "product_id"), "%s", err.Error())
+			continue
+		} else if err != nil {
 			return err
 		}
+		if err := validatePostMenu(product); err != nil {
+			v.Add(Pointer("items", i, "product_id"), "product %s cannot be ordered: %v", item.ProductID, err)
+		}
 	}
-	return nil
+	return v.Err()
 }
 
 func validateCloseOrder(order models.Order) error {
@@ -348,7 +777,7 @@ func validateCloseOrder(order models.Order) error {
 		return errors.New("items cannot be null")
 	}
 	if order.Status == "Closed" {
-		return errors.New("order is already closed")
+		return ErrOrderClosed
 	}
 	return nil
 }
@@ -363,7 +792,7 @@ func validateDeductCheckIngredients(productID string, quantity float64) error {
 	for _, ingredient := range item.Ingredients {
 		requiredQuantity := ingredient.Quantity * quantity
 		if err := CheckInventoryAvailability(ingredient.IngredientID, requiredQuantity); err != nil {
-			return fmt.Errorf("not enough %s (required: %.2f)", ingredient.IngredientID, requiredQuantity)
+			return fmt.Errorf("%w: not enough %s (required: %.2f)", ErrInsufficientStock, ingredient.IngredientID, requiredQuantity)
 		}
 	}
 	return nil
@@ -384,7 +813,7 @@ func CheckInventoryAvailability(ingredientID string, requiredQuantity float64) e
 	}
 
 	if item.Quantity < requiredQuantity {
-		return fmt.Errorf("not enough quantity for ingredient %s", ingredientID)
+		return fmt.Errorf("%w: not enough quantity for ingredient %s", ErrInsufficientStock, ingredientID)
 	}
 
 	return nil
@@ -399,18 +828,34 @@ func validateModifying(modifiedOrder, originalOrder models.Order) error {
 	// 	return errors.New("modifying status is not permitted")
 	// }
 
-	if modifiedOrder.Status != "Open" && modifiedOrder.Status != "Closed" {
-		return errors.New("wrong order status (should be \"Closed\" or \"Open\")")
+	v := &ValidationError{}
+	status := strings.ToLower(modifiedOrder.Status)
+	if status != "open" && status != "ready" && status != "closed" && status != OrderScheduled {
+		v.Add(Pointer("status"), "wrong order status (should be \"Open\", \"Ready\", \"Closed\" or \"Scheduled\")")
+	}
+	if status == OrderScheduled && modifiedOrder.PickupAt == "" {
+		v.Add(Pointer("status"), "only orders with a pickup_at can be scheduled")
+	}
+	for i, item := range modifiedOrder.Items {
+		if !isItemStatus(item.Status) {
+			v.Add(Pointer("items", i, "status"), "wrong item status (should be %q, %q or %q)", ItemPending, ItemStarted, ItemDone)
+		}
 	}
 
 	if originalOrder.CreatedAt != modifiedOrder.CreatedAt {
-		return errors.New("modifying created time is not permitted")
+		v.Add(Pointer("created_at"), "modifying created time is not permitted")
+	}
+	if err := v.Err(); err != nil {
+		return err
 	}
 
 	if originalOrder.ID == modifiedOrder.ID &&
 		originalOrder.CustomerName == modifiedOrder.CustomerName &&
+		originalOrder.CustomerID == modifiedOrder.CustomerID &&
 		areOrderItemsEqual(originalOrder.Items, modifiedOrder.Items) &&
 		originalOrder.Status == modifiedOrder.Status &&
+		originalOrder.PartySize == modifiedOrder.PartySize &&
+		originalOrder.PickupAt == modifiedOrder.PickupAt &&
 		originalOrder.CreatedAt == modifiedOrder.CreatedAt {
 
 		return ErrNothingToModify
@@ -425,7 +870,7 @@ func areOrderItemsEqual(a, b []models.OrderItem) bool {
 	}
 
 	for i := range a {
-		if a[i].ProductID != b[i].ProductID || a[i].Quantity != b[i].Quantity {
+		if a[i] != b[i] {
 			return false
 		}
 	}
@@ -0,0 +1,48 @@
+package service
+
+import (
+	"errors"
+	"hot-coffee1/internal/dal"
+	"hot-coffee1/models"
+	"testing"
+)
+
+func TestAddNewOrderTakesTheNameOfTheCustomer(t *testing.T) {
+	resetData(t)
+	customer, err := NewCustomerService().AddNewCustomer(t.Context(), models.Customer{Name: "Aigerim", Phone: "+77011234567"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	order, err := NewOrderService().AddNewOrder(t.Context(), models.Order{
+		CustomerID: customer.ID,
+		Items:      []models.OrderItem{{ProductID: "latte", Quantity: 1}},
+	})
+	if err != nil {
+		t.Fatalf("order with only customer_id: %v", err)
+	}
+	if order.CustomerName != "Aigerim" {
+		t.Errorf("customer_name = %q, want %q", order.CustomerName, "Aigerim")
+	}
+}
+
+func TestModifyOrderCannotReopenAClosedOrder(t *testing.T) {
+	resetData(t)
+	closed := models.Order{
+		ID:           "order1",
+		CustomerName: "Aigerim",
+		Items:        []models.OrderItem{{ProductID: "latte", Quantity: 1}},
+		Status:       "closed",
+		CreatedAt:    "2026-10-01 09:00:00",
+	}
+	if err := dal.NewOrderRepository().WriteOrder([]models.Order{closed}); err != nil {
+		t.Fatal(err)
+	}
+
+	reopened := closed
+	reopened.Status = "open"
+	err := NewOrderService().ModifyOrder(t.Context(), reopened, closed.ID)
+	if !errors.Is(err, ErrConflict) {
+		t.Fatalf("reopening a closed order: err = %v, want ErrConflict", err)
+	}
+}
@@ -0,0 +1,372 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hot-coffee1/internal/dal"
+	"hot-coffee1/models"
+	"log/slog"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrPaymentDeclined = errors.New("payment was declined")
+	ErrOrderNotPaid    = errors.New("order is not fully paid")
+)
+
+const (
+	TenderCash     = "cash"
+	TenderCard     = "card"
+	TenderGiftCard = "gift_card"
+	TenderVoucher  = "voucher"
+)
+
+const (
+	PaymentCaptured = "captured"
+	PaymentRefunded = "refunded"
+)
+
+// PaymentProvider charges and refunds card payments. Charge returns the
+// provider's transaction ID.
+type PaymentProvider interface {
+	Name() string
+	Charge(ctx context.Context, amount models.Money, reference string) (string, error)
+	Refund(ctx context.Context, transactionID string, amount models.Money) error
+}
+
+var (
+	paymentProviderMu sync.Mutex
+	paymentProvider   PaymentProvider = LocalPaymentProvider{}
+)
+
+func SetPaymentProvider(p PaymentProvider) {
+	paymentProviderMu.Lock()
+	defer paymentProviderMu.Unlock()
+	paymentProvider = p
+}
+
+func currentPaymentProvider() PaymentProvider {
+	paymentProviderMu.Lock()
+	defer paymentProviderMu.Unlock()
+	return paymentProvider
+}
+
+// LocalDeclineToken is the card reference LocalPaymentProvider declines.
+const LocalDeclineToken = "decline"
+
+// LocalPaymentProvider approves every card without contacting anyone, for
+// development and tests.
+type LocalPaymentProvider struct{}
+
+func (LocalPaymentProvider) Name() string { return "local" }
+
+func (LocalPaymentProvider) Charge(ctx context.Context, amount models.Money, reference string) (string, error) {
+	if reference == LocalDeclineToken {
+		return "", fmt.Errorf("%w: card %s", ErrPaymentDeclined, reference)
+	}
+	return "local_" + randomHex(8), nil
+}
+
+func (LocalPaymentProvider) Refund(ctx context.Context, transactionID string, amount models.Money) error {
+	return nil
+}
+
+// AddPayment records a tender towards an order that is not closed yet.
+// Card payments are charged through the payment provider first.
+func (o *Order) AddPayment(ctx context.Context, ID string, payment models.Payment) (models.Payment, error) {
+	ordersMu.Lock()
+	defer ordersMu.Unlock()
+
+	if err := o.LoadOrdersCache(); err != nil {
+		return models.Payment{}, err
+	}
+	existingOrder, exists := o.cacheOrders[ID]
+	if !exists {
+		return models.Payment{}, fmt.Errorf("order with ID %s %w", ID, ErrNotFound)
+	}
+	if status := strings.ToLower(existingOrder.Status); status != "open" && status != "ready" && status != OrderScheduled {
+		return models.Payment{}, ErrOrderClosed
+	}
+
+	order := existingOrder
+	order.Payments = append([]models.Payment(nil), existingOrder.Payments...)
+	if err := calculateTotals(&order); err != nil {
+		return models.Payment{}, err
+	}
+	if payment.Amount.IsZero() && len(payment.Items) == 0 {
+		switch payment.Tender {
+		case TenderGiftCard:
+			payment.Amount = giftCardAmount(payment.Reference, order.Totals.Due)
+		case TenderVoucher:
+			payment.Amount = voucherAmount(payment.Reference, order.Totals.Due)
+		}
+	}
+	if err := preparePayment(&payment, order); err != nil {
+		return models.Payment{}, err
+	}
+
+	payment.ID = randomHex(6)
+	provider := currentPaymentProvider()
+	switch payment.Tender {
+	case TenderCard:
+		transactionID, err := provider.Charge(ctx, payment.Amount, payment.Reference)
+		if err != nil {
+			return models.Payment{}, err
+		}
+		payment.TransactionID = transactionID
+	case TenderGiftCard:
+		if err := redeemGiftCard(ctx, &payment, ID); err != nil {
+			return models.Payment{}, err
+		}
+	case TenderVoucher:
+		if err := redeemVoucher(ctx, &payment, ID); err != nil {
+			return models.Payment{}, err
+		}
+	}
+	payment.Status = PaymentCaptured
+	payment.CreatedAt = time.Now().Format(time.DateTime)
+	payment.CreatedBy = actorName(ctx)
+	payment.RefundedAt = ""
+	order.Payments = append(order.Payments, payment)
+	if err := calculateTotals(&order); err != nil {
+		return models.Payment{}, err
+	}
+
+	o.cacheOrders[ID] = order
+	ordersSlice := make([]models.Order, 0, len(o.cacheOrders))
+	for _, v := range o.cacheOrders {
+		ordersSlice = append(ordersSlice, v)
+	}
+	if err := dal.NewOrderRepository().WriteOrder(ordersSlice); err != nil {
+		if payment.TransactionID != "" {
+			if refundErr := provider.Refund(ctx, payment.TransactionID, payment.Amount); refundErr != nil {
+				slog.Error("Failed to refund unsaved card payment", "order", ID, "transaction", payment.TransactionID, "error", refundErr)
+			}
+		}
+		if payment.Tender == TenderGiftCard {
+			if refundErr := refundGiftCard(ctx, payment, ID); refundErr != nil {
+				slog.Error("Failed to refund unsaved gift card payment", "order", ID, "gift_card", payment.Reference, "error", refundErr)
+			}
+		}
+		if payment.Tender == TenderVoucher {
+			if refundErr := refundVoucher(ctx, payment); refundErr != nil {
+				slog.Error("Failed to refund unsaved voucher payment", "order", ID, "voucher", payment.Reference, "error", refundErr)
+			}
+		}
+		return models.Payment{}, err
+	}
+	recordAudit(ctx, "order", ID, "payment", existingOrder, order)
+	publishOrder(EventOrderModified, order)
+	return payment, nil
+}
+
+// RefundPayment gives a payment back before the order is closed, e.g. when
+// it was taken by mistake.
+func (o *Order) RefundPayment(ctx context.Context, ID, paymentID string) (models.Order, error) {
+	ordersMu.Lock()
+	defer ordersMu.Unlock()
+
+	if err := o.LoadOrdersCache(); err != nil {
+		return models.Order{}, err
+	}
+	existingOrder, exists := o.cacheOrders[ID]
+	if !exists {
+		return models.Order{}, fmt.Errorf("order with ID %s %w", ID, ErrNotFound)
+	}
+	if status := strings.ToLower(existingOrder.Status); status != "open" && status != "ready" && status != OrderScheduled {
+		return models.Order{}, ErrOrderClosed
+	}
+
+	order := existingOrder
+	order.Payments = append([]models.Payment(nil), existingOrder.Payments...)
+	index := slices.IndexFunc(order.Payments, func(payment models.Payment) bool { return payment.ID == paymentID })
+	if index < 0 {
+		return models.Order{}, fmt.Errorf("payment %s on order %s %w", paymentID, ID, ErrNotFound)
+	}
+	payment := &order.Payments[index]
+	if payment.Status != PaymentCaptured {
+		return models.Order{}, fmt.Errorf("%w: payment %s is already %s", ErrConflict, paymentID, payment.Status)
+	}
+	if payment.Tender == TenderCard && payment.TransactionID != "" {
+		if err := currentPaymentProvider().Refund(ctx, payment.TransactionID, payment.Amount); err != nil {
+			return models.Order{}, err
+		}
+	}
+	if payment.Tender == TenderGiftCard {
+		if err := refundGiftCard(ctx, *payment, ID); err != nil {
+			return models.Order{}, err
+		}
+	}
+	if payment.Tender == TenderVoucher {
+		if err := refundVoucher(ctx, *payment); err != nil {
+			return models.Order{}, err
+		}
+	}
+	payment.Status, payment.RefundedAt = PaymentRefunded, time.Now().Format(time.DateTime)
+	if err := calculateTotals(&order); err != nil {
+		return models.Order{}, err
+	}
+
+	o.cacheOrders[ID] = order
+	ordersSlice := make([]models.Order, 0, len(o.cacheOrders))
+	for _, v := range o.cacheOrders {
+		ordersSlice = append(ordersSlice, v)
+	}
+	if err := dal.NewOrderRepository().WriteOrder(ordersSlice); err != nil {
+		return models.Order{}, err
+	}
+	recordAudit(ctx, "order", ID, "refund", existingOrder, order)
+	publishOrder(EventOrderModified, order)
+	return order, nil
+}
+
+// preparePayment checks a payment against what is due on order and works
+// out its amount: the price share of its items for a split bill, otherwise
+// everything due (for cash at most what was tendered). Cash gets change.
+func preparePayment(payment *models.Payment, order models.Order) error {
+	v := &ValidationError{}
+	switch payment.Tender {
+	case TenderCash, TenderCard, TenderGiftCard, TenderVoucher:
+	default:
+		v.Add(Pointer("tender"), "tender has to be %s, %s, %s or %s", TenderCash, TenderCard, TenderGiftCard, TenderVoucher)
+	}
+	if payment.Tender != TenderCash && payment.Reference == "" {
+		v.Add(Pointer("reference"), "%s payments need a reference", payment.Tender)
+	}
+	due := order.Totals.Due
+	if due.Amount <= 0 {
+		return fmt.Errorf("%w: order %s is already paid", ErrConflict, order.ID)
+	}
+	// Валюту проверяем до любых вычислений с суммами
+	if !payment.Amount.IsZero() && payment.Amount.Currency != due.Currency {
+		v.Add(Pointer("amount"), "currency has to be %s", due.Currency)
+	}
+	if !payment.Tendered.IsZero() && payment.Tendered.Currency != due.Currency {
+		v.Add(Pointer("tendered"), "currency has to be %s", due.Currency)
+	}
+	if err := v.Err(); err != nil {
+		return err
+	}
+
+	if len(payment.Items) > 0 {
+		amount := splitAmount(v, order, payment.Items)
+		if !payment.Amount.IsZero() && payment.Amount != amount {
+			v.Add(Pointer("amount"), "amount %s does not match the %s of the items", payment.Amount, amount)
+		}
+		payment.Amount = amount
+	}
+	if payment.Amount.IsZero() {
+		payment.Amount = due
+		if payment.Tender == TenderCash && !payment.Tendered.IsZero() {
+			var err error
+			if payment.Amount, err = minMoney(payment.Tendered, due); err != nil {
+				return err
+			}
+		}
+	}
+
+	if payment.Tender == TenderCash {
+		if payment.Tendered.IsZero() {
+			payment.Tendered = payment.Amount
+		}
+		if payment.Tendered.Amount < payment.Amount.Amount {
+			v.Add(Pointer("tendered"), "tendered %s is less than the amount %s", payment.Tendered, payment.Amount)
+		}
+		change, err := payment.Tendered.Sub(payment.Amount)
+		if err != nil {
+			return err
+		}
+		payment.Change = change
+	} else {
+		if !payment.Tendered.IsZero() {
+			v.Add(Pointer("tendered"), "tendered is only for cash")
+		}
+		payment.Change = models.Money{}
+	}
+
+	if payment.Amount.Amount <= 0 {
+		v.Add(Pointer("amount"), "amount has to be positive")
+	} else if payment.Amount.Amount > due.Amount {
+		v.Add(Pointer("amount"), "amount %s is more than the %s due", payment.Amount, due)
+	}
+	return v.Err()
+}
+
+// splitAmount is what items cost as a share of the order total, so
+// discounts and tax are split with them. Every unit on the order gets its
+// share of the total in whole minor units; what the division leaves over
+// goes one minor unit each to the first units, so the shares add up to the
+// total exactly.
+func splitAmount(v *ValidationError, order models.Order, items []models.PaymentItem) models.Money {
+	paid := map[string]int{}
+	for _, payment := range order.Payments {
+		if payment.Status != PaymentCaptured {
+			continue
+		}
+		for _, item := range payment.Items {
+			paid[item.ProductID] += item.Quantity
+		}
+	}
+
+	total, subtotal := order.Totals.Total.Amount, order.Totals.Subtotal.Amount
+	// offsets[i] is the number of units on the lines before line i
+	offsets := make([]int64, len(order.Items))
+	var units, remainder int64 = 0, total
+	for i, line := range order.Items {
+		offsets[i] = units
+		units += int64(line.Quantity)
+		if subtotal > 0 {
+			remainder -= line.UnitPrice.Amount * total / subtotal * int64(line.Quantity)
+		}
+	}
+
+	share := models.Money{Currency: order.Totals.Total.Currency}
+	for j, item := range items {
+		index := slices.IndexFunc(order.Items, func(line models.OrderItem) bool { return line.ProductID == item.ProductID })
+		if index < 0 {
+			v.Add(Pointer("items", j, "product_id"), "item %s is not on the order", item.ProductID)
+			continue
+		}
+		line := order.Items[index]
+		if item.Quantity < 1 {
+			v.Add(Pointer("items", j, "quantity"), "quantity has to be at least 1")
+			continue
+		}
+		if paid[item.ProductID]+item.Quantity > line.Quantity {
+			v.Add(Pointer("items", j, "quantity"), "only %d of %s are left to pay", line.Quantity-paid[item.ProductID], item.ProductID)
+			continue
+		}
+		if subtotal > 0 {
+			// Единицы строки оплачиваются по порядку, остаток достаётся первым
+			first := offsets[index] + int64(paid[item.ProductID])
+			extra := min(max(remainder-first, 0), int64(item.Quantity))
+			share.Amount += line.UnitPrice.Amount*total/subtotal*int64(item.Quantity) + extra
+		}
+		paid[item.ProductID] += item.Quantity
+	}
+
+	allPaid := true
+	for _, line := range order.Items {
+		allPaid = allPaid && paid[line.ProductID] >= line.Quantity
+	}
+	if allPaid {
+		return order.Totals.Due
+	}
+	return share
+}
+
+func capturedPayments(order models.Order) (models.Money, error) {
+	paid := models.NewMoney(0)
+	for _, payment := range order.Payments {
+		if payment.Status == PaymentCaptured {
+			if err := addMoney(&paid, payment.Amount); err != nil {
+				return models.Money{}, err
+			}
+		}
+	}
+	return paid, nil
+}
@@ -0,0 +1,197 @@
+package service
+
+import (
+	"errors"
+	"hot-coffee1/models"
+	"testing"
+)
+
+func TestSplitAmount(t *testing.T) {
+	usd := func(minor int64) models.Money { return models.Money{Amount: minor, Currency: "USD"} }
+	latte := func(n int) models.PaymentItem { return models.PaymentItem{ProductID: "latte", Quantity: n} }
+	paid := func(items ...models.PaymentItem) models.Payment {
+		return models.Payment{Status: PaymentCaptured, Items: items}
+	}
+	// 3.01 over four items of 1.00: each is 0.75 and the first takes the leftover cent
+	order := models.Order{
+		Items:  []models.OrderItem{{ProductID: "latte", Quantity: 3, UnitPrice: usd(100)}, {ProductID: "muffin", Quantity: 1, UnitPrice: usd(100)}},
+		Totals: &models.OrderTotals{Subtotal: usd(400), Total: usd(301), Due: usd(301)},
+	}
+
+	tests := []struct {
+		name     string
+		payments []models.Payment
+		items    []models.PaymentItem
+		want     int64
+	}{
+		{"first latte takes the leftover cent", nil, []models.PaymentItem{latte(1)}, 76},
+		{"all lattes at once", nil, []models.PaymentItem{latte(3)}, 226},
+		{"later lattes have no leftover", []models.Payment{paid(latte(2))}, []models.PaymentItem{latte(1)}, 75},
+		{"muffin", nil, []models.PaymentItem{{ProductID: "muffin", Quantity: 1}}, 75},
+		{"last items pay what is due", []models.Payment{paid(latte(3))}, []models.PaymentItem{{ProductID: "muffin", Quantity: 1}}, 301},
+		{"refunded payments do not count", []models.Payment{{Status: PaymentRefunded, Items: []models.PaymentItem{latte(3)}}}, []models.PaymentItem{latte(1)}, 76},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			order := order
+			order.Payments = tt.payments
+			v := &ValidationError{}
+			got := splitAmount(v, order, tt.items)
+			if err := v.Err(); err != nil {
+				t.Fatal(err)
+			}
+			if got.Amount != tt.want {
+				t.Errorf("amount = %d, want %d", got.Amount, tt.want)
+			}
+		})
+	}
+}
+
+func TestSplitAmountAddsUpToTheTotal(t *testing.T) {
+	usd := func(minor int64) models.Money { return models.Money{Amount: minor, Currency: "USD"} }
+	// 1.01 split over seven coffees of 0.30 and a 0.90 cake
+	order := models.Order{
+		Items:  []models.OrderItem{{ProductID: "coffee", Quantity: 7, UnitPrice: usd(30)}, {ProductID: "cake", Quantity: 1, UnitPrice: usd(90)}},
+		Totals: &models.OrderTotals{Subtotal: usd(300), Total: usd(101), Due: usd(101)},
+	}
+
+	var sum int64
+	for range 7 {
+		v := &ValidationError{}
+		amount := splitAmount(v, order, []models.PaymentItem{{ProductID: "coffee", Quantity: 1}})
+		if err := v.Err(); err != nil {
+			t.Fatal(err)
+		}
+		sum += amount.Amount
+		order.Payments = append(order.Payments, models.Payment{Status: PaymentCaptured, Amount: amount, Items: []models.PaymentItem{{ProductID: "coffee", Quantity: 1}}})
+	}
+	// Priced as if it were not the last item, the cake still brings the shares to the total
+	v := &ValidationError{}
+	order.Payments = order.Payments[:0]
+	cake := splitAmount(v, order, []models.PaymentItem{{ProductID: "cake", Quantity: 1}})
+	if sum+cake.Amount != 101 {
+		t.Fatalf("coffees %d + cake %d = %d, want 101", sum, cake.Amount, sum+cake.Amount)
+	}
+}
+
+func TestSplitAmountRejectsItemsNotLeftToPay(t *testing.T) {
+	order := models.Order{
+		Items:    []models.OrderItem{{ProductID: "latte", Quantity: 1, UnitPrice: models.NewMoney(350)}},
+		Totals:   &models.OrderTotals{Subtotal: models.NewMoney(350), Total: models.NewMoney(350), Due: models.NewMoney(350)},
+		Payments: []models.Payment{{Status: PaymentCaptured, Items: []models.PaymentItem{{ProductID: "latte", Quantity: 1}}}},
+	}
+	v := &ValidationError{}
+	splitAmount(v, order, []models.PaymentItem{{ProductID: "latte", Quantity: 1}, {ProductID: "mocha", Quantity: 1}})
+	var validation *ValidationError
+	if err := v.Err(); !errors.As(err, &validation) || len(validation.Violations) != 2 {
+		t.Fatalf("err = %v, want violations for both items", err)
+	}
+}
+
+// newLatteOrder places an open order for one latte at 3.50.
+func newLatteOrder(t *testing.T) models.Order {
+	t.Helper()
+	order, err := NewOrderService().AddNewOrder(t.Context(), models.Order{
+		CustomerName: "Aigerim",
+		Items:        []models.OrderItem{{ProductID: "latte", Quantity: 1}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return order
+}
+
+func TestAddPaymentGivesChangeForCash(t *testing.T) {
+	resetData(t)
+	order := newLatteOrder(t)
+
+	payment, err := NewOrderService().AddPayment(t.Context(), order.ID, models.Payment{Tender: TenderCash, Tendered: models.NewMoney(2000)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payment.Amount.Amount != 350 || payment.Change.Amount != 1650 {
+		t.Fatalf("amount %s, change %s; want 3.50 and 16.50", payment.Amount, payment.Change)
+	}
+}
+
+func TestAddPaymentRejectsTooLittleCash(t *testing.T) {
+	resetData(t)
+	order := newLatteOrder(t)
+
+	// Partial cash pays what was tendered, an explicit amount cannot exceed it
+	_, err := NewOrderService().AddPayment(t.Context(), order.ID, models.Payment{Tender: TenderCash, Amount: models.NewMoney(350), Tendered: models.NewMoney(300)})
+	var validation *ValidationError
+	if !errors.As(err, &validation) || validation.Violations[0].Field != Pointer("tendered") {
+		t.Fatalf("err = %v, want a violation at /tendered", err)
+	}
+}
+
+func TestAddPaymentDeclinedByTheProvider(t *testing.T) {
+	resetData(t)
+	order := newLatteOrder(t)
+
+	_, err := NewOrderService().AddPayment(t.Context(), order.ID, models.Payment{Tender: TenderCard, Reference: LocalDeclineToken})
+	if !errors.Is(err, ErrPaymentDeclined) {
+		t.Fatalf("err = %v, want ErrPaymentDeclined", err)
+	}
+	saved, err := NewOrderService().GetOrderByID(order.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(saved.Payments) != 0 || saved.Totals.Due.Amount != 350 {
+		t.Fatalf("declined card was recorded: payments %+v, due %s", saved.Payments, saved.Totals.Due)
+	}
+
+	payment, err := NewOrderService().AddPayment(t.Context(), order.ID, models.Payment{Tender: TenderCard, Reference: "tok_visa"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payment.TransactionID == "" || payment.Amount.Amount != 350 {
+		t.Fatalf("card payment = %+v, want 3.50 with a transaction", payment)
+	}
+}
+
+func TestVoucherIsUsedOnce(t *testing.T) {
+	resetData(t)
+	voucher, err := NewVoucherService().IssueVoucher(t.Context(), models.Voucher{Code: "paper-10", Value: models.NewMoney(1000)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	first, second := newLatteOrder(t), newLatteOrder(t)
+
+	payment, err := NewOrderService().AddPayment(t.Context(), first.ID, models.Payment{Tender: TenderVoucher, Reference: "Paper-10"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payment.Amount.Amount != 350 {
+		t.Errorf("voucher paid %s, want 3.50", payment.Amount)
+	}
+	if voucher, _ = NewVoucherService().GetVoucher(voucher.Code); voucher.OrderID != first.ID {
+		t.Errorf("voucher order = %q, want %q", voucher.OrderID, first.ID)
+	}
+
+	_, err = NewOrderService().AddPayment(t.Context(), second.ID, models.Payment{Tender: TenderVoucher, Reference: voucher.Code})
+	if !errors.Is(err, ErrPaymentDeclined) {
+		t.Fatalf("second use: err = %v, want ErrPaymentDeclined", err)
+	}
+
+	if _, err := NewOrderService().RefundPayment(t.Context(), first.ID, payment.ID); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewOrderService().AddPayment(t.Context(), second.ID, models.Payment{Tender: TenderVoucher, Reference: voucher.Code}); err != nil {
+		t.Fatalf("voucher after refund: %v", err)
+	}
+}
+
+func TestExpiredVoucherIsDeclined(t *testing.T) {
+	resetData(t)
+	if _, err := NewVoucherService().IssueVoucher(t.Context(), models.Voucher{Code: "OLD", Value: models.NewMoney(500), ExpiresAt: "2020-01-01"}); err != nil {
+		t.Fatal(err)
+	}
+	order := newLatteOrder(t)
+
+	_, err := NewOrderService().AddPayment(t.Context(), order.ID, models.Payment{Tender: TenderVoucher, Reference: "OLD"})
+	if !errors.Is(err, ErrPaymentDeclined) {
+		t.Fatalf("err = %v, want ErrPaymentDeclined", err)
+	}
+}
@@ -0,0 +1,401 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"hot-coffee1/internal/dal"
+	"hot-coffee1/models"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+)
+
+var (
+	ErrPickupScheduleNotRead = errors.New("pickup schedule was not read")
+	ErrPickupUnavailable     = errors.New("pickup slot unavailable")
+)
+
+// OrderScheduled is the status of an order placed ahead until it is released
+// to the kitchen.
+const OrderScheduled = "scheduled"
+
+const (
+	pickupLayout      = "2006-01-02 15:04"
+	preorderPollEvery = 30 * time.Second
+)
+
+type Pickups struct{}
+
+type PickupService interface {
+	GetPickupSchedule() (models.PickupSchedule, error)
+	SetPickupSchedule(ctx context.Context, schedule models.PickupSchedule) (models.PickupSchedule, error)
+	GetPickupSlots(date string) ([]models.PickupSlot, error)
+	GetInventoryReservations() ([]models.InventoryReservation, error)
+}
+
+func NewPickupService() PickupService {
+	return &Pickups{}
+}
+
+// readPickupSchedule fills in the defaults for what is not configured: 15
+// minute slots released 15 minutes ahead, up to a week out. A lead time or
+// horizon of 0 is kept.
+func readPickupSchedule() (models.PickupSchedule, error) {
+	schedule, err := dal.NewPickupScheduleRepository().ReadPickupSchedule()
+	if err != nil {
+		return models.PickupSchedule{}, errors.Join(ErrPickupScheduleNotRead, err)
+	}
+	if schedule.SlotMinutes == 0 {
+		schedule.SlotMinutes = 15
+	}
+	if schedule.LeadMinutes == nil {
+		lead := 15
+		schedule.LeadMinutes = &lead
+	}
+	if schedule.MaxDaysAhead == nil {
+		days := 7
+		schedule.MaxDaysAhead = &days
+	}
+	if schedule.OpeningHours == nil {
+		schedule.OpeningHours = []models.RuleSchedule{}
+	}
+	return schedule, nil
+}
+
+func (p *Pickups) GetPickupSchedule() (models.PickupSchedule, error) {
+	return readPickupSchedule()
+}
+
+func (p *Pickups) SetPickupSchedule(ctx context.Context, schedule models.PickupSchedule) (models.PickupSchedule, error) {
+	before, err := readPickupSchedule()
+	if err != nil {
+		return models.PickupSchedule{}, err
+	}
+	v := &ValidationError{}
+	for i, hours := range schedule.OpeningHours {
+		if hours.From == "" && hours.To == "" {
+			v.Add(Pointer("opening_hours", i, "from"), "opening hours need from and to")
+		}
+		validateRuleSchedule(v, hours, "opening_hours", i)
+	}
+	if schedule.SlotMinutes < 0 || schedule.SlotMinutes > 0 && 24*60%schedule.SlotMinutes != 0 {
+		v.Add(Pointer("slot_minutes"), "slot_minutes has to divide a day, e.g. 10, 15 or 30")
+	}
+	if schedule.SlotCapacity < 0 {
+		v.Add(Pointer("slot_capacity"), "slot_capacity cannot be negative")
+	}
+	if schedule.LeadMinutes != nil && *schedule.LeadMinutes < 0 {
+		v.Add(Pointer("lead_minutes"), "lead_minutes cannot be negative")
+	}
+	if schedule.MaxDaysAhead != nil && *schedule.MaxDaysAhead < 0 {
+		v.Add(Pointer("max_days_ahead"), "max_days_ahead cannot be negative")
+	}
+	if err := v.Err(); err != nil {
+		return models.PickupSchedule{}, err
+	}
+
+	if err := dal.NewPickupScheduleRepository().WritePickupSchedule(schedule); err != nil {
+		return models.PickupSchedule{}, err
+	}
+	recordAudit(ctx, "pickup_schedule", "config", AuditModify, before, schedule)
+	return readPickupSchedule()
+}
+
+// GetPickupSlots lists the slots of a day (today when empty) that can still
+// be booked, with what is booked in them.
+func (p *Pickups) GetPickupSlots(date string) ([]models.PickupSlot, error) {
+	now := time.Now()
+	day, err := time.ParseInLocation(time.DateOnly, cmp.Or(date, now.Format(time.DateOnly)), time.Local)
+	if err != nil {
+		v := &ValidationError{}
+		v.Add(Pointer("date"), "date has to be a date like 2026-10-01")
+		return nil, v.Err()
+	}
+	schedule, err := readPickupSchedule()
+	if err != nil {
+		return nil, err
+	}
+	orders, err := dal.NewOrderRepository().ReadOrder()
+	if err != nil {
+		return nil, errors.Join(ErrOrderNotRead, err)
+	}
+
+	booked := map[time.Time]int{}
+	for _, order := range orders {
+		if at, err := time.ParseInLocation(time.DateTime, order.PickupAt, time.Local); err == nil {
+			booked[slotStart(at, schedule.SlotMinutes)] += orderUnits(order)
+		}
+	}
+	slots := []models.PickupSlot{}
+	step := time.Duration(schedule.SlotMinutes) * time.Minute
+	for start := day; start.Before(day.AddDate(0, 0, 1)); start = start.Add(step) {
+		if !start.After(now) || !start.Before(pickupHorizon(schedule, now)) || !openAt(schedule, start) {
+			continue
+		}
+		slots = append(slots, models.PickupSlot{
+			Start:     start.Format(pickupLayout),
+			Booked:    booked[start],
+			Capacity:  schedule.SlotCapacity,
+			Available: schedule.SlotCapacity == 0 || booked[start] < schedule.SlotCapacity,
+		})
+	}
+	return slots, nil
+}
+
+// GetInventoryReservations shows what the orders that are not closed hold
+// of each ingredient. Scheduled orders hold their ingredients from the
+// moment they are placed, so the stock is there at pickup.
+func (p *Pickups) GetInventoryReservations() ([]models.InventoryReservation, error) {
+	inventory, err := readInventoryByID()
+	if err != nil {
+		return nil, err
+	}
+	menu, err := dal.NewMenuRepository().ReadMenu()
+	if err != nil {
+		return nil, errors.Join(ErrMenuNotRead, err)
+	}
+	orders, err := dal.NewOrderRepository().ReadOrder()
+	if err != nil {
+		return nil, errors.Join(ErrOrderNotRead, err)
+	}
+
+	reservations := make(map[string]*models.InventoryReservation, len(inventory))
+	for id, item := range inventory {
+		reservations[id] = &models.InventoryReservation{
+			IngredientID: id,
+			Name:         item.Name,
+			Unit:         item.Unit,
+			OnHand:       item.Quantity,
+		}
+	}
+	recipes := make(map[string][]models.MenuItemIngredient, len(menu))
+	for _, item := range menu {
+		recipes[item.ID] = item.Ingredients
+	}
+	for _, order := range orders {
+		if strings.EqualFold(order.Status, "closed") {
+			continue
+		}
+		held := map[string]float64{}
+		for _, line := range order.Items {
+			for _, ingredient := range recipes[line.ProductID] {
+				held[ingredient.IngredientID] += ingredient.Quantity * float64(line.Quantity)
+			}
+		}
+		for id, quantity := range held {
+			reservation, ok := reservations[id]
+			if !ok {
+				continue
+			}
+			reservation.Reserved += quantity
+			if order.PickupAt != "" {
+				reservation.Pickups = append(reservation.Pickups, models.PickupReservation{
+					OrderID:  order.ID,
+					PickupAt: order.PickupAt,
+					Status:   order.Status,
+					Quantity: quantity,
+				})
+			}
+		}
+	}
+
+	result := make([]models.InventoryReservation, 0, len(reservations))
+	for _, reservation := range reservations {
+		reservation.Available = reservation.OnHand - reservation.Reserved
+		slices.SortFunc(reservation.Pickups, func(a, b models.PickupReservation) int {
+			return cmp.Or(strings.Compare(a.PickupAt, b.PickupAt), strings.Compare(a.OrderID, b.OrderID))
+		})
+		result = append(result, *reservation)
+	}
+	slices.SortFunc(result, func(a, b models.InventoryReservation) int { return strings.Compare(a.IngredientID, b.IngredientID) })
+	return result, nil
+}
+
+// ReleaseOrder sends a scheduled order to the kitchen ahead of its release
+// time.
+func (o *Order) ReleaseOrder(ctx context.Context, ID string) (models.Order, error) {
+	ordersMu.Lock()
+	defer ordersMu.Unlock()
+
+	if err := o.LoadOrdersCache(); err != nil {
+		return models.Order{}, err
+	}
+	existingOrder, exists := o.cacheOrders[ID]
+	if !exists {
+		return models.Order{}, fmt.Errorf("order with ID %s %w", ID, ErrNotFound)
+	}
+	if !strings.EqualFold(existingOrder.Status, OrderScheduled) {
+		return models.Order{}, fmt.Errorf("%w: order %s is not scheduled", ErrConflict, ID)
+	}
+
+	order := existingOrder
+	order.Status = "open"
+	o.cacheOrders[ID] = order
+	ordersSlice := make([]models.Order, 0, len(o.cacheOrders))
+	for _, v := range o.cacheOrders {
+		ordersSlice = append(ordersSlice, v)
+	}
+	if err := dal.NewOrderRepository().WriteOrder(ordersSlice); err != nil {
+		return models.Order{}, err
+	}
+	recordAudit(ctx, "order", ID, "released", existingOrder, order)
+	publishOrder(EventOrderStatusChanged, order)
+	return order, nil
+}
+
+// StartPreorderRelease releases scheduled orders to the kitchen once they
+// are within the lead time of their pickup.
+func StartPreorderRelease(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(preorderPollEvery)
+		defer ticker.Stop()
+		for {
+			releaseDueOrders(ctx)
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+func releaseDueOrders(ctx context.Context) {
+	o := NewOrderService()
+	orders, err := o.GetAllOrders()
+	if err != nil {
+		slog.Error("Failed to read orders", "error", err)
+		return
+	}
+	schedule, err := readPickupSchedule()
+	if err != nil {
+		slog.Error("Failed to read pickup schedule", "error", err)
+		return
+	}
+	now := time.Now()
+	for _, order := range orders {
+		if !strings.EqualFold(order.Status, OrderScheduled) {
+			continue
+		}
+		at, err := time.ParseInLocation(time.DateTime, order.PickupAt, time.Local)
+		if err == nil && now.Before(releaseTime(schedule, at)) {
+			continue
+		}
+		if _, err := o.ReleaseOrder(ctx, order.ID); err != nil {
+			slog.Error("Failed to release order", "ID", order.ID, "error", err)
+			continue
+		}
+		slog.Info("Released order to the kitchen", "ID", order.ID, "pickup_at", order.PickupAt)
+	}
+}
+
+// schedulePickup checks the pickup time of an order against the opening
+// hours and what is left in its slot, and holds the order back as scheduled
+// until its release time. orders are all orders, the order itself may be
+// among them.
+func schedulePickup(order *models.Order, orders map[string]models.Order) error {
+	schedule, err := readPickupSchedule()
+	if err != nil {
+		return err
+	}
+	now := time.Now()
+	at, err := parsePickupTime(order.PickupAt)
+
+	v := &ValidationError{}
+	switch {
+	case err != nil:
+		v.Add(Pointer("pickup_at"), "pickup_at has to be a time like 2026-10-01 08:15")
+	case !at.After(now):
+		v.Add(Pointer("pickup_at"), "pickup_at has to be in the future")
+	case !at.Before(pickupHorizon(schedule, now)):
+		v.Add(Pointer("pickup_at"), "pickup_at can be at most %d days ahead", *schedule.MaxDaysAhead)
+	case !openAt(schedule, at):
+		v.Add(Pointer("pickup_at"), "we are not open at %s", at.Format(pickupLayout))
+	}
+	if err := v.Err(); err != nil {
+		return err
+	}
+	order.PickupAt = at.Format(time.DateTime)
+
+	if err := checkPickupCapacity(*order, orders, schedule); err != nil {
+		return err
+	}
+
+	if now.Before(releaseTime(schedule, at)) {
+		order.Status = OrderScheduled
+	} else if strings.EqualFold(order.Status, OrderScheduled) {
+		order.Status = "open"
+	}
+	return nil
+}
+
+// checkPickupCapacity checks that the items of order fit in what the other
+// orders left of its pickup slot.
+func checkPickupCapacity(order models.Order, orders map[string]models.Order, schedule models.PickupSchedule) error {
+	at, err := time.ParseInLocation(time.DateTime, order.PickupAt, time.Local)
+	if err != nil || schedule.SlotCapacity == 0 {
+		return nil
+	}
+	start := slotStart(at, schedule.SlotMinutes)
+	booked := 0
+	for _, other := range orders {
+		pickup, err := time.ParseInLocation(time.DateTime, other.PickupAt, time.Local)
+		if other.ID != order.ID && err == nil && slotStart(pickup, schedule.SlotMinutes).Equal(start) {
+			booked += orderUnits(other)
+		}
+	}
+	if booked+orderUnits(order) > schedule.SlotCapacity {
+		return fmt.Errorf("%w: %d of %d items are already booked for the %s slot", ErrPickupUnavailable,
+			booked, schedule.SlotCapacity, start.Format(pickupLayout))
+	}
+	return nil
+}
+
+func errScheduled(order models.Order) error {
+	return fmt.Errorf("%w: order %s is scheduled for pickup at %s, release it first", ErrConflict, order.ID, order.PickupAt)
+}
+
+func parsePickupTime(value string) (time.Time, error) {
+	for _, layout := range []string{time.DateTime, pickupLayout} {
+		if at, err := time.ParseInLocation(layout, value, time.Local); err == nil {
+			return at, nil
+		}
+	}
+	at, err := time.Parse(time.RFC3339, value)
+	return at.Local(), err
+}
+
+func openAt(schedule models.PickupSchedule, at time.Time) bool {
+	if len(schedule.OpeningHours) == 0 {
+		return true
+	}
+	return slices.ContainsFunc(schedule.OpeningHours, func(hours models.RuleSchedule) bool { return scheduleCovers(hours, at) })
+}
+
+func releaseTime(schedule models.PickupSchedule, at time.Time) time.Time {
+	return at.Add(-time.Duration(*schedule.LeadMinutes) * time.Minute)
+}
+
+// pickupHorizon is the midnight that ends the last day pickups can be
+// booked for.
+func pickupHorizon(schedule models.PickupSchedule, now time.Time) time.Time {
+	year, month, day := now.Date()
+	return time.Date(year, month, day+*schedule.MaxDaysAhead+1, 0, 0, 0, 0, now.Location())
+}
+
+func slotStart(at time.Time, minutes int) time.Time {
+	midnight := time.Date(at.Year(), at.Month(), at.Day(), 0, 0, 0, 0, at.Location())
+	step := time.Duration(minutes) * time.Minute
+	return midnight.Add(at.Sub(midnight) / step * step)
+}
+
+func orderUnits(order models.Order) int {
+	units := 0
+	for _, item := range order.Items {
+		units += item.Quantity
+	}
+	return units
+}
@@ -0,0 +1,97 @@
+package service
+
+import (
+	"errors"
+	"hot-coffee1/internal/dal"
+	"hot-coffee1/models"
+	"testing"
+	"time"
+)
+
+func writePickupSchedule(t *testing.T, schedule models.PickupSchedule) {
+	t.Helper()
+	if err := dal.NewPickupScheduleRepository().WritePickupSchedule(schedule); err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestReadPickupScheduleKeepsZero(t *testing.T) {
+	resetData(t)
+	schedule, err := readPickupSchedule()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if *schedule.LeadMinutes != 15 || *schedule.MaxDaysAhead != 7 {
+		t.Fatalf("defaults: lead %d, days %d; want 15 and 7", *schedule.LeadMinutes, *schedule.MaxDaysAhead)
+	}
+
+	zero := 0
+	writePickupSchedule(t, models.PickupSchedule{LeadMinutes: &zero, MaxDaysAhead: &zero})
+	if schedule, err = readPickupSchedule(); err != nil {
+		t.Fatal(err)
+	}
+	if *schedule.LeadMinutes != 0 || *schedule.MaxDaysAhead != 0 {
+		t.Fatalf("configured zero: lead %d, days %d; want 0 and 0", *schedule.LeadMinutes, *schedule.MaxDaysAhead)
+	}
+}
+
+func TestSchedulePickupSameDayOnly(t *testing.T) {
+	resetData(t)
+	zero := 0
+	writePickupSchedule(t, models.PickupSchedule{MaxDaysAhead: &zero})
+
+	tomorrow := time.Now().AddDate(0, 0, 1).Format(time.DateOnly) + " 10:00"
+	order := models.Order{ID: "order1", PickupAt: tomorrow}
+	var validation *ValidationError
+	if err := schedulePickup(&order, nil); !errors.As(err, &validation) {
+		t.Fatalf("pickup tomorrow with max_days_ahead 0: err = %v, want a validation error", err)
+	}
+}
+
+func TestModifyOrderRechecksSlotCapacity(t *testing.T) {
+	resetData(t)
+	writePickupSchedule(t, models.PickupSchedule{SlotCapacity: 6})
+	pickupAt := time.Now().AddDate(0, 0, 1).Format(time.DateOnly) + " 10:00"
+	place := func(quantity int) models.Order {
+		order, err := NewOrderService().AddNewOrder(t.Context(), models.Order{
+			CustomerName: "Aigerim",
+			Items:        []models.OrderItem{{ProductID: "latte", Quantity: quantity}},
+			PickupAt:     pickupAt,
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return order
+	}
+	first, second := place(1), place(3)
+
+	tests := []struct {
+		name    string
+		id      string
+		release bool
+	}{
+		{"scheduled order", "", false},
+		{"body names another order", second.ID, false},
+		{"order already in the kitchen", "", true},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if tt.release {
+				if _, err := NewOrderService().ReleaseOrder(t.Context(), first.ID); err != nil {
+					t.Fatal(err)
+				}
+			}
+			grown := models.Order{ID: tt.id, Items: []models.OrderItem{{ProductID: "latte", Quantity: 4}}}
+			err := NewOrderService().ModifyOrder(t.Context(), grown, first.ID)
+			if !errors.Is(err, ErrPickupUnavailable) {
+				t.Fatalf("4 + 3 items in a slot of 6: err = %v, want ErrPickupUnavailable", err)
+			}
+		})
+	}
+
+	// Swapping items without growing the order still fits
+	swapped := models.Order{Items: []models.OrderItem{{ProductID: "mocha", Quantity: 1}}}
+	if err := NewOrderService().ModifyOrder(t.Context(), swapped, first.ID); err != nil {
+		t.Fatalf("same number of items: %v", err)
+	}
+}
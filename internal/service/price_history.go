@@ -0,0 +1,169 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hot-coffee1/internal/dal"
+	"hot-coffee1/models"
+	"log/slog"
+	"slices"
+	"strings"
+	"time"
+)
+
+const priceActivationEvery = time.Minute
+
+// SchedulePrice queues a price change that takes effect at
+// change.EffectiveFrom, which has to lie in the future.
+func (m *Menu) SchedulePrice(ctx context.Context, productID string, change models.PriceChange) (models.PriceChange, error) {
+	menuMu.Lock()
+	defer menuMu.Unlock()
+
+	if err := m.LoadMenuCache(); err != nil {
+		return models.PriceChange{}, err
+	}
+	index, exists := m.takenIDMenu[productID]
+	if !exists || index < 0 || index >= len(m.cacheMenu) {
+		return models.PriceChange{}, fmt.Errorf("item with product ID=%s %w", productID, ErrNotFound)
+	}
+
+	v := &ValidationError{}
+	validatePrice(v, Pointer("price"), change.Price)
+	effective, err := parseAuditBound(change.EffectiveFrom, false)
+	if err != nil {
+		v.Add(Pointer("effective_from"), "%v", err)
+	} else if !effective.After(time.Now()) {
+		v.Add(Pointer("effective_from"), "effective_from has to be in the future")
+	}
+	if err := v.Err(); err != nil {
+		return models.PriceChange{}, err
+	}
+
+	change.ID = randomHex(4)
+	change.EffectiveFrom = effective.Format(time.DateTime)
+	change.ChangedBy = actorName(ctx)
+	before := m.cacheMenu[index]
+	item := &m.cacheMenu[index]
+	item.ScheduledPrices = append(slices.Clone(item.ScheduledPrices), change)
+	slices.SortStableFunc(item.ScheduledPrices, func(a, b models.PriceChange) int {
+		return strings.Compare(a.EffectiveFrom, b.EffectiveFrom)
+	})
+	if err := dal.NewMenuRepository().WriteMenu(m.cacheMenu); err != nil {
+		return models.PriceChange{}, errors.New("failed to modify menu item")
+	}
+	recordAudit(ctx, "menu", productID, "schedule_price", before, *item)
+	return change, nil
+}
+
+func (m *Menu) CancelScheduledPrice(ctx context.Context, productID, changeID string) error {
+	menuMu.Lock()
+	defer menuMu.Unlock()
+
+	if err := m.LoadMenuCache(); err != nil {
+		return err
+	}
+	index, exists := m.takenIDMenu[productID]
+	if !exists || index < 0 || index >= len(m.cacheMenu) {
+		return fmt.Errorf("item with product ID=%s %w", productID, ErrNotFound)
+	}
+	item := &m.cacheMenu[index]
+	position := slices.IndexFunc(item.ScheduledPrices, func(change models.PriceChange) bool { return change.ID == changeID })
+	if position < 0 {
+		return fmt.Errorf("scheduled price %s %w", changeID, ErrNotFound)
+	}
+
+	before := *item
+	item.ScheduledPrices = slices.Delete(slices.Clone(item.ScheduledPrices), position, position+1)
+	if err := dal.NewMenuRepository().WriteMenu(m.cacheMenu); err != nil {
+		return errors.New("failed to modify menu item")
+	}
+	recordAudit(ctx, "menu", productID, "cancel_scheduled_price", before, *item)
+	return nil
+}
+
+// StartPriceActivation saves scheduled prices once they take effect, so the
+// change is written and audited even when nobody edits the menu.
+func StartPriceActivation(ctx context.Context) {
+	go func() {
+		ticker := time.NewTicker(priceActivationEvery)
+		defer ticker.Stop()
+		for {
+			menuMu.Lock()
+			if err := NewMenuService().LoadMenuCache(); err != nil {
+				slog.Error("Failed to activate scheduled prices", "error", err)
+			}
+			menuMu.Unlock()
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			}
+		}
+	}()
+}
+
+// activateScheduledPrices moves scheduled prices whose time has come into
+// the price history and returns the previous version of every item it
+// changed. It runs whenever the menu is loaded, so a due change is in
+// effect before anything reads the price.
+func activateScheduledPrices(menu []models.MenuItem) map[int]models.MenuItem {
+	now := time.Now().Format(time.DateTime)
+	changed := map[int]models.MenuItem{}
+	for i := range menu {
+		item := &menu[i]
+		before := *item
+		for len(item.ScheduledPrices) > 0 && item.ScheduledPrices[0].EffectiveFrom <= now {
+			change := item.ScheduledPrices[0]
+			item.ScheduledPrices = item.ScheduledPrices[1:]
+			change.ID = ""
+			item.PriceHistory = append(seedPriceHistory(item), change)
+			item.Price = change.Price
+		}
+		if len(item.ScheduledPrices) == 0 {
+			item.ScheduledPrices = nil
+		}
+		if len(item.ScheduledPrices) != len(before.ScheduledPrices) {
+			changed[i] = before
+			slog.Info("Scheduled price took effect", "ID", item.ID, "price", item.Price)
+		}
+	}
+	return changed
+}
+
+// recordPriceChange appends the new price of item to its history. Items
+// created before the history existed get their old price recorded first,
+// with an empty effective_from.
+func recordPriceChange(ctx context.Context, item *models.MenuItem, old models.MenuItem) {
+	item.PriceHistory, item.ScheduledPrices = slices.Clone(old.PriceHistory), slices.Clone(old.ScheduledPrices)
+	if item.Price == old.Price {
+		return
+	}
+	item.PriceHistory = append(seedPriceHistory(&old), models.PriceChange{
+		Price:         item.Price,
+		EffectiveFrom: time.Now().Format(time.DateTime),
+		ChangedBy:     actorName(ctx),
+	})
+}
+
+func seedPriceHistory(item *models.MenuItem) []models.PriceChange {
+	if len(item.PriceHistory) > 0 {
+		return slices.Clone(item.PriceHistory)
+	}
+	return []models.PriceChange{{Price: item.Price}}
+}
+
+// priceAt is the price item had at the given time (time.DateTime layout),
+// used for orders placed before lines carried their own price.
+func priceAt(item models.MenuItem, at string) models.Money {
+	if len(item.PriceHistory) == 0 {
+		return item.Price
+	}
+	price := item.PriceHistory[0].Price
+	for _, change := range item.PriceHistory {
+		if change.EffectiveFrom <= at {
+			price = change.Price
+		}
+	}
+	return price
+}
@@ -0,0 +1,316 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hot-coffee1/internal/dal"
+	"hot-coffee1/models"
+	"slices"
+	"strings"
+	"time"
+)
+
+var ErrPricingRuleNotRead = errors.New("pricing rules were not read")
+
+const (
+	EffectPercentOff = "percent_off"
+	EffectAmountOff  = "amount_off"
+	EffectFixedPrice = "fixed_price"
+)
+
+var ruleDays = []string{"sun", "mon", "tue", "wed", "thu", "fri", "sat"}
+
+const ruleTimeLayout = "15:04"
+
+type PricingRules struct {
+	cacheRules []models.PricingRule
+}
+
+type PricingRuleService interface {
+	GetAllPricingRules() ([]models.PricingRule, error)
+	GetPricingRuleByID(id string) (models.PricingRule, error)
+	AddNewPricingRule(ctx context.Context, rule models.PricingRule) (models.PricingRule, error)
+	ModifyPricingRule(ctx context.Context, rule models.PricingRule) (models.PricingRule, error)
+	DeletePricingRule(ctx context.Context, id string) error
+}
+
+func NewPricingRuleService() PricingRuleService {
+	return &PricingRules{cacheRules: []models.PricingRule{}}
+}
+
+func (p *PricingRules) loadRules() error {
+	rules, err := dal.NewPricingRuleRepository().ReadPricingRules()
+	if err != nil {
+		return errors.Join(ErrPricingRuleNotRead, err)
+	}
+	p.cacheRules = rules
+	return nil
+}
+
+func (p *PricingRules) findRule(id string) int {
+	return slices.IndexFunc(p.cacheRules, func(rule models.PricingRule) bool { return rule.ID == id })
+}
+
+func (p *PricingRules) GetAllPricingRules() ([]models.PricingRule, error) {
+	if err := p.loadRules(); err != nil {
+		return nil, err
+	}
+	return p.cacheRules, nil
+}
+
+func (p *PricingRules) GetPricingRuleByID(id string) (models.PricingRule, error) {
+	if err := p.loadRules(); err != nil {
+		return models.PricingRule{}, err
+	}
+	index := p.findRule(id)
+	if index < 0 {
+		return models.PricingRule{}, fmt.Errorf("pricing rule %s %w", id, ErrNotFound)
+	}
+	return p.cacheRules[index], nil
+}
+
+func (p *PricingRules) AddNewPricingRule(ctx context.Context, rule models.PricingRule) (models.PricingRule, error) {
+	if err := p.loadRules(); err != nil {
+		return models.PricingRule{}, err
+	}
+	if err := validatePricingRule(rule); err != nil {
+		return models.PricingRule{}, err
+	}
+
+	rule.ID = randomHex(4)
+	rule.CreatedAt = time.Now().Format(time.DateTime)
+	p.cacheRules = append(p.cacheRules, rule)
+	if err := dal.NewPricingRuleRepository().WritePricingRules(p.cacheRules); err != nil {
+		return models.PricingRule{}, err
+	}
+	recordAudit(ctx, "pricing_rule", rule.ID, AuditCreate, nil, rule)
+	return rule, nil
+}
+
+func (p *PricingRules) ModifyPricingRule(ctx context.Context, rule models.PricingRule) (models.PricingRule, error) {
+	if err := p.loadRules(); err != nil {
+		return models.PricingRule{}, err
+	}
+	index := p.findRule(rule.ID)
+	if index < 0 {
+		return models.PricingRule{}, fmt.Errorf("pricing rule %s %w", rule.ID, ErrNotFound)
+	}
+	if err := validatePricingRule(rule); err != nil {
+		return models.PricingRule{}, err
+	}
+
+	before := p.cacheRules[index]
+	rule.CreatedAt = before.CreatedAt
+	p.cacheRules[index] = rule
+	if err := dal.NewPricingRuleRepository().WritePricingRules(p.cacheRules); err != nil {
+		return models.PricingRule{}, err
+	}
+	recordAudit(ctx, "pricing_rule", rule.ID, AuditModify, before, rule)
+	return rule, nil
+}
+
+func (p *PricingRules) DeletePricingRule(ctx context.Context, id string) error {
+	if err := p.loadRules(); err != nil {
+		return err
+	}
+	index := p.findRule(id)
+	if index < 0 {
+		return fmt.Errorf("pricing rule %s %w", id, ErrNotFound)
+	}
+	deleted := p.cacheRules[index]
+	p.cacheRules = slices.Delete(p.cacheRules, index, index+1)
+	if err := dal.NewPricingRuleRepository().WritePricingRules(p.cacheRules); err != nil {
+		return err
+	}
+	recordAudit(ctx, "pricing_rule", id, AuditDelete, deleted, nil)
+	return nil
+}
+
+// activeRule is a rule in effect at the time an order is priced, with its
+// target categories expanded to their subcategories.
+type activeRule struct {
+	models.PricingRule
+	categories map[string]bool
+}
+
+// activePricingRules returns the rules whose schedule covers at.
+func activePricingRules(at time.Time) ([]activeRule, error) {
+	rules, err := dal.NewPricingRuleRepository().ReadPricingRules()
+	if err != nil {
+		return nil, errors.Join(ErrPricingRuleNotRead, err)
+	}
+
+	var active []activeRule
+	for _, rule := range rules {
+		if !rule.Active || !scheduleCovers(rule.Schedule, at) {
+			continue
+		}
+		categories, err := targetCategories(rule.Targets)
+		if err != nil {
+			return nil, err
+		}
+		active = append(active, activeRule{PricingRule: rule, categories: categories})
+	}
+	return active, nil
+}
+
+// targetCategories expands the categories of targets to their
+// subcategories. Categories deleted since are skipped.
+func targetCategories(targets models.RuleTargets) (map[string]bool, error) {
+	categories := map[string]bool{}
+	for _, id := range targets.Categories {
+		ids, err := categoryWithSubcategories(id)
+		if errors.Is(err, ErrNotFound) {
+			continue
+		} else if err != nil {
+			return nil, err
+		}
+		for id := range ids {
+			categories[id] = true
+		}
+	}
+	return categories, nil
+}
+
+func scheduleCovers(schedule models.RuleSchedule, at time.Time) bool {
+	if len(schedule.Days) > 0 && !slices.Contains(schedule.Days, ruleDays[at.Weekday()]) {
+		return false
+	}
+	date := at.Format(time.DateOnly)
+	if schedule.StartDate != "" && date < schedule.StartDate || schedule.EndDate != "" && date > schedule.EndDate {
+		return false
+	}
+	if schedule.From == "" {
+		return true
+	}
+	now := at.Format(ruleTimeLayout)
+	if schedule.From <= schedule.To {
+		return schedule.From <= now && now < schedule.To
+	}
+	// The window wraps past midnight, e.g. 22:00 to 02:00.
+	return now >= schedule.From || now < schedule.To
+}
+
+// bestPrice applies every matching rule to the menu price and keeps the one
+// cheapest for the customer; the first rule wins a tie. The rule ID is
+// empty when none matches.
+func bestPrice(item models.MenuItem, rules []activeRule) (models.Money, string, error) {
+	price, ruleID := item.Price, ""
+	for _, rule := range rules {
+		if !slices.Contains(rule.Targets.ProductIDs, item.ID) && !rule.categories[item.CategoryID] {
+			continue
+		}
+		discounted, err := applyEffect(rule.Effect, item.Price)
+		if err != nil {
+			return models.Money{}, "", fmt.Errorf("pricing rule %s: %w", rule.ID, err)
+		}
+		if discounted.Amount < price.Amount || ruleID == "" && discounted == price {
+			price, ruleID = discounted, rule.ID
+		}
+	}
+	return price, ruleID, nil
+}
+
+func applyEffect(effect models.RuleEffect, price models.Money) (models.Money, error) {
+	var err error
+	switch effect.Kind {
+	case EffectPercentOff:
+		price, err = price.Sub(percentOf(price, effect.Value))
+	case EffectAmountOff:
+		price, err = price.Sub(effect.Amount)
+	case EffectFixedPrice:
+		price, err = models.Money{Currency: price.Currency}.Add(effect.Amount)
+	}
+	price.Amount = max(0, price.Amount)
+	return price, err
+}
+
+func validatePricingRule(rule models.PricingRule) error {
+	v := &ValidationError{}
+	if rule.Name == "" {
+		v.Add(Pointer("name"), "name cannot be empty")
+	}
+
+	validateRuleSchedule(v, rule.Schedule, "schedule")
+
+	if len(rule.Targets.ProductIDs) == 0 && len(rule.Targets.Categories) == 0 {
+		v.Add(Pointer("targets"), "a rule needs at least one product or category")
+	}
+	if err := validateRuleTargets(v, rule.Targets, "targets"); err != nil {
+		return err
+	}
+
+	switch effect := rule.Effect; effect.Kind {
+	case EffectPercentOff:
+		if effect.Value <= 0 || effect.Value > 100 {
+			v.Add(Pointer("effect", "value"), "percent_off has to be between 0 and 100")
+		}
+	case EffectAmountOff, EffectFixedPrice:
+		if effect.Amount.Amount < 0 || effect.Kind == EffectAmountOff && effect.Amount.IsZero() {
+			v.Add(Pointer("effect", "amount"), "%s cannot be negative or zero", effect.Kind)
+		}
+		if effect.Amount.Currency != "" && effect.Amount.Currency != models.DefaultCurrency {
+			v.Add(Pointer("effect", "amount", "currency"), "currency has to be %s", models.DefaultCurrency)
+		}
+		if effect.Value != 0 {
+			v.Add(Pointer("effect", "value"), "%s takes an amount, not a value", effect.Kind)
+		}
+	default:
+		v.Add(Pointer("effect", "kind"), "kind has to be %s, %s or %s", EffectPercentOff, EffectAmountOff, EffectFixedPrice)
+	}
+	return v.Err()
+}
+
+// validateRuleSchedule checks the days, times and dates of a schedule at path.
+func validateRuleSchedule(v *ValidationError, schedule models.RuleSchedule, path ...any) {
+	for j, day := range schedule.Days {
+		if !slices.Contains(ruleDays, day) {
+			v.Add(Pointer(append(slices.Clone(path), "days", j)...), "unknown day %s, use %s", day, strings.Join(ruleDays, ", "))
+		}
+	}
+	if (schedule.From == "") != (schedule.To == "") {
+		v.Add(Pointer(append(slices.Clone(path), "to")...), "from and to have to be given together")
+	}
+	for _, field := range []struct{ name, value string }{{"from", schedule.From}, {"to", schedule.To}} {
+		if _, err := time.Parse(ruleTimeLayout, field.value); field.value != "" && (err != nil || len(field.value) != 5) {
+			v.Add(Pointer(append(slices.Clone(path), field.name)...), "%s has to be a time like 15:00", field.name)
+		}
+	}
+	if schedule.From != "" && schedule.From == schedule.To {
+		v.Add(Pointer(append(slices.Clone(path), "to")...), "the time window cannot be empty")
+	}
+	for _, field := range []struct{ name, value string }{{"start_date", schedule.StartDate}, {"end_date", schedule.EndDate}} {
+		if _, err := time.Parse(time.DateOnly, field.value); field.value != "" && err != nil {
+			v.Add(Pointer(append(slices.Clone(path), field.name)...), "%s has to be a date like 2026-10-01", field.name)
+		}
+	}
+	if schedule.StartDate != "" && schedule.EndDate != "" && schedule.EndDate < schedule.StartDate {
+		v.Add(Pointer(append(slices.Clone(path), "end_date")...), "end_date cannot be before start_date")
+	}
+}
+
+// validateRuleTargets checks that the targets exist; path is where they are
+// in the request.
+func validateRuleTargets(v *ValidationError, targets models.RuleTargets, path ...any) error {
+	menu, err := dal.NewMenuRepository().ReadMenu()
+	if err != nil {
+		return errors.Join(ErrMenuNotRead, err)
+	}
+	for j, id := range targets.ProductIDs {
+		if !slices.ContainsFunc(menu, func(item models.MenuItem) bool { return item.ID == id }) {
+			v.Add(Pointer(append(slices.Clone(path), "product_ids", j)...), "menu item %s does not exist", id)
+		}
+	}
+
+	categories, err := dal.NewCategoryRepository().ReadCategories()
+	if err != nil {
+		return errors.Join(ErrCategoryNotRead, err)
+	}
+	for j, id := range targets.Categories {
+		if !slices.ContainsFunc(categories, func(category models.Category) bool { return category.ID == id }) {
+			v.Add(Pointer(append(slices.Clone(path), "categories", j)...), "category %s does not exist", id)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,401 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"hot-coffee1/internal/dal"
+	"hot-coffee1/models"
+	"math"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+var (
+	ErrPromoCodeNotRead   = errors.New("promo codes were not read")
+	ErrPromoNotApplicable = errors.New("promo code cannot be applied")
+)
+
+const (
+	PromoPercent  = "percent"
+	PromoAmount   = "amount"
+	PromoBuyXGetY = "buy_x_get_y"
+)
+
+// promoMu serialises redemptions so usage limits hold under concurrent
+// orders.
+var promoMu sync.Mutex
+
+type PromoCodes struct {
+	cacheCodes []models.PromoCode
+}
+
+type PromoCodeService interface {
+	GetAllPromoCodes() ([]models.PromoCode, error)
+	GetPromoCode(code string) (models.PromoCode, error)
+	AddNewPromoCode(ctx context.Context, promo models.PromoCode) (models.PromoCode, error)
+	ModifyPromoCode(ctx context.Context, promo models.PromoCode) (models.PromoCode, error)
+	DeletePromoCode(ctx context.Context, code string) error
+}
+
+func NewPromoCodeService() PromoCodeService {
+	return &PromoCodes{cacheCodes: []models.PromoCode{}}
+}
+
+func (p *PromoCodes) loadCodes() error {
+	codes, err := dal.NewPromoCodeRepository().ReadPromoCodes()
+	if err != nil {
+		return errors.Join(ErrPromoCodeNotRead, err)
+	}
+	p.cacheCodes = codes
+	return nil
+}
+
+func findPromoCode(codes []models.PromoCode, code string) int {
+	code = normalizePromoCode(code)
+	return slices.IndexFunc(codes, func(promo models.PromoCode) bool { return promo.Code == code })
+}
+
+// Codes are matched case-insensitively.
+func normalizePromoCode(code string) string {
+	return strings.ToUpper(strings.TrimSpace(code))
+}
+
+func (p *PromoCodes) GetAllPromoCodes() ([]models.PromoCode, error) {
+	if err := p.loadCodes(); err != nil {
+		return nil, err
+	}
+	return p.cacheCodes, nil
+}
+
+func (p *PromoCodes) GetPromoCode(code string) (models.PromoCode, error) {
+	if err := p.loadCodes(); err != nil {
+		return models.PromoCode{}, err
+	}
+	index := findPromoCode(p.cacheCodes, code)
+	if index < 0 {
+		return models.PromoCode{}, fmt.Errorf("promo code %s %w", code, ErrNotFound)
+	}
+	return p.cacheCodes[index], nil
+}
+
+func (p *PromoCodes) AddNewPromoCode(ctx context.Context, promo models.PromoCode) (models.PromoCode, error) {
+	promoMu.Lock()
+	defer promoMu.Unlock()
+
+	if err := p.loadCodes(); err != nil {
+		return models.PromoCode{}, err
+	}
+	promo.Code = normalizePromoCode(promo.Code)
+	if findPromoCode(p.cacheCodes, promo.Code) >= 0 {
+		return models.PromoCode{}, fmt.Errorf("%w: promo code %s", ErrConflict, promo.Code)
+	}
+	if err := validatePromoCode(&promo); err != nil {
+		return models.PromoCode{}, err
+	}
+
+	promo.Uses = 0
+	promo.CreatedAt = time.Now().Format(time.DateTime)
+	p.cacheCodes = append(p.cacheCodes, promo)
+	if err := dal.NewPromoCodeRepository().WritePromoCodes(p.cacheCodes); err != nil {
+		return models.PromoCode{}, err
+	}
+	recordAudit(ctx, "promo_code", promo.Code, AuditCreate, nil, promo)
+	return promo, nil
+}
+
+// ModifyPromoCode replaces the terms of a code; its use count is kept.
+func (p *PromoCodes) ModifyPromoCode(ctx context.Context, promo models.PromoCode) (models.PromoCode, error) {
+	promoMu.Lock()
+	defer promoMu.Unlock()
+
+	if err := p.loadCodes(); err != nil {
+		return models.PromoCode{}, err
+	}
+	index := findPromoCode(p.cacheCodes, promo.Code)
+	if index < 0 {
+		return models.PromoCode{}, fmt.Errorf("promo code %s %w", promo.Code, ErrNotFound)
+	}
+	if err := validatePromoCode(&promo); err != nil {
+		return models.PromoCode{}, err
+	}
+
+	before := p.cacheCodes[index]
+	promo.Code, promo.Uses, promo.CreatedAt = before.Code, before.Uses, before.CreatedAt
+	p.cacheCodes[index] = promo
+	if err := dal.NewPromoCodeRepository().WritePromoCodes(p.cacheCodes); err != nil {
+		return models.PromoCode{}, err
+	}
+	recordAudit(ctx, "promo_code", promo.Code, AuditModify, before, promo)
+	return promo, nil
+}
+
+func (p *PromoCodes) DeletePromoCode(ctx context.Context, code string) error {
+	promoMu.Lock()
+	defer promoMu.Unlock()
+
+	if err := p.loadCodes(); err != nil {
+		return err
+	}
+	index := findPromoCode(p.cacheCodes, code)
+	if index < 0 {
+		return fmt.Errorf("promo code %s %w", code, ErrNotFound)
+	}
+	deleted := p.cacheCodes[index]
+	p.cacheCodes = slices.Delete(p.cacheCodes, index, index+1)
+	if err := dal.NewPromoCodeRepository().WritePromoCodes(p.cacheCodes); err != nil {
+		return err
+	}
+	recordAudit(ctx, "promo_code", deleted.Code, AuditDelete, deleted, nil)
+	return nil
+}
+
+// redeemPromoCodes adds codes to the discounts already on order, checks
+// them against it and counts a use of each. Nothing is counted when any
+// code is refused.
+func redeemPromoCodes(order *models.Order, codes []string) error {
+	if len(codes) == 0 {
+		return calculateDiscounts(order)
+	}
+	promoMu.Lock()
+	defer promoMu.Unlock()
+
+	promos, err := dal.NewPromoCodeRepository().ReadPromoCodes()
+	if err != nil {
+		return errors.Join(ErrPromoCodeNotRead, err)
+	}
+	now := time.Now().Format(time.DateTime)
+	applied := len(order.Discounts)
+	for _, code := range codes {
+		code = normalizePromoCode(code)
+		index := findPromoCode(promos, code)
+		switch {
+		case index < 0:
+			return fmt.Errorf("promo code %s %w", code, ErrNotFound)
+		case slices.ContainsFunc(order.Discounts, func(discount models.OrderDiscount) bool { return discount.Code == code }):
+			return fmt.Errorf("%w: %s is already applied", ErrPromoNotApplicable, code)
+		case !promos[index].Active:
+			return fmt.Errorf("%w: %s is not active", ErrPromoNotApplicable, code)
+		case promos[index].ExpiresAt != "" && promos[index].ExpiresAt <= now:
+			return fmt.Errorf("%w: %s expired at %s", ErrPromoNotApplicable, code, promos[index].ExpiresAt)
+		case promos[index].MaxUses > 0 && promos[index].Uses >= promos[index].MaxUses:
+			return fmt.Errorf("%w: %s has been used up", ErrPromoNotApplicable, code)
+		}
+		promo := promos[index]
+		order.Discounts = append(order.Discounts, models.OrderDiscount{Code: promo.Code, Kind: promo.Kind, Description: promo.Description})
+		promos[index].Uses++
+	}
+
+	if err := applyDiscounts(order, promos); err != nil {
+		return err
+	}
+	for _, discount := range order.Discounts[applied:] {
+		if discount.Amount.Amount <= 0 {
+			return fmt.Errorf("%w: %s does not apply to anything on this order", ErrPromoNotApplicable, discount.Code)
+		}
+	}
+	if err := dal.NewPromoCodeRepository().WritePromoCodes(promos); err != nil {
+		return err
+	}
+	return nil
+}
+
+// releasePromoCodes gives back the uses of discounts on an order that will
+// not be charged, e.g. a deleted one.
+func releasePromoCodes(discounts []models.OrderDiscount) {
+	if len(discounts) == 0 {
+		return
+	}
+	promoMu.Lock()
+	defer promoMu.Unlock()
+
+	promos, err := dal.NewPromoCodeRepository().ReadPromoCodes()
+	if err != nil {
+		return
+	}
+	for _, discount := range discounts {
+		if index := findPromoCode(promos, discount.Code); index >= 0 && promos[index].Uses > 0 {
+			promos[index].Uses--
+		}
+	}
+	dal.NewPromoCodeRepository().WritePromoCodes(promos)
+}
+
+// calculateDiscounts recomputes the discounts already on order after its
+// lines changed.
+func calculateDiscounts(order *models.Order) error {
+	if len(order.Discounts) == 0 {
+		return nil
+	}
+	promos, err := dal.NewPromoCodeRepository().ReadPromoCodes()
+	if err != nil {
+		return errors.Join(ErrPromoCodeNotRead, err)
+	}
+	return applyDiscounts(order, promos)
+}
+
+// applyDiscounts sets the amount of every discount on order, in the order
+// they were applied and never more than what is left to pay. A code
+// deleted since it was applied keeps its last amount.
+func applyDiscounts(order *models.Order, promos []models.PromoCode) error {
+	subtotal, err := orderSubtotal(order.Items)
+	if err != nil {
+		return err
+	}
+	remaining := subtotal
+	for i := range order.Discounts {
+		discount := &order.Discounts[i]
+		amount := discount.Amount
+		if index := findPromoCode(promos, discount.Code); index >= 0 {
+			promo := promos[index]
+			if subtotal.Amount < promo.MinSpend.Amount {
+				return fmt.Errorf("%w: %s needs a minimum spend of %s", ErrPromoNotApplicable, promo.Code, promo.MinSpend)
+			}
+			if amount, err = promoDiscount(promo, order.Items); err != nil {
+				return err
+			}
+		}
+		if discount.Amount, err = minMoney(amount, remaining); err != nil {
+			return err
+		}
+		if remaining, err = remaining.Sub(discount.Amount); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+func promoDiscount(promo models.PromoCode, items []models.OrderItem) (models.Money, error) {
+	eligible := models.NewMoney(0)
+	var units []models.Money
+	for _, item := range items {
+		if len(promo.ProductIDs) > 0 && !slices.Contains(promo.ProductIDs, item.ProductID) {
+			continue
+		}
+		if err := addMoney(&eligible, item.UnitPrice.Mul(item.Quantity)); err != nil {
+			return models.Money{}, err
+		}
+		for range item.Quantity {
+			units = append(units, item.UnitPrice)
+		}
+	}
+
+	switch promo.Kind {
+	case PromoPercent:
+		return percentOf(eligible, promo.Value), nil
+	case PromoAmount:
+		return minMoney(promo.Amount, eligible)
+	case PromoBuyXGetY:
+		// The cheapest units are the free ones.
+		slices.SortFunc(units, func(a, b models.Money) int { return cmp.Compare(b.Amount, a.Amount) })
+		free := len(units) / (promo.BuyQuantity + promo.GetQuantity) * promo.GetQuantity
+		amount := models.NewMoney(0)
+		err := addMoney(&amount, units[len(units)-free:]...)
+		return amount, err
+	}
+	return models.NewMoney(0), nil
+}
+
+func orderSubtotal(items []models.OrderItem) (models.Money, error) {
+	subtotal := models.NewMoney(0)
+	for _, item := range items {
+		if err := addMoney(&subtotal, item.UnitPrice.Mul(item.Quantity)); err != nil {
+			return models.Money{}, err
+		}
+	}
+	return subtotal, nil
+}
+
+func orderDiscountTotal(order models.Order) (models.Money, error) {
+	total := models.NewMoney(0)
+	for _, discount := range order.Discounts {
+		if err := addMoney(&total, discount.Amount); err != nil {
+			return models.Money{}, err
+		}
+	}
+	return total, nil
+}
+
+// percentOf is percent % of amount, rounded half away from zero.
+func percentOf(amount models.Money, percent float64) models.Money {
+	amount.Amount = int64(math.Round(float64(amount.Amount) * percent / 100))
+	return amount
+}
+
+func minMoney(a, b models.Money) (models.Money, error) {
+	diff, err := a.Sub(b)
+	if err != nil {
+		return models.Money{}, err
+	}
+	if diff.Amount > 0 {
+		return b, nil
+	}
+	return a, nil
+}
+
+func validatePromoCode(promo *models.PromoCode) error {
+	v := &ValidationError{}
+	if promo.Code == "" {
+		v.Add(Pointer("code"), "code cannot be empty")
+	} else if normalizePromoCode(promo.Code) == LoyaltyCode {
+		v.Add(Pointer("code"), "%s is kept for loyalty redemptions", LoyaltyCode)
+	}
+	switch promo.Kind {
+	case PromoPercent:
+		if promo.Value <= 0 || promo.Value > 100 {
+			v.Add(Pointer("value"), "percent has to be between 0 and 100")
+		}
+	case PromoAmount:
+		if promo.Amount.Amount <= 0 {
+			v.Add(Pointer("amount"), "amount cannot be negative or zero")
+		}
+		if promo.Amount.Currency != "" && promo.Amount.Currency != models.DefaultCurrency {
+			v.Add(Pointer("amount", "currency"), "currency has to be %s", models.DefaultCurrency)
+		}
+		if promo.Value != 0 {
+			v.Add(Pointer("value"), "an amount code takes an amount, not a value")
+		}
+	case PromoBuyXGetY:
+		if promo.BuyQuantity < 1 {
+			v.Add(Pointer("buy_quantity"), "buy_quantity has to be at least 1")
+		}
+		if promo.GetQuantity < 1 {
+			v.Add(Pointer("get_quantity"), "get_quantity has to be at least 1")
+		}
+		if len(promo.ProductIDs) == 0 {
+			v.Add(Pointer("product_ids"), "buy_x_get_y needs the products it applies to")
+		}
+	default:
+		v.Add(Pointer("kind"), "kind has to be %s, %s or %s", PromoPercent, PromoAmount, PromoBuyXGetY)
+	}
+	if promo.MinSpend.Amount < 0 {
+		v.Add(Pointer("min_spend"), "min_spend cannot be negative")
+	}
+	if !promo.MinSpend.IsZero() && promo.MinSpend.Currency != models.DefaultCurrency {
+		v.Add(Pointer("min_spend", "currency"), "currency has to be %s", models.DefaultCurrency)
+	}
+	if promo.MaxUses < 0 {
+		v.Add(Pointer("max_uses"), "max_uses cannot be negative")
+	}
+	if promo.ExpiresAt != "" {
+		// A bare date expires at the end of that day.
+		if expires, err := parseAuditBound(promo.ExpiresAt, true); err != nil {
+			v.Add(Pointer("expires_at"), "%v", err)
+		} else {
+			promo.ExpiresAt = expires.Format(time.DateTime)
+		}
+	}
+	menu, err := dal.NewMenuRepository().ReadMenu()
+	if err != nil {
+		return errors.Join(ErrMenuNotRead, err)
+	}
+	for j, id := range promo.ProductIDs {
+		if !slices.ContainsFunc(menu, func(item models.MenuItem) bool { return item.ID == id }) {
+			v.Add(Pointer("product_ids", j), "menu item %s does not exist", id)
+		}
+	}
+	return v.Err()
+}
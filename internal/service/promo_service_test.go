@@ -0,0 +1,162 @@
+package service
+
+import (
+	"errors"
+	"hot-coffee1/internal/dal"
+	"hot-coffee1/models"
+	"testing"
+	"time"
+)
+
+func orderLine(productID string, quantity int, price int64) models.OrderItem {
+	return models.OrderItem{ProductID: productID, Quantity: quantity, UnitPrice: models.NewMoney(price)}
+}
+
+func TestPromoDiscount(t *testing.T) {
+	tests := []struct {
+		name  string
+		promo models.PromoCode
+		items []models.OrderItem
+		want  int64
+	}{
+		{"percent", models.PromoCode{Kind: PromoPercent, Value: 10}, []models.OrderItem{orderLine("latte", 2, 350)}, 70},
+		{"percent rounds half away from zero", models.PromoCode{Kind: PromoPercent, Value: 15}, []models.OrderItem{orderLine("latte", 1, 333)}, 50},
+		{"percent rounds down below half", models.PromoCode{Kind: PromoPercent, Value: 15}, []models.OrderItem{orderLine("latte", 1, 329)}, 49},
+		{"percent of the listed products only", models.PromoCode{Kind: PromoPercent, Value: 50, ProductIDs: []string{"muffin"}},
+			[]models.OrderItem{orderLine("latte", 1, 350), orderLine("muffin", 1, 200)}, 100},
+		{"amount", models.PromoCode{Kind: PromoAmount, Amount: models.NewMoney(100)}, []models.OrderItem{orderLine("latte", 1, 350)}, 100},
+		{"amount at most what it applies to", models.PromoCode{Kind: PromoAmount, Amount: models.NewMoney(500), ProductIDs: []string{"muffin"}},
+			[]models.OrderItem{orderLine("latte", 1, 350), orderLine("muffin", 1, 200)}, 200},
+		{"buy two get one takes the cheapest", models.PromoCode{Kind: PromoBuyXGetY, BuyQuantity: 2, GetQuantity: 1, ProductIDs: []string{"latte", "muffin"}},
+			[]models.OrderItem{orderLine("latte", 2, 350), orderLine("muffin", 1, 200)}, 200},
+		{"buy two get one needs three", models.PromoCode{Kind: PromoBuyXGetY, BuyQuantity: 2, GetQuantity: 1, ProductIDs: []string{"latte"}},
+			[]models.OrderItem{orderLine("latte", 2, 350)}, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := promoDiscount(tt.promo, tt.items)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got.Amount != tt.want {
+				t.Errorf("discount = %d, want %d", got.Amount, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedeemPromoCodes(t *testing.T) {
+	past := time.Now().Add(-time.Hour).Format(time.DateTime)
+	future := time.Now().Add(time.Hour).Format(time.DateTime)
+	promos := []models.PromoCode{
+		{Code: "TEN", Kind: PromoPercent, Value: 10, Active: true},
+		{Code: "OFF", Kind: PromoPercent, Value: 10},
+		{Code: "OLD", Kind: PromoPercent, Value: 10, Active: true, ExpiresAt: past},
+		{Code: "SOON", Kind: PromoPercent, Value: 10, Active: true, ExpiresAt: future},
+		{Code: "ONCE", Kind: PromoPercent, Value: 10, Active: true, MaxUses: 1, Uses: 1},
+		{Code: "BIG", Kind: PromoAmount, Amount: models.NewMoney(100), Active: true, MinSpend: models.NewMoney(1000)},
+		{Code: "MUFFIN", Kind: PromoPercent, Value: 10, Active: true, ProductIDs: []string{"muffin"}},
+	}
+
+	tests := []struct {
+		name  string
+		codes []string
+		err   error
+		want  int64
+	}{
+		{"active code, any case", []string{" ten "}, nil, 70},
+		{"not expired yet", []string{"SOON"}, nil, 70},
+		{"unknown", []string{"NOPE"}, ErrNotFound, 0},
+		{"inactive", []string{"OFF"}, ErrPromoNotApplicable, 0},
+		{"expired", []string{"OLD"}, ErrPromoNotApplicable, 0},
+		{"used up", []string{"ONCE"}, ErrPromoNotApplicable, 0},
+		{"below the minimum spend", []string{"BIG"}, ErrPromoNotApplicable, 0},
+		{"nothing to apply to", []string{"MUFFIN"}, ErrPromoNotApplicable, 0},
+		{"same code twice", []string{"TEN", "ten"}, ErrPromoNotApplicable, 0},
+		{"one refused code counts none", []string{"TEN", "OLD"}, ErrPromoNotApplicable, 0},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetData(t)
+			if err := dal.NewPromoCodeRepository().WritePromoCodes(promos); err != nil {
+				t.Fatal(err)
+			}
+			order := models.Order{Items: []models.OrderItem{orderLine("latte", 2, 350)}}
+
+			err := redeemPromoCodes(&order, tt.codes)
+			if !errors.Is(err, tt.err) {
+				t.Fatalf("err = %v, want %v", err, tt.err)
+			}
+			total, _ := orderDiscountTotal(order)
+			if tt.err == nil && total.Amount != tt.want {
+				t.Errorf("discount = %d, want %d", total.Amount, tt.want)
+			}
+
+			saved, err := dal.NewPromoCodeRepository().ReadPromoCodes()
+			if err != nil {
+				t.Fatal(err)
+			}
+			for i, promo := range saved {
+				want := promos[i].Uses
+				if tt.err == nil && findPromoCode(promos, tt.codes[0]) == i {
+					want++
+				}
+				if promo.Uses != want {
+					t.Errorf("%s uses = %d, want %d", promo.Code, promo.Uses, want)
+				}
+			}
+		})
+	}
+}
+
+func TestDiscountsNeverExceedTheSubtotal(t *testing.T) {
+	resetData(t)
+	promos := []models.PromoCode{
+		{Code: "THREE", Kind: PromoAmount, Amount: models.NewMoney(300), Active: true},
+		{Code: "MORE", Kind: PromoAmount, Amount: models.NewMoney(300), Active: true},
+	}
+	if err := dal.NewPromoCodeRepository().WritePromoCodes(promos); err != nil {
+		t.Fatal(err)
+	}
+	order := models.Order{Items: []models.OrderItem{orderLine("latte", 1, 350)}}
+
+	if err := redeemPromoCodes(&order, []string{"THREE", "MORE"}); err != nil {
+		t.Fatal(err)
+	}
+	if first, second := order.Discounts[0].Amount.Amount, order.Discounts[1].Amount.Amount; first != 300 || second != 50 {
+		t.Errorf("discounts = %d and %d, want 300 and the remaining 50", first, second)
+	}
+}
+
+func TestPromoCodeStacksOnPricingRule(t *testing.T) {
+	resetData(t)
+	ctx := t.Context()
+	if _, err := NewPricingRuleService().AddNewPricingRule(ctx, models.PricingRule{
+		Name:    "Latte week",
+		Active:  true,
+		Targets: models.RuleTargets{ProductIDs: []string{"latte"}},
+		Effect:  models.RuleEffect{Kind: EffectPercentOff, Value: 10},
+	}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err := NewPromoCodeService().AddNewPromoCode(ctx, models.PromoCode{Code: "TEN", Kind: PromoPercent, Value: 10, Active: true}); err != nil {
+		t.Fatal(err)
+	}
+
+	order, err := NewOrderService().AddNewOrder(ctx, models.Order{
+		CustomerName: "Aigerim",
+		Items:        []models.OrderItem{{ProductID: "latte", Quantity: 2}, {ProductID: "muffin", Quantity: 1}},
+		Discounts:    []models.OrderDiscount{{Code: "TEN"}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	// The rule lowers the latte to 3.15; the code takes 10% off what is left
+	if order.Items[0].UnitPrice.Amount != 315 || order.Items[0].ListPrice.Amount != 350 {
+		t.Errorf("latte at %s (list %s), want 3.15 (list 3.50)", order.Items[0].UnitPrice, order.Items[0].ListPrice)
+	}
+	totals := order.Totals
+	if totals.Subtotal.Amount != 830 || totals.Discounts.Amount != 83 || totals.Total.Amount != 747 {
+		t.Errorf("subtotal %s, discounts %s, total %s; want 8.30, 0.83 and 7.47", totals.Subtotal, totals.Discounts, totals.Total)
+	}
+}
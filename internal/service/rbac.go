@@ -0,0 +1,80 @@
+package service
+
+import (
+	"errors"
+	"slices"
+)
+
+var ErrForbidden = errors.New("permission denied")
+
+type Permission string
+
+const (
+	PermOrdersRead     Permission = "orders:read"
+	PermOrdersWrite    Permission = "orders:write"
+	PermOrdersDelete   Permission = "orders:delete"
+	PermMenuRead       Permission = "menu:read"
+	PermMenuAdmin      Permission = "menu:admin"
+	PermInventoryRead  Permission = "inventory:read"
+	PermInventoryAdmin Permission = "inventory:admin"
+	PermReportsRead    Permission = "reports:read"
+	PermAuditRead      Permission = "audit:read"
+	PermUsersAdmin     Permission = "users:admin"
+	PermWebhooksAdmin  Permission = "webhooks:admin"
+	// PermGiftCardsAdmin issues and reloads gift cards, which creates
+	// balance without a sale.
+	PermGiftCardsAdmin Permission = "gift_cards:admin"
+)
+
+const (
+	RoleBarista = "barista"
+	RoleManager = "manager"
+	RoleOwner   = "owner"
+)
+
+var baristaPermissions = []Permission{
+	PermOrdersRead, PermOrdersWrite, PermMenuRead, PermInventoryRead,
+}
+
+var managerPermissions = append(slices.Clone(baristaPermissions),
+	PermOrdersDelete, PermMenuAdmin, PermInventoryAdmin, PermReportsRead, PermAuditRead, PermGiftCardsAdmin,
+)
+
+var ownerPermissions = append(slices.Clone(managerPermissions),
+	PermUsersAdmin, PermWebhooksAdmin,
+)
+
+var rolePermissions = map[string][]Permission{
+	RoleBarista: baristaPermissions,
+	RoleManager: managerPermissions,
+	RoleOwner:   ownerPermissions,
+}
+
+func Roles() []string {
+	return []string{RoleBarista, RoleManager, RoleOwner}
+}
+
+func IsValidRole(role string) bool {
+	_, ok := rolePermissions[role]
+	return ok
+}
+
+// PermissionsOf returns what a role may do. Accounts created before roles
+// existed have none and fall back to the least privileged role; a role that
+// is not known may do nothing.
+func PermissionsOf(role string) []Permission {
+	if role == "" {
+		return rolePermissions[RoleBarista]
+	}
+	return rolePermissions[role]
+}
+
+func (p Principal) Can(permission Permission) bool {
+	return slices.Contains(PermissionsOf(p.Role), permission)
+}
+
+func validateRole(v *ValidationError, role string) {
+	if !IsValidRole(role) {
+		v.Add(Pointer("role"), "role must be one of %v", Roles())
+	}
+}
@@ -0,0 +1,284 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hot-coffee1/internal/dal"
+	"hot-coffee1/models"
+	"math"
+	"slices"
+)
+
+var ErrTaxNotRead = errors.New("tax configuration was not read")
+
+const (
+	TaxRoundOrder = "order"
+	TaxRoundLine  = "line"
+)
+
+const (
+	RoundHalfUp   = "half_up"
+	RoundHalfEven = "half_even"
+	RoundUp       = "up"
+	RoundDown     = "down"
+)
+
+type Taxes struct{}
+
+type TaxService interface {
+	GetTaxConfig() (models.TaxConfig, error)
+	SetTaxConfig(ctx context.Context, config models.TaxConfig) (models.TaxConfig, error)
+}
+
+func NewTaxService() TaxService {
+	return &Taxes{}
+}
+
+func (t *Taxes) GetTaxConfig() (models.TaxConfig, error) {
+	return readTaxConfig()
+}
+
+func (t *Taxes) SetTaxConfig(ctx context.Context, config models.TaxConfig) (models.TaxConfig, error) {
+	before, err := readTaxConfig()
+	if err != nil {
+		return models.TaxConfig{}, err
+	}
+	if err := validateTaxConfig(config); err != nil {
+		return models.TaxConfig{}, err
+	}
+	if config.Rounding == "" {
+		config.Rounding = TaxRoundOrder
+	}
+	if config.RoundingMode == "" {
+		config.RoundingMode = RoundHalfUp
+	}
+	if config.Rates == nil {
+		config.Rates = []models.TaxRate{}
+	}
+
+	if err := dal.NewTaxRepository().WriteTaxConfig(config); err != nil {
+		return models.TaxConfig{}, err
+	}
+	recordAudit(ctx, "tax", "config", AuditModify, before, config)
+	return config, nil
+}
+
+func readTaxConfig() (models.TaxConfig, error) {
+	config, err := dal.NewTaxRepository().ReadTaxConfig()
+	if err != nil {
+		return models.TaxConfig{}, errors.Join(ErrTaxNotRead, err)
+	}
+	if config.Rates == nil {
+		config.Rates = []models.TaxRate{}
+	}
+	return config, nil
+}
+
+// calculateTotals fills in the totals of order from its line prices and
+// discounts with the tax configuration in effect now. Discounts are spread
+// over the lines in proportion to their price before tax is worked out.
+func calculateTotals(order *models.Order) error {
+	config, err := readTaxConfig()
+	if err != nil {
+		return err
+	}
+	menu, err := dal.NewMenuRepository().ReadMenu()
+	if err != nil {
+		return errors.Join(ErrMenuNotRead, err)
+	}
+	rates, err := categoryTaxRates(config.Rates)
+	if err != nil {
+		return err
+	}
+
+	subtotal, err := orderSubtotal(order.Items)
+	if err != nil {
+		return err
+	}
+	discounts, err := orderDiscountTotal(*order)
+	if err != nil {
+		return err
+	}
+	totals := &models.OrderTotals{
+		Subtotal:     subtotal,
+		Discounts:    discounts,
+		Taxes:        []models.TaxLine{},
+		TaxInclusive: config.Inclusive,
+	}
+	share := 1.0
+	if totals.Subtotal.Amount > 0 {
+		share = float64(totals.Subtotal.Amount-totals.Discounts.Amount) / float64(totals.Subtotal.Amount)
+	}
+	// Tax is worked out in fractions of a minor unit and rounded at the end.
+	taxable := make([]float64, len(config.Rates))
+	taxed := make([]float64, len(config.Rates))
+	charged := make([]bool, len(config.Rates))
+
+	for _, item := range order.Items {
+		var categoryID string
+		if index := slices.IndexFunc(menu, func(product models.MenuItem) bool { return product.ID == item.ProductID }); index >= 0 {
+			categoryID = menu[index].CategoryID
+		}
+		applied := itemTaxRates(config.Rates, rates, categoryID)
+		var combined float64
+		for _, i := range applied {
+			combined += config.Rates[i].Rate
+		}
+
+		amount := float64(item.UnitPrice.Mul(item.Quantity).Amount) * share
+		if config.Inclusive {
+			amount = amount * 100 / (100 + combined)
+		}
+		for _, i := range applied {
+			tax := amount * config.Rates[i].Rate / 100
+			if config.Rounding == TaxRoundLine {
+				tax = roundMinor(tax, config.RoundingMode)
+			}
+			taxable[i] += amount
+			taxed[i] += tax
+			charged[i] = true
+		}
+	}
+
+	totals.Tax = models.NewMoney(0)
+	for i, rate := range config.Rates {
+		if !charged[i] {
+			continue
+		}
+		line := models.TaxLine{
+			RateID:  rate.ID,
+			Name:    rate.Name,
+			Rate:    rate.Rate,
+			Taxable: models.NewMoney(int64(math.Round(taxable[i]))),
+			Amount:  models.NewMoney(int64(roundMinor(taxed[i], config.RoundingMode))),
+		}
+		totals.Taxes = append(totals.Taxes, line)
+		if err := addMoney(&totals.Tax, line.Amount); err != nil {
+			return err
+		}
+	}
+	if config.Inclusive {
+		if totals.Total, err = totals.Subtotal.Sub(totals.Discounts); err != nil {
+			return err
+		}
+		if totals.Net, err = totals.Total.Sub(totals.Tax); err != nil {
+			return err
+		}
+	} else {
+		if totals.Net, err = totals.Subtotal.Sub(totals.Discounts); err != nil {
+			return err
+		}
+		if totals.Total, err = totals.Net.Add(totals.Tax); err != nil {
+			return err
+		}
+	}
+	if err := addServiceCharge(order, totals); err != nil {
+		return err
+	}
+	if totals.Paid, err = capturedPayments(*order); err != nil {
+		return err
+	}
+	if totals.Due, err = totals.Total.Sub(totals.Paid); err != nil {
+		return err
+	}
+	if totals.Due.Amount < 0 {
+		return fmt.Errorf("%w: order total %s is less than the %s already paid, refund a payment first", ErrConflict, totals.Total, totals.Paid)
+	}
+	order.Totals = totals
+	return nil
+}
+
+// categoryTaxRates returns, per rate, the categories it covers including
+// their subcategories; nil for rates that apply by default.
+func categoryTaxRates(rates []models.TaxRate) ([]map[string]bool, error) {
+	covered := make([]map[string]bool, len(rates))
+	for i, rate := range rates {
+		if len(rate.Categories) == 0 {
+			continue
+		}
+		covered[i] = map[string]bool{}
+		for _, id := range rate.Categories {
+			ids, err := categoryWithSubcategories(id)
+			if errors.Is(err, ErrNotFound) {
+				continue
+			} else if err != nil {
+				return nil, err
+			}
+			for id := range ids {
+				covered[i][id] = true
+			}
+		}
+	}
+	return covered, nil
+}
+
+// itemTaxRates returns the indexes of the rates charged on an item of
+// categoryID.
+func itemTaxRates(rates []models.TaxRate, covered []map[string]bool, categoryID string) []int {
+	var specific, general []int
+	for i := range rates {
+		switch {
+		case covered[i] == nil:
+			general = append(general, i)
+		case covered[i][categoryID]:
+			specific = append(specific, i)
+		}
+	}
+	if len(specific) > 0 {
+		return specific
+	}
+	return general
+}
+
+// roundMinor rounds an amount in minor units. The value is first rounded
+// far below a unit so that float noise such as 110.00000000000001 does not
+// push "up" to the next one.
+func roundMinor(value float64, mode string) float64 {
+	value = math.Round(value*1e6) / 1e6
+	switch mode {
+	case RoundHalfEven:
+		return math.RoundToEven(value)
+	case RoundUp:
+		return math.Ceil(value)
+	case RoundDown:
+		return math.Floor(value)
+	}
+	return math.Round(value)
+}
+
+func validateTaxConfig(config models.TaxConfig) error {
+	v := &ValidationError{}
+	if config.Rounding != "" && config.Rounding != TaxRoundOrder && config.Rounding != TaxRoundLine {
+		v.Add(Pointer("rounding"), "rounding has to be %s or %s", TaxRoundOrder, TaxRoundLine)
+	}
+	if mode := config.RoundingMode; mode != "" && mode != RoundHalfUp && mode != RoundHalfEven && mode != RoundUp && mode != RoundDown {
+		v.Add(Pointer("rounding_mode"), "rounding_mode has to be %s, %s, %s or %s", RoundHalfUp, RoundHalfEven, RoundUp, RoundDown)
+	}
+
+	categories, err := dal.NewCategoryRepository().ReadCategories()
+	if err != nil {
+		return errors.Join(ErrCategoryNotRead, err)
+	}
+	ids := map[string]bool{}
+	for j, rate := range config.Rates {
+		if rate.ID == "" {
+			v.Add(Pointer("rates", j, "id"), "rate ID cannot be empty")
+		} else if ids[rate.ID] {
+			v.Add(Pointer("rates", j, "id"), "duplicated rate ID %s", rate.ID)
+		}
+		ids[rate.ID] = true
+		if rate.Name == "" {
+			v.Add(Pointer("rates", j, "name"), "name cannot be empty")
+		}
+		if rate.Rate < 0 || rate.Rate > 100 {
+			v.Add(Pointer("rates", j, "rate"), "rate has to be between 0 and 100")
+		}
+		for k, id := range rate.Categories {
+			if !slices.ContainsFunc(categories, func(category models.Category) bool { return category.ID == id }) {
+				v.Add(Pointer("rates", j, "categories", k), "category %s does not exist", id)
+			}
+		}
+	}
+	return v.Err()
+}
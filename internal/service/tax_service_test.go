@@ -0,0 +1,123 @@
+package service
+
+import (
+	"hot-coffee1/internal/dal"
+	"hot-coffee1/models"
+	"testing"
+)
+
+func TestCalculateTotals(t *testing.T) {
+	vat := models.TaxRate{ID: "vat", Name: "VAT", Rate: 20}
+	tests := []struct {
+		name      string
+		config    models.TaxConfig
+		items     []models.OrderItem
+		discount  int64
+		net, tax  int64
+		total     int64
+		taxByRate []int64
+	}{
+		{"no tax", models.TaxConfig{}, []models.OrderItem{orderLine("latte", 1, 350)}, 0, 350, 0, 350, nil},
+		{"exclusive is added on top", models.TaxConfig{Rates: []models.TaxRate{vat}},
+			[]models.OrderItem{orderLine("latte", 1, 350)}, 0, 350, 70, 420, []int64{70}},
+		{"inclusive is backed out", models.TaxConfig{Inclusive: true, Rates: []models.TaxRate{vat}},
+			[]models.OrderItem{orderLine("latte", 1, 350)}, 0, 292, 58, 350, []int64{58}},
+		{"every matching rate is charged", models.TaxConfig{Rates: []models.TaxRate{vat, {ID: "city", Name: "City", Rate: 5}}},
+			[]models.OrderItem{orderLine("latte", 2, 500)}, 0, 1000, 250, 1250, []int64{200, 50}},
+		{"discounts come off before tax", models.TaxConfig{Rates: []models.TaxRate{{ID: "vat", Name: "VAT", Rate: 10}}},
+			[]models.OrderItem{orderLine("latte", 2, 350), orderLine("muffin", 1, 300)}, 100, 900, 90, 990, []int64{90}},
+		{"inclusive with a discount", models.TaxConfig{Inclusive: true, Rates: []models.TaxRate{{ID: "vat", Name: "VAT", Rate: 10}}},
+			[]models.OrderItem{orderLine("latte", 1, 1200)}, 100, 1000, 100, 1100, []int64{100}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetData(t)
+			if err := dal.NewTaxRepository().WriteTaxConfig(tt.config); err != nil {
+				t.Fatal(err)
+			}
+			order := models.Order{Items: tt.items}
+			if tt.discount > 0 {
+				order.Discounts = []models.OrderDiscount{{Code: "X", Amount: models.NewMoney(tt.discount)}}
+			}
+
+			if err := calculateTotals(&order); err != nil {
+				t.Fatal(err)
+			}
+			totals := order.Totals
+			if totals.Net.Amount != tt.net || totals.Tax.Amount != tt.tax || totals.Total.Amount != tt.total {
+				t.Errorf("net %d, tax %d, total %d; want %d, %d and %d", totals.Net.Amount, totals.Tax.Amount, totals.Total.Amount, tt.net, tt.tax, tt.total)
+			}
+			if totals.Due != totals.Total {
+				t.Errorf("due %s, want the total %s", totals.Due, totals.Total)
+			}
+			if len(totals.Taxes) != len(tt.taxByRate) {
+				t.Fatalf("tax lines %+v, want %d", totals.Taxes, len(tt.taxByRate))
+			}
+			for i, amount := range tt.taxByRate {
+				if totals.Taxes[i].Amount.Amount != amount {
+					t.Errorf("%s = %d, want %d", totals.Taxes[i].RateID, totals.Taxes[i].Amount.Amount, amount)
+				}
+			}
+		})
+	}
+}
+
+func TestCalculateTotalsRounding(t *testing.T) {
+	// 10% of three lines of 0.05 is 0.005 each, 0.015 in all
+	small := []models.OrderItem{orderLine("latte", 1, 5), orderLine("muffin", 1, 5), orderLine("mocha", 1, 5)}
+	// 10% of 0.25 is 0.025
+	quarter := []models.OrderItem{orderLine("latte", 1, 25)}
+	tests := []struct {
+		name     string
+		rounding string
+		mode     string
+		items    []models.OrderItem
+		tax      int64
+	}{
+		{"per order", TaxRoundOrder, RoundHalfUp, small, 2},
+		{"per line", TaxRoundLine, RoundHalfUp, small, 3},
+		{"per line rounding down", TaxRoundLine, RoundDown, small, 0},
+		{"half up", TaxRoundOrder, RoundHalfUp, quarter, 3},
+		{"half even", TaxRoundOrder, RoundHalfEven, quarter, 2},
+		{"up", TaxRoundOrder, RoundUp, quarter, 3},
+		{"down", TaxRoundOrder, RoundDown, quarter, 2},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			resetData(t)
+			config := models.TaxConfig{Rounding: tt.rounding, RoundingMode: tt.mode, Rates: []models.TaxRate{{ID: "vat", Name: "VAT", Rate: 10}}}
+			if err := dal.NewTaxRepository().WriteTaxConfig(config); err != nil {
+				t.Fatal(err)
+			}
+			order := models.Order{Items: tt.items}
+			if err := calculateTotals(&order); err != nil {
+				t.Fatal(err)
+			}
+			if order.Totals.Tax.Amount != tt.tax {
+				t.Errorf("tax = %d, want %d", order.Totals.Tax.Amount, tt.tax)
+			}
+		})
+	}
+}
+
+func TestRoundMinor(t *testing.T) {
+	tests := []struct {
+		value float64
+		mode  string
+		want  float64
+	}{
+		{2.5, RoundHalfUp, 3},
+		{2.5, RoundHalfEven, 2},
+		{3.5, RoundHalfEven, 4},
+		{2.1, RoundUp, 3},
+		{2.9, RoundDown, 2},
+		// float noise must not push up to the next unit
+		{110.00000000000001, RoundUp, 110},
+		{0.1 + 0.2, RoundUp, 1},
+	}
+	for _, tt := range tests {
+		if got := roundMinor(tt.value, tt.mode); got != tt.want {
+			t.Errorf("roundMinor(%v, %s) = %v, want %v", tt.value, tt.mode, got, tt.want)
+		}
+	}
+}
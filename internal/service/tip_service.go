@@ -0,0 +1,290 @@
+package service
+
+import (
+	"cmp"
+	"context"
+	"errors"
+	"fmt"
+	"hot-coffee1/internal/dal"
+	"hot-coffee1/models"
+	"math"
+	"slices"
+	"strconv"
+	"strings"
+	"time"
+)
+
+var ErrServiceChargeNotRead = errors.New("service charge was not read")
+
+type Tips struct{}
+
+type TipService interface {
+	GetServiceCharge() (models.ServiceCharge, error)
+	SetServiceCharge(ctx context.Context, charge models.ServiceCharge) (models.ServiceCharge, error)
+	GetTipReport(from, to string) (models.TipReport, error)
+	GetTipPool(from, to string, hours map[string]float64) (models.TipPool, error)
+}
+
+func NewTipService() TipService {
+	return &Tips{}
+}
+
+func (t *Tips) GetServiceCharge() (models.ServiceCharge, error) {
+	return readServiceCharge()
+}
+
+func (t *Tips) SetServiceCharge(ctx context.Context, charge models.ServiceCharge) (models.ServiceCharge, error) {
+	before, err := readServiceCharge()
+	if err != nil {
+		return models.ServiceCharge{}, err
+	}
+	v := &ValidationError{}
+	if charge.Percent < 0 || charge.Percent > 100 {
+		v.Add(Pointer("percent"), "percent has to be between 0 and 100")
+	}
+	if charge.Percent > 0 && charge.MinPartySize < 1 {
+		v.Add(Pointer("min_party_size"), "min_party_size has to be at least 1")
+	}
+	if err := v.Err(); err != nil {
+		return models.ServiceCharge{}, err
+	}
+	if charge.Name == "" {
+		charge.Name = "Service charge"
+	}
+
+	if err := dal.NewServiceChargeRepository().WriteServiceCharge(charge); err != nil {
+		return models.ServiceCharge{}, err
+	}
+	recordAudit(ctx, "service_charge", "config", AuditModify, before, charge)
+	return charge, nil
+}
+
+func readServiceCharge() (models.ServiceCharge, error) {
+	charge, err := dal.NewServiceChargeRepository().ReadServiceCharge()
+	if err != nil {
+		return models.ServiceCharge{}, errors.Join(ErrServiceChargeNotRead, err)
+	}
+	return charge, nil
+}
+
+// addServiceCharge adds the service charge of a large party and the tip to
+// the total. Neither is taxed nor counted as a sale; both are worked out on
+// the net sale.
+func addServiceCharge(order *models.Order, totals *models.OrderTotals) error {
+	charge, err := readServiceCharge()
+	if err != nil {
+		return err
+	}
+	if charge.Percent > 0 && charge.MinPartySize > 0 && order.PartySize >= charge.MinPartySize {
+		totals.ServiceCharge = percentOf(totals.Net, charge.Percent)
+	}
+	if order.Tip != nil {
+		// The tip is shared with copies of the order, so it is replaced
+		// rather than changed in place.
+		tip := *order.Tip
+		if tip.Percent > 0 {
+			tip.Amount = percentOf(totals.Net, tip.Percent)
+		}
+		order.Tip = &tip
+		totals.Tip = tip.Amount
+	}
+	return addMoney(&totals.Total, totals.ServiceCharge, totals.Tip)
+}
+
+// SetTip puts a tip on an order that is not closed yet, replacing any
+// earlier one. It is credited to the signed-in user unless staff is named.
+func (o *Order) SetTip(ctx context.Context, ID string, tip models.OrderTip) (models.Order, error) {
+	v := &ValidationError{}
+	switch {
+	case tip.Percent != 0 && !tip.Amount.IsZero():
+		v.Add(Pointer("percent"), "give either amount or percent")
+	case tip.Percent < 0 || tip.Percent > 100:
+		v.Add(Pointer("percent"), "percent has to be between 0 and 100")
+	case tip.Amount.Amount < 0:
+		v.Add(Pointer("amount"), "amount cannot be negative")
+	case !tip.Amount.IsZero() && tip.Amount.Currency != models.DefaultCurrency:
+		v.Add(Pointer("amount", "currency"), "currency has to be %s", models.DefaultCurrency)
+	}
+	if err := v.Err(); err != nil {
+		return models.Order{}, err
+	}
+	if tip.Amount.IsZero() {
+		tip.Amount = models.NewMoney(0)
+	}
+	if principal, ok := PrincipalFrom(ctx); ok && tip.Staff == "" && principal.Kind == PrincipalUser {
+		tip.Staff = principal.Name
+	}
+
+	return o.updateCharges(ctx, ID, "tip", func(order *models.Order) error {
+		order.Tip = &tip
+		return nil
+	})
+}
+
+func (o *Order) RemoveTip(ctx context.Context, ID string) (models.Order, error) {
+	return o.updateCharges(ctx, ID, "tip_removed", func(order *models.Order) error {
+		if order.Tip == nil {
+			return fmt.Errorf("tip on order %s %w", ID, ErrNotFound)
+		}
+		order.Tip = nil
+		return nil
+	})
+}
+
+// GetTipReport sums the tips and service charges of closed orders created
+// between from and to, by day and by the staff member credited.
+func (t *Tips) GetTipReport(from, to string) (models.TipReport, error) {
+	orders, err := closedOrdersBetween(from, to)
+	if err != nil {
+		return models.TipReport{}, err
+	}
+
+	zero := models.NewMoney(0)
+	report := models.TipReport{From: from, To: to, Tips: zero, ServiceCharges: zero, ByDay: []models.TipsByDay{}, ByStaff: []models.TipsByStaff{}}
+	for _, order := range orders {
+		totals := order.Totals
+		if totals == nil || totals.Tip.IsZero() && totals.ServiceCharge.IsZero() {
+			continue
+		}
+		if err := errors.Join(addMoney(&report.Tips, totals.Tip), addMoney(&report.ServiceCharges, totals.ServiceCharge)); err != nil {
+			return models.TipReport{}, fmt.Errorf("order %s: %w", order.ID, err)
+		}
+
+		date := order.CreatedAt[:min(len(order.CreatedAt), len(time.DateOnly))]
+		day := slices.IndexFunc(report.ByDay, func(day models.TipsByDay) bool { return day.Date == date })
+		if day < 0 {
+			report.ByDay = append(report.ByDay, models.TipsByDay{Date: date, Tips: zero, ServiceCharges: zero})
+			day = len(report.ByDay) - 1
+		}
+		if err := errors.Join(addMoney(&report.ByDay[day].Tips, totals.Tip), addMoney(&report.ByDay[day].ServiceCharges, totals.ServiceCharge)); err != nil {
+			return models.TipReport{}, fmt.Errorf("order %s: %w", order.ID, err)
+		}
+		report.ByDay[day].Orders++
+
+		if order.Tip == nil || totals.Tip.IsZero() {
+			continue
+		}
+		staff := slices.IndexFunc(report.ByStaff, func(staff models.TipsByStaff) bool { return staff.Staff == order.Tip.Staff })
+		if staff < 0 {
+			report.ByStaff = append(report.ByStaff, models.TipsByStaff{Staff: order.Tip.Staff, Tips: zero})
+			staff = len(report.ByStaff) - 1
+		}
+		if err := addMoney(&report.ByStaff[staff].Tips, totals.Tip); err != nil {
+			return models.TipReport{}, fmt.Errorf("order %s: %w", order.ID, err)
+		}
+		report.ByStaff[staff].Orders++
+	}
+
+	slices.SortFunc(report.ByDay, func(a, b models.TipsByDay) int { return cmp.Compare(a.Date, b.Date) })
+	slices.SortFunc(report.ByStaff, func(a, b models.TipsByStaff) int {
+		return cmp.Or(cmp.Compare(b.Tips.Amount, a.Tips.Amount), cmp.Compare(a.Staff, b.Staff))
+	})
+	return report, nil
+}
+
+// GetTipPool splits the tips of closed orders created between from and to
+// by hours worked. Service charges are not pooled. Shares are rounded down
+// and the cents left over go to the largest remainders, so they add up to
+// the pool exactly.
+func (t *Tips) GetTipPool(from, to string, hours map[string]float64) (models.TipPool, error) {
+	v := &ValidationError{}
+	if len(hours) == 0 {
+		v.Add(Pointer("hours"), "hours worked are required, e.g. alice:6,bob:4.5")
+	}
+	var total float64
+	for staff, worked := range hours {
+		if worked < 0 {
+			v.Add(Pointer("hours", staff), "hours cannot be negative")
+		}
+		total += worked
+	}
+	if len(hours) > 0 && total <= 0 {
+		v.Add(Pointer("hours"), "nobody worked any hours")
+	}
+	if err := v.Err(); err != nil {
+		return models.TipPool{}, err
+	}
+
+	orders, err := closedOrdersBetween(from, to)
+	if err != nil {
+		return models.TipPool{}, err
+	}
+	pool := models.TipPool{From: from, To: to, Pool: models.NewMoney(0), Hours: total, Shares: []models.TipShare{}}
+	for _, order := range orders {
+		if order.Totals != nil {
+			if err := addMoney(&pool.Pool, order.Totals.Tip); err != nil {
+				return models.TipPool{}, fmt.Errorf("order %s: %w", order.ID, err)
+			}
+		}
+	}
+
+	remainders := map[string]float64{}
+	distributed := int64(0)
+	for staff, worked := range hours {
+		exact := float64(pool.Pool.Amount) * worked / total
+		share := models.NewMoney(int64(math.Floor(exact)))
+		remainders[staff] = exact - math.Floor(exact)
+		distributed += share.Amount
+		pool.Shares = append(pool.Shares, models.TipShare{Staff: staff, Hours: worked, Amount: share})
+	}
+	slices.SortFunc(pool.Shares, func(a, b models.TipShare) int {
+		return cmp.Or(cmp.Compare(remainders[b.Staff], remainders[a.Staff]), cmp.Compare(a.Staff, b.Staff))
+	})
+	for i := 0; distributed < pool.Pool.Amount; i = (i + 1) % len(pool.Shares) {
+		pool.Shares[i].Amount.Amount++
+		distributed++
+	}
+	slices.SortFunc(pool.Shares, func(a, b models.TipShare) int { return cmp.Compare(a.Staff, b.Staff) })
+	return pool, nil
+}
+
+func closedOrdersBetween(from, to string) ([]models.Order, error) {
+	v := &ValidationError{}
+	lower, err := parseAuditBound(from, false)
+	if err != nil {
+		v.Add(Pointer("from"), "%s", err.Error())
+	}
+	upper, err := parseAuditBound(to, true)
+	if err != nil {
+		v.Add(Pointer("to"), "%s", err.Error())
+	}
+	if err := v.Err(); err != nil {
+		return nil, err
+	}
+
+	orders, err := NewOrderService().GetAllOrders()
+	if err != nil {
+		return nil, err
+	}
+	var closed []models.Order
+	for _, order := range orders {
+		if strings.ToLower(order.Status) != "closed" {
+			continue
+		}
+		if !lower.IsZero() && order.CreatedAt < lower.Format(time.DateTime) || !upper.IsZero() && order.CreatedAt >= upper.Format(time.DateTime) {
+			continue
+		}
+		closed = append(closed, order)
+	}
+	return closed, nil
+}
+
+// ParseHours reads hours worked given as "alice:6,bob:4.5".
+func ParseHours(value string) (map[string]float64, error) {
+	hours := map[string]float64{}
+	for _, entry := range strings.Split(value, ",") {
+		entry = strings.TrimSpace(entry)
+		if entry == "" {
+			continue
+		}
+		staff, worked, ok := strings.Cut(entry, ":")
+		number, err := strconv.ParseFloat(strings.TrimSpace(worked), 64)
+		if !ok || err != nil || strings.TrimSpace(staff) == "" {
+			v := &ValidationError{}
+			v.Add("hours", "%q is not staff:hours", entry)
+			return nil, v.Err()
+		}
+		hours[strings.TrimSpace(staff)] += number
+	}
+	return hours, nil
+}
@@ -0,0 +1,64 @@
+package service
+
+import (
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+var ErrValidation = errors.New("validation failed")
+
+type FieldViolation struct {
+	Field   string `json:"field"`
+	Message string `json:"message"`
+}
+
+// ValidationError collects every problem found in a payload instead of
+// stopping at the first one. Fields are JSON pointers (RFC 6901) into the
+// request body, e.g. "/ingredients/1/quantity".
+type ValidationError struct {
+	Violations []FieldViolation
+}
+
+func (e *ValidationError) Add(field string, format string, args ...any) {
+	e.Violations = append(e.Violations, FieldViolation{Field: field, Message: fmt.Sprintf(format, args...)})
+}
+
+// Err returns nil when nothing was collected so callers can write
+// `return v.Err()`.
+func (e *ValidationError) Err() error {
+	if len(e.Violations) == 0 {
+		return nil
+	}
+	return e
+}
+
+func (e *ValidationError) Error() string {
+	messages := make([]string, 0, len(e.Violations))
+	for _, v := range e.Violations {
+		messages = append(messages, v.Message)
+	}
+	return strings.Join(messages, "; ")
+}
+
+func (e *ValidationError) Unwrap() error {
+	return ErrValidation
+}
+
+func Pointer(tokens ...any) string {
+	var b strings.Builder
+	for _, token := range tokens {
+		b.WriteByte('/')
+		switch t := token.(type) {
+		case int:
+			b.WriteString(strconv.Itoa(t))
+		default:
+			s := fmt.Sprint(t)
+			s = strings.ReplaceAll(s, "~", "~0")
+			s = strings.ReplaceAll(s, "/", "~1")
+			b.WriteString(s)
+		}
+	}
+	return b.String()
+}
@@ -0,0 +1,185 @@
+package service
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"hot-coffee1/internal/dal"
+	"hot-coffee1/models"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+var ErrVoucherNotRead = errors.New("vouchers were not read")
+
+// voucherMu serialises redemptions so a voucher cannot be used twice.
+var voucherMu sync.Mutex
+
+type Vouchers struct{}
+
+type VoucherService interface {
+	GetAllVouchers() ([]models.Voucher, error)
+	GetVoucher(code string) (models.Voucher, error)
+	IssueVoucher(ctx context.Context, voucher models.Voucher) (models.Voucher, error)
+}
+
+func NewVoucherService() VoucherService {
+	return &Vouchers{}
+}
+
+func findVoucher(vouchers []models.Voucher, code string) int {
+	return slices.IndexFunc(vouchers, func(voucher models.Voucher) bool { return voucher.Code == code })
+}
+
+func readVouchers() ([]models.Voucher, error) {
+	vouchers, err := dal.NewVoucherRepository().ReadVouchers()
+	if err != nil {
+		return nil, errors.Join(ErrVoucherNotRead, err)
+	}
+	return vouchers, nil
+}
+
+func (vs *Vouchers) GetAllVouchers() ([]models.Voucher, error) {
+	vouchers, err := readVouchers()
+	if err != nil {
+		return nil, err
+	}
+	if vouchers == nil {
+		vouchers = []models.Voucher{}
+	}
+	return vouchers, nil
+}
+
+func (vs *Vouchers) GetVoucher(code string) (models.Voucher, error) {
+	vouchers, err := readVouchers()
+	if err != nil {
+		return models.Voucher{}, err
+	}
+	code = normalizeGiftCardCode(code)
+	index := findVoucher(vouchers, code)
+	if index < 0 {
+		return models.Voucher{}, fmt.Errorf("voucher %s %w", code, ErrNotFound)
+	}
+	return vouchers[index], nil
+}
+
+// IssueVoucher records a voucher that was printed or sold. A code is made
+// up when none is given.
+func (vs *Vouchers) IssueVoucher(ctx context.Context, voucher models.Voucher) (models.Voucher, error) {
+	v := &ValidationError{}
+	validatePrice(v, Pointer("value"), voucher.Value)
+	voucher.Code = normalizeGiftCardCode(voucher.Code)
+	if strings.ContainsAny(voucher.Code, "/?# ") {
+		v.Add(Pointer("code"), "code cannot contain spaces, /, ? or #")
+	}
+	if voucher.ExpiresAt != "" {
+		// A bare date expires at the end of that day.
+		if expires, err := parseAuditBound(voucher.ExpiresAt, true); err != nil {
+			v.Add(Pointer("expires_at"), "%v", err)
+		} else {
+			voucher.ExpiresAt = expires.Format(time.DateTime)
+		}
+	}
+	if err := v.Err(); err != nil {
+		return models.Voucher{}, err
+	}
+
+	voucherMu.Lock()
+	defer voucherMu.Unlock()
+
+	vouchers, err := readVouchers()
+	if err != nil {
+		return models.Voucher{}, err
+	}
+	if voucher.Code == "" {
+		for voucher.Code == "" || findVoucher(vouchers, voucher.Code) >= 0 {
+			voucher.Code = strings.ToUpper(randomHex(8))
+		}
+	}
+	if findVoucher(vouchers, voucher.Code) >= 0 {
+		return models.Voucher{}, fmt.Errorf("%w: voucher %s", ErrConflict, voucher.Code)
+	}
+	voucher.OrderID, voucher.PaymentID, voucher.RedeemedAt = "", "", ""
+	voucher.CreatedAt = time.Now().Format(time.DateTime)
+	voucher.CreatedBy = actorName(ctx)
+	if err := dal.NewVoucherRepository().WriteVouchers(append(vouchers, voucher)); err != nil {
+		return models.Voucher{}, err
+	}
+	recordAudit(ctx, "voucher", voucher.Code, AuditCreate, nil, voucher)
+	return voucher, nil
+}
+
+// voucherAmount is what a voucher pays when the payment names no amount:
+// its value, or what is due when that is less. Unknown vouchers are left
+// for redeemVoucher to decline.
+func voucherAmount(code string, due models.Money) models.Money {
+	voucher, err := NewVoucherService().GetVoucher(code)
+	if err != nil {
+		return models.Money{}
+	}
+	amount, err := minMoney(voucher.Value, due)
+	if err != nil {
+		return models.Money{}
+	}
+	return amount
+}
+
+// redeemVoucher uses up the voucher in the reference of a payment.
+func redeemVoucher(ctx context.Context, payment *models.Payment, orderID string) error {
+	voucherMu.Lock()
+	defer voucherMu.Unlock()
+
+	vouchers, err := readVouchers()
+	if err != nil {
+		return err
+	}
+	payment.Reference = normalizeGiftCardCode(payment.Reference)
+	index := findVoucher(vouchers, payment.Reference)
+	if index < 0 {
+		return fmt.Errorf("%w: voucher %s does not exist", ErrPaymentDeclined, payment.Reference)
+	}
+	voucher := &vouchers[index]
+	now := time.Now().Format(time.DateTime)
+	switch over, err := payment.Amount.Sub(voucher.Value); {
+	case err != nil:
+		return err
+	case voucher.RedeemedAt != "":
+		return fmt.Errorf("%w: voucher %s was used on order %s", ErrPaymentDeclined, voucher.Code, voucher.OrderID)
+	case voucher.ExpiresAt != "" && now >= voucher.ExpiresAt:
+		return fmt.Errorf("%w: voucher %s expired at %s", ErrPaymentDeclined, voucher.Code, voucher.ExpiresAt)
+	case over.Amount > 0:
+		return fmt.Errorf("%w: voucher %s is worth %s", ErrPaymentDeclined, voucher.Code, voucher.Value)
+	}
+
+	before := *voucher
+	voucher.OrderID, voucher.PaymentID, voucher.RedeemedAt = orderID, payment.ID, now
+	if err := dal.NewVoucherRepository().WriteVouchers(vouchers); err != nil {
+		return err
+	}
+	recordAudit(ctx, "voucher", voucher.Code, "redeem", before, *voucher)
+	return nil
+}
+
+// refundVoucher makes the voucher of a refunded payment usable again.
+func refundVoucher(ctx context.Context, payment models.Payment) error {
+	voucherMu.Lock()
+	defer voucherMu.Unlock()
+
+	vouchers, err := readVouchers()
+	if err != nil {
+		return err
+	}
+	index := findVoucher(vouchers, payment.Reference)
+	if index < 0 || vouchers[index].PaymentID != payment.ID {
+		return fmt.Errorf("voucher %s of payment %s %w", payment.Reference, payment.ID, ErrNotFound)
+	}
+	before := vouchers[index]
+	vouchers[index].OrderID, vouchers[index].PaymentID, vouchers[index].RedeemedAt = "", "", ""
+	if err := dal.NewVoucherRepository().WriteVouchers(vouchers); err != nil {
+		return err
+	}
+	recordAudit(ctx, "voucher", payment.Reference, "refund", before, vouchers[index])
+	return nil
+}
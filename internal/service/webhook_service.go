@@ -0,0 +1,501 @@
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hot-coffee1/internal/dal"
+	"hot-coffee1/models"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"slices"
+	"strconv"
+	"sync"
+	"time"
+)
+
+var ErrWebhookNotRead = errors.New("webhooks were not read")
+
+const (
+	DeliveryPending   = "pending"
+	DeliveryDelivered = "delivered"
+	DeliveryFailed    = "failed"
+)
+
+// Deliveries are retried with exponential backoff starting at
+// webhookBaseBackoff until webhookMaxAttempts is reached. Finished ones are
+// kept as a log, trimmed to the newest webhookLogSize.
+const (
+	webhookMaxAttempts = 8
+	webhookBaseBackoff = 10 * time.Second
+	webhookMaxBackoff  = time.Hour
+	webhookLogSize     = 5000
+	webhookPollEvery   = time.Second
+)
+
+const (
+	SignatureHeader = "X-Hotcoffee-Signature"
+	EventHeader     = "X-Hotcoffee-Event"
+	DeliveryHeader  = "X-Hotcoffee-Delivery"
+)
+
+// WebhookClient sends the deliveries. Its timeout bounds how long a slow
+// receiver can hold up the outbox.
+var WebhookClient = &http.Client{Timeout: 10 * time.Second}
+
+// outboxMu serialises read-modify-write cycles on the deliveries file
+// between the dispatcher and requests that enqueue or replay.
+var (
+	outboxMu   sync.Mutex
+	outboxWake = make(chan struct{}, 1)
+)
+
+type Webhooks struct {
+	cacheWebhooks []models.Webhook
+}
+
+type WebhookService interface {
+	GetAllWebhooks() ([]models.Webhook, error)
+	GetWebhookByID(id string) (models.Webhook, error)
+	AddNewWebhook(ctx context.Context, webhook models.Webhook) (models.Webhook, error)
+	ModifyWebhook(ctx context.Context, webhook models.Webhook) (models.Webhook, error)
+	DeleteWebhook(ctx context.Context, id string) error
+	GetDeliveries(webhookID, status string) ([]models.WebhookDelivery, error)
+	ReplayDelivery(ctx context.Context, webhookID, deliveryID string) (models.WebhookDelivery, error)
+}
+
+func NewWebhookService() WebhookService {
+	return &Webhooks{cacheWebhooks: []models.Webhook{}}
+}
+
+func (s *Webhooks) loadWebhooks() error {
+	webhooks, err := dal.NewWebhookRepository().ReadWebhooks()
+	if err != nil {
+		return errors.Join(ErrWebhookNotRead, err)
+	}
+	s.cacheWebhooks = webhooks
+	return nil
+}
+
+func (s *Webhooks) findWebhook(id string) int {
+	for i, webhook := range s.cacheWebhooks {
+		if webhook.ID == id {
+			return i
+		}
+	}
+	return -1
+}
+
+func (s *Webhooks) GetAllWebhooks() ([]models.Webhook, error) {
+	if err := s.loadWebhooks(); err != nil {
+		return nil, err
+	}
+	return s.cacheWebhooks, nil
+}
+
+func (s *Webhooks) GetWebhookByID(id string) (models.Webhook, error) {
+	if err := s.loadWebhooks(); err != nil {
+		return models.Webhook{}, err
+	}
+	index := s.findWebhook(id)
+	if index < 0 {
+		return models.Webhook{}, fmt.Errorf("webhook %s %w", id, ErrNotFound)
+	}
+	return s.cacheWebhooks[index], nil
+}
+
+// AddNewWebhook generates the ID, and the signing secret when none is given.
+func (s *Webhooks) AddNewWebhook(ctx context.Context, webhook models.Webhook) (models.Webhook, error) {
+	if err := s.loadWebhooks(); err != nil {
+		return models.Webhook{}, err
+	}
+	if err := validateWebhook(webhook); err != nil {
+		return models.Webhook{}, err
+	}
+
+	webhook.ID = randomHex(4)
+	if webhook.Secret == "" {
+		webhook.Secret = "whsec_" + randomToken(24)
+	}
+	webhook.CreatedAt = time.Now().Format(time.DateTime)
+	s.cacheWebhooks = append(s.cacheWebhooks, webhook)
+	if err := dal.NewWebhookRepository().WriteWebhooks(s.cacheWebhooks); err != nil {
+		return models.Webhook{}, err
+	}
+	recordAudit(ctx, "webhook", webhook.ID, AuditCreate, nil, auditedWebhook(webhook))
+	return webhook, nil
+}
+
+// ModifyWebhook replaces URL, events and active flag; the secret is only
+// rotated when a new one is given.
+func (s *Webhooks) ModifyWebhook(ctx context.Context, webhook models.Webhook) (models.Webhook, error) {
+	if err := s.loadWebhooks(); err != nil {
+		return models.Webhook{}, err
+	}
+	index := s.findWebhook(webhook.ID)
+	if index < 0 {
+		return models.Webhook{}, fmt.Errorf("webhook %s %w", webhook.ID, ErrNotFound)
+	}
+	if err := validateWebhook(webhook); err != nil {
+		return models.Webhook{}, err
+	}
+
+	before := s.cacheWebhooks[index]
+	webhook.CreatedAt = before.CreatedAt
+	if webhook.Secret == "" {
+		webhook.Secret = before.Secret
+	}
+	s.cacheWebhooks[index] = webhook
+	if err := dal.NewWebhookRepository().WriteWebhooks(s.cacheWebhooks); err != nil {
+		return models.Webhook{}, err
+	}
+	recordAudit(ctx, "webhook", webhook.ID, AuditModify, auditedWebhook(before), auditedWebhook(webhook))
+	return webhook, nil
+}
+
+func (s *Webhooks) DeleteWebhook(ctx context.Context, id string) error {
+	if err := s.loadWebhooks(); err != nil {
+		return err
+	}
+	index := s.findWebhook(id)
+	if index < 0 {
+		return fmt.Errorf("webhook %s %w", id, ErrNotFound)
+	}
+	deleted := s.cacheWebhooks[index]
+	s.cacheWebhooks = append(s.cacheWebhooks[:index], s.cacheWebhooks[index+1:]...)
+	if err := dal.NewWebhookRepository().WriteWebhooks(s.cacheWebhooks); err != nil {
+		return err
+	}
+	recordAudit(ctx, "webhook", id, AuditDelete, auditedWebhook(deleted), nil)
+	return nil
+}
+
+// GetDeliveries returns the delivery log of a webhook, newest first.
+func (s *Webhooks) GetDeliveries(webhookID, status string) ([]models.WebhookDelivery, error) {
+	if _, err := s.GetWebhookByID(webhookID); err != nil {
+		return nil, err
+	}
+	deliveries, err := dal.NewWebhookRepository().ReadDeliveries()
+	if err != nil {
+		return nil, errors.Join(ErrWebhookNotRead, err)
+	}
+
+	result := []models.WebhookDelivery{}
+	for i := len(deliveries) - 1; i >= 0; i-- {
+		if deliveries[i].WebhookID == webhookID && (status == "" || deliveries[i].Status == status) {
+			result = append(result, deliveries[i])
+		}
+	}
+	return result, nil
+}
+
+// ReplayDelivery queues the payload of an earlier delivery again as a new
+// delivery, regardless of how the original ended.
+func (s *Webhooks) ReplayDelivery(ctx context.Context, webhookID, deliveryID string) (models.WebhookDelivery, error) {
+	if _, err := s.GetWebhookByID(webhookID); err != nil {
+		return models.WebhookDelivery{}, err
+	}
+
+	outboxMu.Lock()
+	defer outboxMu.Unlock()
+
+	repo := dal.NewWebhookRepository()
+	deliveries, err := repo.ReadDeliveries()
+	if err != nil {
+		return models.WebhookDelivery{}, errors.Join(ErrWebhookNotRead, err)
+	}
+	index := slices.IndexFunc(deliveries, func(d models.WebhookDelivery) bool {
+		return d.ID == deliveryID && d.WebhookID == webhookID
+	})
+	if index < 0 {
+		return models.WebhookDelivery{}, fmt.Errorf("delivery %s %w", deliveryID, ErrNotFound)
+	}
+
+	original := deliveries[index]
+	replay := newDelivery(webhookID, original.EventID, original.EventType, original.Payload)
+	replay.ReplayOf = original.ID
+	if err := repo.WriteDeliveries(trimDeliveryLog(append(deliveries, replay))); err != nil {
+		return models.WebhookDelivery{}, err
+	}
+	recordAudit(ctx, "webhook_delivery", replay.ID, "replay", nil, replay)
+	wakeOutbox()
+	return replay, nil
+}
+
+func validateWebhook(webhook models.Webhook) error {
+	v := &ValidationError{}
+	if u, err := url.Parse(webhook.URL); err != nil || (u.Scheme != "http" && u.Scheme != "https") || u.Host == "" {
+		v.Add(Pointer("url"), "url must be an absolute http or https URL")
+	}
+	if len(webhook.Events) == 0 {
+		v.Add(Pointer("events"), "subscribe to at least one event type, e.g. \"order.closed\" or \"order.*\"")
+	}
+	for i, pattern := range webhook.Events {
+		if pattern == "" {
+			v.Add(Pointer("events", i), "event type cannot be empty")
+		}
+	}
+	return v.Err()
+}
+
+func auditedWebhook(webhook models.Webhook) models.Webhook {
+	webhook.Secret = ""
+	return webhook
+}
+
+func newDelivery(webhookID string, eventID int64, eventType string, payload json.RawMessage) models.WebhookDelivery {
+	now := time.Now().Format(time.DateTime)
+	return models.WebhookDelivery{
+		ID:            randomHex(8),
+		WebhookID:     webhookID,
+		EventID:       eventID,
+		EventType:     eventType,
+		Payload:       payload,
+		Status:        DeliveryPending,
+		CreatedAt:     now,
+		NextAttemptAt: now,
+	}
+}
+
+// trimDeliveryLog drops the oldest finished deliveries beyond the log size;
+// pending ones are never dropped.
+func trimDeliveryLog(deliveries []models.WebhookDelivery) []models.WebhookDelivery {
+	excess := len(deliveries) - webhookLogSize
+	if excess <= 0 {
+		return deliveries
+	}
+	kept := make([]models.WebhookDelivery, 0, webhookLogSize)
+	for _, delivery := range deliveries {
+		if excess > 0 && delivery.Status != DeliveryPending {
+			excess--
+			continue
+		}
+		kept = append(kept, delivery)
+	}
+	return kept
+}
+
+func wakeOutbox() {
+	select {
+	case outboxWake <- struct{}{}:
+	default:
+	}
+}
+
+// StartWebhookDispatcher turns published events into outbox entries for
+// every matching webhook and delivers the outbox in the background until
+// ctx is done. Deliveries still pending from an earlier run are resumed.
+func StartWebhookDispatcher(ctx context.Context) {
+	go func() {
+		lastID := Events.LastID()
+		sub, _, _ := Events.Subscribe(lastID)
+		defer func() { sub.Close() }()
+
+		for {
+			select {
+			case <-ctx.Done():
+				return
+			case event, ok := <-sub.Events():
+				if !ok {
+					var missed []Event
+					sub, missed, _ = Events.Subscribe(lastID)
+					for _, event := range missed {
+						enqueueEvent(event)
+						lastID = event.ID
+					}
+					continue
+				}
+				enqueueEvent(event)
+				lastID = event.ID
+			}
+		}
+	}()
+
+	go func() {
+		ticker := time.NewTicker(webhookPollEvery)
+		defer ticker.Stop()
+		for {
+			deliverDue()
+			select {
+			case <-ctx.Done():
+				return
+			case <-ticker.C:
+			case <-outboxWake:
+			}
+		}
+	}()
+}
+
+func enqueueEvent(event Event) {
+	webhooks, err := dal.NewWebhookRepository().ReadWebhooks()
+	if err != nil {
+		slog.Error("Failed to read webhooks", "error", err)
+		return
+	}
+	var matching []models.Webhook
+	for _, webhook := range webhooks {
+		if webhook.Active && MatchesType(event.Type, webhook.Events...) {
+			matching = append(matching, webhook)
+		}
+	}
+	if len(matching) == 0 {
+		return
+	}
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		slog.Error("Failed to encode webhook payload", "event", event.Type, "error", err)
+		return
+	}
+
+	outboxMu.Lock()
+	defer outboxMu.Unlock()
+	repo := dal.NewWebhookRepository()
+	deliveries, err := repo.ReadDeliveries()
+	if err != nil {
+		slog.Error("Failed to read webhook outbox", "error", err)
+		return
+	}
+	for _, webhook := range matching {
+		deliveries = append(deliveries, newDelivery(webhook.ID, event.ID, event.Type, payload))
+	}
+	if err := repo.WriteDeliveries(trimDeliveryLog(deliveries)); err != nil {
+		slog.Error("Failed to write webhook outbox", "error", err)
+		return
+	}
+	wakeOutbox()
+}
+
+func deliverDue() {
+	outboxMu.Lock()
+	deliveries, err := dal.NewWebhookRepository().ReadDeliveries()
+	outboxMu.Unlock()
+	if err != nil {
+		slog.Error("Failed to read webhook outbox", "error", err)
+		return
+	}
+	webhooks, err := dal.NewWebhookRepository().ReadWebhooks()
+	if err != nil {
+		slog.Error("Failed to read webhooks", "error", err)
+		return
+	}
+
+	now := time.Now()
+	for _, delivery := range deliveries {
+		if delivery.Status != DeliveryPending {
+			continue
+		}
+		if next, err := time.ParseInLocation(time.DateTime, delivery.NextAttemptAt, time.Local); err == nil && next.After(now) {
+			continue
+		}
+		index := slices.IndexFunc(webhooks, func(w models.Webhook) bool { return w.ID == delivery.WebhookID })
+		if index < 0 {
+			delivery.Status, delivery.LastError, delivery.NextAttemptAt = DeliveryFailed, "webhook was deleted", ""
+		} else {
+			delivery = attemptDelivery(webhooks[index], delivery)
+		}
+		saveDelivery(delivery)
+	}
+}
+
+func attemptDelivery(webhook models.Webhook, delivery models.WebhookDelivery) models.WebhookDelivery {
+	delivery.Attempts++
+	status, err := postWebhook(webhook, delivery)
+	delivery.ResponseStatus = status
+	now := time.Now()
+
+	if err == nil {
+		delivery.Status, delivery.LastError = DeliveryDelivered, ""
+		delivery.DeliveredAt, delivery.NextAttemptAt = now.Format(time.DateTime), ""
+		slog.Info("Delivered webhook", "webhook", webhook.ID, "delivery", delivery.ID, "event", delivery.EventType)
+		return delivery
+	}
+
+	delivery.LastError = err.Error()
+	if delivery.Attempts >= webhookMaxAttempts {
+		delivery.Status, delivery.NextAttemptAt = DeliveryFailed, ""
+		slog.Error("Webhook delivery failed for good", "webhook", webhook.ID, "delivery", delivery.ID, "error", err)
+		return delivery
+	}
+	delivery.NextAttemptAt = now.Add(webhookBackoff(delivery.Attempts)).Format(time.DateTime)
+	slog.Warn("Webhook delivery failed, will retry", "webhook", webhook.ID, "delivery", delivery.ID,
+		"attempt", delivery.Attempts, "next", delivery.NextAttemptAt, "error", err)
+	return delivery
+}
+
+func webhookBackoff(attempts int) time.Duration {
+	backoff := webhookBaseBackoff << (attempts - 1)
+	if backoff <= 0 || backoff > webhookMaxBackoff {
+		return webhookMaxBackoff
+	}
+	return backoff
+}
+
+// postWebhook sends one attempt. Receivers verify SignatureHeader, which is
+// "t=<unix time>,v1=<hex HMAC-SHA256 of "<unix time>.<body>" keyed with the
+// webhook secret>".
+func postWebhook(webhook models.Webhook, delivery models.WebhookDelivery) (int, error) {
+	var body bytes.Buffer
+	if err := json.Compact(&body, delivery.Payload); err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	signature := SignWebhook(webhook.Secret, timestamp, body.Bytes())
+
+	req, err := http.NewRequest(http.MethodPost, webhook.URL, &body)
+	if err != nil {
+		return 0, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("User-Agent", "hot-coffee-webhooks/1")
+	req.Header.Set(EventHeader, delivery.EventType)
+	req.Header.Set(DeliveryHeader, delivery.ID)
+	req.Header.Set(SignatureHeader, "t="+timestamp+",v1="+signature)
+
+	resp, err := WebhookClient.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64*1024))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("receiver answered %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+func SignWebhook(secret, timestamp string, payload []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(payload)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+func saveDelivery(delivery models.WebhookDelivery) {
+	outboxMu.Lock()
+	defer outboxMu.Unlock()
+
+	repo := dal.NewWebhookRepository()
+	deliveries, err := repo.ReadDeliveries()
+	if err != nil {
+		slog.Error("Failed to read webhook outbox", "error", err)
+		return
+	}
+	for i := range deliveries {
+		if deliveries[i].ID == delivery.ID {
+			deliveries[i] = delivery
+			break
+		}
+	}
+	if err := repo.WriteDeliveries(deliveries); err != nil {
+		slog.Error("Failed to write webhook outbox", "error", err)
+	}
+}
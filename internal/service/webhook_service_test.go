@@ -0,0 +1,161 @@
+package service
+
+import (
+	"crypto/hmac"
+	"encoding/json"
+	"hot-coffee1/internal/dal"
+	"hot-coffee1/models"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+// receiver is a webhook endpoint that answers with the queued statuses, then
+// 200, and keeps what it was sent.
+type receiver struct {
+	mu       sync.Mutex
+	statuses []int
+	requests []receivedWebhook
+}
+
+type receivedWebhook struct {
+	header http.Header
+	body   []byte
+}
+
+func newReceiver(t *testing.T, statuses ...int) (*receiver, *httptest.Server) {
+	rec := &receiver{statuses: statuses}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		body, _ := io.ReadAll(r.Body)
+		rec.mu.Lock()
+		defer rec.mu.Unlock()
+		rec.requests = append(rec.requests, receivedWebhook{header: r.Header.Clone(), body: body})
+		status := http.StatusOK
+		if len(rec.statuses) > 0 {
+			status, rec.statuses = rec.statuses[0], rec.statuses[1:]
+		}
+		w.WriteHeader(status)
+	}))
+	t.Cleanup(server.Close)
+	return rec, server
+}
+
+func (rec *receiver) received() []receivedWebhook {
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+	return append([]receivedWebhook(nil), rec.requests...)
+}
+
+func testDelivery(webhookID string) models.WebhookDelivery {
+	payload := json.RawMessage(`{"id": 7, "type": "order.closed", "data": {"id": "order1"}}`)
+	return newDelivery(webhookID, 7, "order.closed", payload)
+}
+
+func TestWebhookDeliveryIsSigned(t *testing.T) {
+	rec, server := newReceiver(t)
+	webhook := models.Webhook{ID: "hook1", URL: server.URL, Secret: "whsec_test", Active: true}
+
+	delivery := attemptDelivery(webhook, testDelivery(webhook.ID))
+	if delivery.Status != DeliveryDelivered || delivery.ResponseStatus != http.StatusOK {
+		t.Fatalf("delivery = %+v, want delivered with 200", delivery)
+	}
+
+	requests := rec.received()
+	if len(requests) != 1 {
+		t.Fatalf("receiver got %d requests, want 1", len(requests))
+	}
+	got := requests[0]
+	if got.header.Get(EventHeader) != "order.closed" || got.header.Get(DeliveryHeader) != delivery.ID {
+		t.Errorf("headers %v, want the event type and delivery ID", got.header)
+	}
+	timestamp, signature, ok := strings.Cut(got.header.Get(SignatureHeader), ",v1=")
+	timestamp, found := strings.CutPrefix(timestamp, "t=")
+	if !ok || !found {
+		t.Fatalf("signature header %q is not t=...,v1=...", got.header.Get(SignatureHeader))
+	}
+	if want := SignWebhook(webhook.Secret, timestamp, got.body); !hmac.Equal([]byte(signature), []byte(want)) {
+		t.Errorf("signature %s does not match the body, want %s", signature, want)
+	}
+	if want := SignWebhook("whsec_other", timestamp, got.body); signature == want {
+		t.Error("signature does not depend on the secret")
+	}
+}
+
+func TestWebhookDeliveryIsRetried(t *testing.T) {
+	rec, server := newReceiver(t, http.StatusInternalServerError)
+	webhook := models.Webhook{ID: "hook1", URL: server.URL, Secret: "whsec_test", Active: true}
+
+	before := time.Now()
+	delivery := attemptDelivery(webhook, testDelivery(webhook.ID))
+	if delivery.Status != DeliveryPending || delivery.Attempts != 1 || delivery.ResponseStatus != http.StatusInternalServerError {
+		t.Fatalf("after a 500: %+v, want pending after one attempt", delivery)
+	}
+	next, err := time.ParseInLocation(time.DateTime, delivery.NextAttemptAt, time.Local)
+	if err != nil || next.Before(before.Add(webhookBaseBackoff).Truncate(time.Second)) {
+		t.Errorf("next attempt at %q, want %s after the first", delivery.NextAttemptAt, webhookBaseBackoff)
+	}
+
+	delivery = attemptDelivery(webhook, delivery)
+	if delivery.Status != DeliveryDelivered || delivery.Attempts != 2 || delivery.LastError != "" {
+		t.Fatalf("after a 200: %+v, want delivered after two attempts", delivery)
+	}
+	if len(rec.received()) != 2 {
+		t.Errorf("receiver got %d requests, want 2", len(rec.received()))
+	}
+}
+
+func TestWebhookDeliveryGivesUp(t *testing.T) {
+	_, server := newReceiver(t, http.StatusServiceUnavailable)
+	webhook := models.Webhook{ID: "hook1", URL: server.URL, Secret: "whsec_test", Active: true}
+
+	delivery := testDelivery(webhook.ID)
+	delivery.Attempts = webhookMaxAttempts - 1
+	delivery = attemptDelivery(webhook, delivery)
+	if delivery.Status != DeliveryFailed || delivery.NextAttemptAt != "" {
+		t.Fatalf("last attempt: %+v, want failed for good", delivery)
+	}
+}
+
+func TestReplayDeliveryIsSentAgain(t *testing.T) {
+	resetData(t)
+	rec, server := newReceiver(t)
+	webhooks := NewWebhookService()
+	webhook, err := webhooks.AddNewWebhook(t.Context(), models.Webhook{URL: server.URL, Events: []string{"order.*"}, Active: true})
+	if err != nil {
+		t.Fatal(err)
+	}
+	original := testDelivery(webhook.ID)
+	original.Status, original.Attempts = DeliveryFailed, webhookMaxAttempts
+	if err := dal.NewWebhookRepository().WriteDeliveries([]models.WebhookDelivery{original}); err != nil {
+		t.Fatal(err)
+	}
+
+	replay, err := webhooks.ReplayDelivery(t.Context(), webhook.ID, original.ID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if replay.ReplayOf != original.ID || replay.Status != DeliveryPending || replay.ID == original.ID {
+		t.Fatalf("replay = %+v, want a new pending delivery of %s", replay, original.ID)
+	}
+	deliverDue()
+
+	requests := rec.received()
+	if len(requests) != 1 || requests[0].header.Get(DeliveryHeader) != replay.ID {
+		t.Fatalf("receiver got %d requests, want the replay", len(requests))
+	}
+	var event map[string]any
+	if err := json.Unmarshal(requests[0].body, &event); err != nil || event["type"] != "order.closed" {
+		t.Errorf("replayed body %s, want the original event", requests[0].body)
+	}
+	log, err := webhooks.GetDeliveries(webhook.ID, DeliveryDelivered)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(log) != 1 || log[0].ID != replay.ID {
+		t.Errorf("delivered log = %+v, want only the replay", log)
+	}
+}
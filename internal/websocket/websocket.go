@@ -0,0 +1,277 @@
+// Package websocket is a minimal RFC 6455 server: text and binary messages,
+// fragmentation, ping/pong and the closing handshake. It has no
+// extensions and never acts as a client.
+package websocket
+
+import (
+	"bufio"
+	"crypto/sha1"
+	"encoding/base64"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+const acceptGUID = "258EAFA5-E914-47DA-95CA-C5AB0DC85B11"
+
+const (
+	opContinuation = 0x0
+	opText         = 0x1
+	opBinary       = 0x2
+	opClose        = 0x8
+	opPing         = 0x9
+	opPong         = 0xA
+)
+
+const (
+	CloseNormal        = 1000
+	CloseGoingAway     = 1001
+	CloseProtocolError = 1002
+	ClosePolicy        = 1008
+	CloseTooBig        = 1009
+)
+
+// MaxMessageSize bounds a single (reassembled) message from a client.
+const MaxMessageSize = 1 << 20
+
+var (
+	ErrNotWebSocket = errors.New("not a websocket handshake")
+	ErrClosed       = errors.New("websocket closed")
+)
+
+// CloseError is returned by ReadMessage once the peer closed the
+// connection.
+type CloseError struct {
+	Code   int
+	Reason string
+}
+
+func (e *CloseError) Error() string {
+	return fmt.Sprintf("websocket closed with %d %s", e.Code, e.Reason)
+}
+
+type Conn struct {
+	conn    net.Conn
+	reader  *bufio.Reader
+	writeMu sync.Mutex
+	closed  bool
+}
+
+// Upgrade completes the opening handshake. On failure nothing has been
+// written to w yet, so the caller can still answer with a normal error.
+func Upgrade(w http.ResponseWriter, r *http.Request) (*Conn, error) {
+	if r.Method != http.MethodGet ||
+		!headerContains(r.Header, "Connection", "upgrade") ||
+		!headerContains(r.Header, "Upgrade", "websocket") {
+		return nil, ErrNotWebSocket
+	}
+	if r.Header.Get("Sec-WebSocket-Version") != "13" {
+		return nil, fmt.Errorf("%w: unsupported version %q", ErrNotWebSocket, r.Header.Get("Sec-WebSocket-Version"))
+	}
+	key := r.Header.Get("Sec-WebSocket-Key")
+	if decoded, err := base64.StdEncoding.DecodeString(key); err != nil || len(decoded) != 16 {
+		return nil, fmt.Errorf("%w: invalid Sec-WebSocket-Key", ErrNotWebSocket)
+	}
+
+	netConn, rw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		return nil, err
+	}
+
+	sum := sha1.Sum([]byte(key + acceptGUID))
+	response := "HTTP/1.1 101 Switching Protocols\r\n" +
+		"Upgrade: websocket\r\n" +
+		"Connection: Upgrade\r\n" +
+		"Sec-WebSocket-Accept: " + base64.StdEncoding.EncodeToString(sum[:]) + "\r\n\r\n"
+	if _, err := rw.WriteString(response); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+	if err := rw.Flush(); err != nil {
+		netConn.Close()
+		return nil, err
+	}
+	netConn.SetDeadline(time.Time{})
+	return &Conn{conn: netConn, reader: rw.Reader}, nil
+}
+
+// ReadMessage returns the next text or binary message. Pings are answered
+// and pongs skipped on the way.
+func (c *Conn) ReadMessage() ([]byte, error) {
+	var message []byte
+	started := false
+	for {
+		fin, opcode, payload, err := c.readFrame()
+		if err != nil {
+			return nil, err
+		}
+
+		switch opcode {
+		case opPing:
+			if err := c.writeFrame(opPong, payload); err != nil {
+				return nil, err
+			}
+			continue
+		case opPong:
+			continue
+		case opClose:
+			closeErr := &CloseError{Code: CloseNormal}
+			if len(payload) >= 2 {
+				closeErr.Code = int(binary.BigEndian.Uint16(payload))
+				closeErr.Reason = string(payload[2:])
+			}
+			c.Close(closeErr.Code, "")
+			return nil, closeErr
+		case opText, opBinary:
+			if started {
+				c.Close(CloseProtocolError, "expected continuation frame")
+				return nil, ErrClosed
+			}
+			started = true
+		case opContinuation:
+			if !started {
+				c.Close(CloseProtocolError, "unexpected continuation frame")
+				return nil, ErrClosed
+			}
+		default:
+			c.Close(CloseProtocolError, "unknown opcode")
+			return nil, ErrClosed
+		}
+
+		if len(message)+len(payload) > MaxMessageSize {
+			c.Close(CloseTooBig, "message too big")
+			return nil, ErrClosed
+		}
+		message = append(message, payload...)
+		if fin {
+			return message, nil
+		}
+	}
+}
+
+func (c *Conn) readFrame() (fin bool, opcode byte, payload []byte, err error) {
+	var header [2]byte
+	if _, err = io.ReadFull(c.reader, header[:]); err != nil {
+		return
+	}
+	fin = header[0]&0x80 != 0
+	opcode = header[0] & 0x0F
+	if header[0]&0x70 != 0 {
+		c.Close(CloseProtocolError, "reserved bits set")
+		return false, 0, nil, ErrClosed
+	}
+	if header[1]&0x80 == 0 {
+		c.Close(CloseProtocolError, "client frames must be masked")
+		return false, 0, nil, ErrClosed
+	}
+
+	length := uint64(header[1] & 0x7F)
+	switch length {
+	case 126:
+		var ext [2]byte
+		if _, err = io.ReadFull(c.reader, ext[:]); err != nil {
+			return
+		}
+		length = uint64(binary.BigEndian.Uint16(ext[:]))
+	case 127:
+		var ext [8]byte
+		if _, err = io.ReadFull(c.reader, ext[:]); err != nil {
+			return
+		}
+		length = binary.BigEndian.Uint64(ext[:])
+	}
+	if opcode >= opClose && (length > 125 || !fin) {
+		c.Close(CloseProtocolError, "invalid control frame")
+		return false, 0, nil, ErrClosed
+	}
+	if length > MaxMessageSize {
+		c.Close(CloseTooBig, "message too big")
+		return false, 0, nil, ErrClosed
+	}
+
+	var mask [4]byte
+	if _, err = io.ReadFull(c.reader, mask[:]); err != nil {
+		return
+	}
+	payload = make([]byte, length)
+	if _, err = io.ReadFull(c.reader, payload); err != nil {
+		return
+	}
+	for i := range payload {
+		payload[i] ^= mask[i%4]
+	}
+	return
+}
+
+// WriteMessage sends data as a single text frame. It is safe to call from
+// several goroutines.
+func (c *Conn) WriteMessage(data []byte) error {
+	return c.writeFrame(opText, data)
+}
+
+func (c *Conn) Ping() error {
+	return c.writeFrame(opPing, nil)
+}
+
+func (c *Conn) writeFrame(opcode byte, payload []byte) error {
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closed {
+		return ErrClosed
+	}
+
+	header := []byte{0x80 | opcode, 0}
+	switch length := len(payload); {
+	case length <= 125:
+		header[1] = byte(length)
+	case length <= 0xFFFF:
+		header[1] = 126
+		header = binary.BigEndian.AppendUint16(header, uint16(length))
+	default:
+		header[1] = 127
+		header = binary.BigEndian.AppendUint64(header, uint64(length))
+	}
+
+	c.conn.SetWriteDeadline(time.Now().Add(10 * time.Second))
+	if _, err := c.conn.Write(append(header, payload...)); err != nil {
+		return err
+	}
+	return nil
+}
+
+// Close sends a close frame and closes the connection. Calling it again is a
+// no-op.
+func (c *Conn) Close(code int, reason string) error {
+	payload := binary.BigEndian.AppendUint16(nil, uint16(code))
+	payload = append(payload, reason...)
+	if len(payload) > 125 {
+		payload = payload[:125]
+	}
+	err := c.writeFrame(opClose, payload)
+
+	c.writeMu.Lock()
+	defer c.writeMu.Unlock()
+	if c.closed {
+		return nil
+	}
+	c.closed = true
+	c.conn.Close()
+	return err
+}
+
+func headerContains(header http.Header, name, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
@@ -1,7 +1,19 @@
 package models
 
+// TotalSales reports gross sales (line prices after pricing rules, with
+// tax when prices include it) with promo discounts separately. NetSales is
+// what was sold after discounts without tax, GrandTotal what was charged
+// including tax, service charges and tips.
 type TotalSales struct {
-	Amount float64 `json:"total_sales"`
+	Amount          Money            `json:"total_sales"`
+	Discounts       Money            `json:"discounts"`
+	NetSales        Money            `json:"net_sales"`
+	Tax             Money            `json:"tax"`
+	ServiceCharges  Money            `json:"service_charges"`
+	Tips            Money            `json:"tips"`
+	GrandTotal      Money            `json:"grand_total"`
+	DiscountsByCode map[string]Money `json:"discounts_by_code,omitempty"`
+	TaxByRate       map[string]Money `json:"tax_by_rate,omitempty"`
 }
 
 type PopularItem struct {
@@ -9,6 +21,6 @@ type PopularItem struct {
 	ID          string               `json:"product_id"`
 	Name        string               `json:"name"`
 	Description string               `json:"description"`
-	Price       float64              `json:"price"`
+	Price       Money                `json:"price"`
 	Ingredients []MenuItemIngredient `json:"ingredients"`
 }
@@ -0,0 +1,15 @@
+package models
+
+type Alert struct {
+	ID             string  `json:"id"`
+	IngredientID   string  `json:"ingredient_id"`
+	Kind           string  `json:"kind"`
+	Quantity       float64 `json:"quantity"`
+	Threshold      float64 `json:"threshold"`
+	Unit           string  `json:"unit"`
+	Status         string  `json:"status"`
+	CreatedAt      string  `json:"created_at"`
+	AcknowledgedAt string  `json:"acknowledged_at,omitempty"`
+	AcknowledgedBy string  `json:"acknowledged_by,omitempty"`
+	ResolvedAt     string  `json:"resolved_at,omitempty"`
+}
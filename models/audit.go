@@ -0,0 +1,26 @@
+package models
+
+import "encoding/json"
+
+// AuditEntry is one line of the append-only audit log. Hash is an HMAC of
+// every other field including PrevHash, so editing or dropping a line breaks
+// the chain from that point on.
+type AuditEntry struct {
+	Seq       int64           `json:"seq"`
+	Timestamp string          `json:"timestamp"`
+	Actor     string          `json:"actor"`
+	Entity    string          `json:"entity"`
+	EntityID  string          `json:"entity_id"`
+	Action    string          `json:"action"`
+	Before    json.RawMessage `json:"before,omitempty"`
+	After     json.RawMessage `json:"after,omitempty"`
+	Changes   []AuditChange   `json:"changes,omitempty"`
+	PrevHash  string          `json:"prev_hash"`
+	Hash      string          `json:"hash"`
+}
+
+type AuditChange struct {
+	Field  string          `json:"field"`
+	Before json.RawMessage `json:"before,omitempty"`
+	After  json.RawMessage `json:"after,omitempty"`
+}
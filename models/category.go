@@ -0,0 +1,21 @@
+package models
+
+type Category struct {
+	ID        string `json:"category_id"`
+	Name      string `json:"name"`
+	ParentID  string `json:"parent_id,omitempty"`
+	SortOrder int    `json:"sort_order"`
+}
+
+// MenuTree is the structured menu: top-level categories with their
+// subcategories and items, sorted for display.
+type MenuTree struct {
+	Categories    []MenuTreeNode `json:"categories"`
+	Uncategorized []MenuItem     `json:"uncategorized"`
+}
+
+type MenuTreeNode struct {
+	Category
+	Items         []MenuItem     `json:"items"`
+	Subcategories []MenuTreeNode `json:"subcategories"`
+}
@@ -0,0 +1,13 @@
+package models
+
+// Customer is a regular with a profile. Orders link to it by ID; walk-ins
+// only have Order.CustomerName.
+type Customer struct {
+	ID          string   `json:"customer_id"`
+	Name        string   `json:"name"`
+	Phone       string   `json:"phone,omitempty"`
+	Email       string   `json:"email,omitempty"`
+	Preferences []string `json:"preferences,omitempty"`
+	Notes       string   `json:"notes,omitempty"`
+	CreatedAt   string   `json:"created_at"`
+}
@@ -0,0 +1,26 @@
+package models
+
+// GiftCard is stored value identified by its Code. Issuing and reloading
+// add to Balance; paying for orders takes from it.
+type GiftCard struct {
+	Code       string `json:"code"`
+	Balance    Money  `json:"balance"`
+	CustomerID string `json:"customer_id,omitempty"`
+	Active     bool   `json:"active"`
+	CreatedAt  string `json:"created_at"`
+	CreatedBy  string `json:"created_by"`
+}
+
+// GiftCardTransaction is one change to a gift card's balance. Amount is
+// negative when the card pays; Balance is what is left afterwards.
+type GiftCardTransaction struct {
+	ID        string `json:"id"`
+	Code      string `json:"code"`
+	Kind      string `json:"kind"`
+	Amount    Money  `json:"amount"`
+	Balance   Money  `json:"balance"`
+	OrderID   string `json:"order_id,omitempty"`
+	PaymentID string `json:"payment_id,omitempty"`
+	CreatedAt string `json:"created_at"`
+	CreatedBy string `json:"created_by"`
+}
@@ -1,8 +1,12 @@
 package models
 
 type InventoryItem struct {
-	IngredientID string  `json:"ingredient_id"`
-	Name         string  `json:"name"`
-	Quantity     float64 `json:"quantity"`
-	Unit         string  `json:"unit"`
+	IngredientID      string     `json:"ingredient_id"`
+	Name              string     `json:"name"`
+	Quantity          float64    `json:"quantity"`
+	Unit              string     `json:"unit"`
+	LowStockThreshold float64    `json:"low_stock_threshold,omitempty"`
+	Allergens         []string   `json:"allergens,omitempty"`
+	Dietary           []string   `json:"dietary,omitempty"`
+	Nutrition         *Nutrition `json:"nutrition,omitempty"`
 }
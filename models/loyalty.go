@@ -0,0 +1,66 @@
+package models
+
+// LoyaltyProgram is how customers with a profile earn and redeem rewards.
+// Mode is off, points or stamps.
+//
+// In points mode a closed order earns PointsPerUnit for every 1.00 of its
+// net sale plus the bonuses of matching Rules, and points are redeemed at
+// PointValue each, at least MinRedeem at a time. In stamps mode every unit
+// matching Stamps earns a stamp, and StampsForReward stamps make the
+// cheapest matching unit of an order free ("10th coffee free" is 9).
+// Points and stamps expire ExpiryDays after they were earned; 0 keeps them.
+type LoyaltyProgram struct {
+	Mode            string        `json:"mode"`
+	PointsPerUnit   float64       `json:"points_per_unit,omitempty"`
+	Rules           []LoyaltyRule `json:"rules,omitempty"`
+	PointValue      Money         `json:"point_value,omitzero"`
+	MinRedeem       int           `json:"min_redeem,omitempty"`
+	Stamps          RuleTargets   `json:"stamps,omitzero"`
+	StampsForReward int           `json:"stamps_for_reward,omitempty"`
+	ExpiryDays      int           `json:"expiry_days,omitempty"`
+}
+
+// LoyaltyRule gives PointsPerItem for every unit matching Targets, and
+// BonusPoints once when the order has a matching unit and a net sale of at
+// least MinSpend. Empty targets match every item.
+type LoyaltyRule struct {
+	Name          string      `json:"name"`
+	Targets       RuleTargets `json:"targets,omitzero"`
+	PointsPerItem int         `json:"points_per_item,omitempty"`
+	BonusPoints   int         `json:"bonus_points,omitempty"`
+	MinSpend      Money       `json:"min_spend,omitzero"`
+}
+
+// LoyaltyEntry is one movement on a customer's balance, in Unit (points or
+// stamps). Earned entries keep the part that is not redeemed or expired
+// yet in Remaining; redemptions take from the oldest first.
+type LoyaltyEntry struct {
+	ID         string          `json:"id"`
+	CustomerID string          `json:"customer_id"`
+	Kind       string          `json:"kind"`
+	Unit       string          `json:"unit"`
+	Points     int             `json:"points"`
+	Remaining  int             `json:"remaining,omitempty"`
+	OrderID    string          `json:"order_id,omitempty"`
+	Sources    []LoyaltySource `json:"sources,omitempty"`
+	ExpiresAt  string          `json:"expires_at,omitempty"`
+	CreatedAt  string          `json:"created_at"`
+}
+
+// LoyaltySource is the part of an earned entry a redemption used, so it
+// can be given back with its original expiry.
+type LoyaltySource struct {
+	EntryID string `json:"entry_id"`
+	Points  int    `json:"points"`
+}
+
+type LoyaltyAccount struct {
+	CustomerID string         `json:"customer_id"`
+	Unit       string         `json:"unit"`
+	Balance    int            `json:"balance"`
+	Rewards    int            `json:"rewards,omitempty"`
+	Value      Money          `json:"value,omitzero"`
+	NextExpiry string         `json:"next_expiry,omitempty"`
+	Expiring   int            `json:"expiring,omitempty"`
+	Ledger     []LoyaltyEntry `json:"ledger"`
+}
@@ -4,8 +4,37 @@ type MenuItem struct {
 	ID          string               `json:"product_id"`
 	Name        string               `json:"name"`
 	Description string               `json:"description"`
-	Price       float64              `json:"price"`
+	Price       Money                `json:"price"`
 	Ingredients []MenuItemIngredient `json:"ingredients"`
+	CategoryID  string               `json:"category_id,omitempty"`
+	Tags        []string             `json:"tags,omitempty"`
+	// Images are references (URLs or paths) the menu boards load themselves.
+	Images       []string `json:"images,omitempty"`
+	DisplayOrder int      `json:"display_order,omitempty"`
+	// EightySixed hides the item regardless of stock.
+	EightySixed bool `json:"eighty_sixed,omitempty"`
+	// PriceHistory records every price with the time it took effect, the
+	// last entry being the current Price. ScheduledPrices take effect
+	// automatically once their time has come.
+	PriceHistory    []PriceChange `json:"price_history,omitempty"`
+	ScheduledPrices []PriceChange `json:"scheduled_prices,omitempty"`
+	// Available, MaxMakeable, Allergens, Dietary and Nutrition are computed
+	// from inventory on read and never stored. MaxMakeable is absent for
+	// items that use no stock, NutritionMissing lists the ingredients
+	// without nutrition data.
+	Available        *bool      `json:"available,omitempty"`
+	MaxMakeable      *int       `json:"max_makeable,omitempty"`
+	Allergens        []string   `json:"allergens,omitempty"`
+	Dietary          []string   `json:"dietary,omitempty"`
+	Nutrition        *Nutrition `json:"nutrition,omitempty"`
+	NutritionMissing []string   `json:"nutrition_missing,omitempty"`
+}
+
+type PriceChange struct {
+	ID            string `json:"id,omitempty"`
+	Price         Money  `json:"price"`
+	EffectiveFrom string `json:"effective_from"`
+	ChangedBy     string `json:"changed_by,omitempty"`
 }
 
 type MenuItemIngredient struct {
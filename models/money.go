@@ -0,0 +1,135 @@
+package models
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math"
+	"math/big"
+	"strings"
+)
+
+// DefaultCurrency is the currency of the shop, set from --currency. Amounts
+// written before Money existed are read in it.
+var DefaultCurrency = "USD"
+
+// Money is an exact amount in the minor units of Currency, e.g. cents.
+type Money struct {
+	Amount   int64  `json:"amount"`
+	Currency string `json:"currency"`
+}
+
+// minorDigits lists currencies whose minor unit is not a hundredth.
+var minorDigits = map[string]int{
+	"BHD": 3, "IQD": 3, "JOD": 3, "KWD": 3, "LYD": 3, "OMR": 3, "TND": 3,
+	"CLP": 0, "ISK": 0, "JPY": 0, "KRW": 0, "PYG": 0, "UGX": 0, "VND": 0,
+}
+
+func MinorDigits(currency string) int {
+	if digits, ok := minorDigits[currency]; ok {
+		return digits
+	}
+	return 2
+}
+
+func NewMoney(minor int64) Money {
+	return Money{Amount: minor, Currency: DefaultCurrency}
+}
+
+// ErrCurrencyMismatch is returned when amounts in different currencies
+// are combined.
+var ErrCurrencyMismatch = errors.New("amounts are in different currencies")
+
+// Add returns an error instead of mixing currencies. An amount without a
+// currency takes the other one.
+func (m Money) Add(other Money) (Money, error) {
+	if m.Currency != "" && other.Currency != "" && !strings.EqualFold(m.Currency, other.Currency) {
+		return m, fmt.Errorf("%w: %s and %s", ErrCurrencyMismatch, m, other)
+	}
+	if m.Currency == "" {
+		m.Currency = other.Currency
+	}
+	m.Amount += other.Amount
+	return m, nil
+}
+
+func (m Money) Sub(other Money) (Money, error) {
+	return m.Add(Money{Amount: -other.Amount, Currency: other.Currency})
+}
+
+func (m Money) Mul(n int) Money {
+	m.Amount *= int64(n)
+	return m
+}
+
+// Float is the amount in major units, for ratios and log output only.
+func (m Money) Float() float64 {
+	return float64(m.Amount) / math.Pow10(MinorDigits(m.currency()))
+}
+
+func (m Money) IsZero() bool {
+	return m.Amount == 0
+}
+
+func (m Money) String() string {
+	return fmt.Sprintf("%.*f %s", MinorDigits(m.currency()), m.Float(), m.currency())
+}
+
+func (m Money) currency() string {
+	if m.Currency == "" {
+		return DefaultCurrency
+	}
+	return m.Currency
+}
+
+func (m Money) MarshalJSON() ([]byte, error) {
+	type money Money
+	m.Currency = m.currency()
+	return json.Marshal(money(m))
+}
+
+// UnmarshalJSON also accepts the plain decimal numbers, such as 3.5, that
+// prices were stored as before; they are converted exactly, without going
+// through float64.
+func (m *Money) UnmarshalJSON(data []byte) error {
+	text := strings.TrimSpace(string(data))
+	if text == "null" {
+		return nil
+	}
+	if strings.HasPrefix(text, "{") {
+		type money Money
+		var value money
+		if err := json.Unmarshal(data, &value); err != nil {
+			return err
+		}
+		*m = Money(value)
+		m.Currency = strings.ToUpper(m.currency())
+		return nil
+	}
+
+	value, err := ParseMoney(text)
+	if err != nil {
+		return err
+	}
+	*m = value
+	return nil
+}
+
+// ParseMoney reads a decimal amount in major units, e.g. "3.5", in the
+// shop currency.
+func ParseMoney(text string) (Money, error) {
+	decimal, ok := new(big.Rat).SetString(strings.TrimSpace(text))
+	if !ok {
+		return Money{}, fmt.Errorf("invalid amount %q", text)
+	}
+	scale := new(big.Int).Exp(big.NewInt(10), big.NewInt(int64(MinorDigits(DefaultCurrency))), nil)
+	decimal.Mul(decimal, new(big.Rat).SetInt(scale))
+	minor, rest := new(big.Int).QuoRem(decimal.Num(), decimal.Denom(), new(big.Int))
+	if rest.Abs(rest).Lsh(rest, 1).Cmp(decimal.Denom()) >= 0 {
+		minor.Add(minor, big.NewInt(int64(decimal.Sign())))
+	}
+	if !minor.IsInt64() {
+		return Money{}, fmt.Errorf("amount %s is out of range", text)
+	}
+	return NewMoney(minor.Int64()), nil
+}
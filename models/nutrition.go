@@ -0,0 +1,11 @@
+package models
+
+// Nutrition on an inventory item is per 100 g or ml, or per piece for
+// countable units; on a menu item it is per serving.
+type Nutrition struct {
+	Calories float64 `json:"calories_kcal"`
+	Sugar    float64 `json:"sugar_g"`
+	Fat      float64 `json:"fat_g"`
+	Protein  float64 `json:"protein_g"`
+	Caffeine float64 `json:"caffeine_mg"`
+}
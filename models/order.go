@@ -1,14 +1,41 @@
 package models
 
 type Order struct {
-	ID           string      `json:"order_id"`
-	CustomerName string      `json:"customer_name"`
-	Items        []OrderItem `json:"items"`
-	Status       string      `json:"status"`
-	CreatedAt    string      `json:"created_at"`
+	ID           string `json:"order_id"`
+	CustomerName string `json:"customer_name"`
+	// CustomerID links the order to a customer profile; walk-ins only
+	// have a name.
+	CustomerID string      `json:"customer_id,omitempty"`
+	Items      []OrderItem `json:"items"`
+	// Discounts are filled in by the server; clients only name the codes.
+	Discounts []OrderDiscount `json:"discounts,omitempty"`
+	Totals    *OrderTotals    `json:"totals,omitempty"`
+	Payments  []Payment       `json:"payments,omitempty"`
+	// PartySize is the number of guests; large parties get the service
+	// charge.
+	PartySize int       `json:"party_size,omitempty"`
+	Tip       *OrderTip `json:"tip,omitempty"`
+	// LoyaltyEarned is what the customer earned when the order was closed.
+	LoyaltyEarned int `json:"loyalty_earned,omitempty"`
+	// PickupAt is when an order placed ahead is picked up; it stays
+	// scheduled until it is released to the kitchen.
+	PickupAt  string `json:"pickup_at,omitempty"`
+	Status    string `json:"status"`
+	CreatedAt string `json:"created_at"`
 }
 
 type OrderItem struct {
 	ProductID string `json:"product_id"`
 	Quantity  int    `json:"quantity"`
+	// Name and UnitPrice are snapshots of the menu item when the line was
+	// ordered, so later menu changes do not rewrite past sales.
+	Name      string `json:"name,omitempty"`
+	UnitPrice Money  `json:"unit_price,omitzero"`
+	// ListPrice is the menu price when a pricing rule, named by
+	// AppliedRule, changed UnitPrice.
+	ListPrice   Money  `json:"list_price,omitzero"`
+	AppliedRule string `json:"applied_rule,omitempty"`
+	Status      string `json:"status,omitempty"`
+	StartedAt   string `json:"started_at,omitempty"`
+	DoneAt      string `json:"done_at,omitempty"`
 }
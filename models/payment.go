@@ -0,0 +1,30 @@
+package models
+
+// Payment is one tender towards an order. Clients send the tender, the
+// amount or the items it pays for, what was handed over for cash and a
+// reference (card token, gift card or voucher code); the rest is filled in
+// by the server.
+type Payment struct {
+	ID     string `json:"id"`
+	Tender string `json:"tender"`
+	// Amount is what goes towards the order; for cash, Tendered minus
+	// Change.
+	Amount        Money         `json:"amount"`
+	Tendered      Money         `json:"tendered,omitzero"`
+	Change        Money         `json:"change,omitzero"`
+	Reference     string        `json:"reference,omitempty"`
+	TransactionID string        `json:"transaction_id,omitempty"`
+	Items         []PaymentItem `json:"items,omitempty"`
+	Payer         string        `json:"payer,omitempty"`
+	Status        string        `json:"status"`
+	CreatedAt     string        `json:"created_at"`
+	CreatedBy     string        `json:"created_by"`
+	RefundedAt    string        `json:"refunded_at,omitempty"`
+}
+
+// PaymentItem is a share of an order line paid for by one guest of a split
+// bill.
+type PaymentItem struct {
+	ProductID string `json:"product_id"`
+	Quantity  int    `json:"quantity"`
+}
@@ -0,0 +1,43 @@
+package models
+
+// PickupSchedule governs orders placed ahead for a pickup time. Pickups have
+// to fall in one of the OpeningHours and at most MaxDaysAhead days out (0 is
+// today only). Every SlotMinutes slot takes at most SlotCapacity items (0 is
+// no cap). Orders wait as scheduled until LeadMinutes before pickup, then go
+// to the kitchen. LeadMinutes and MaxDaysAhead are nil when not configured.
+type PickupSchedule struct {
+	OpeningHours []RuleSchedule `json:"opening_hours"`
+	SlotMinutes  int            `json:"slot_minutes"`
+	SlotCapacity int            `json:"slot_capacity,omitempty"`
+	LeadMinutes  *int           `json:"lead_minutes,omitempty"`
+	MaxDaysAhead *int           `json:"max_days_ahead,omitempty"`
+}
+
+// PickupSlot is one slot of a day; Booked counts the items of the orders to
+// be picked up in it.
+type PickupSlot struct {
+	Start     string `json:"start"`
+	Booked    int    `json:"booked"`
+	Capacity  int    `json:"capacity,omitempty"`
+	Available bool   `json:"available"`
+}
+
+// InventoryReservation is what orders that are not closed hold of an
+// ingredient until they are closed. Pickups are the orders for later
+// pickup among them.
+type InventoryReservation struct {
+	IngredientID string              `json:"ingredient_id"`
+	Name         string              `json:"name"`
+	Unit         string              `json:"unit"`
+	OnHand       float64             `json:"on_hand"`
+	Reserved     float64             `json:"reserved"`
+	Available    float64             `json:"available"`
+	Pickups      []PickupReservation `json:"pickups,omitempty"`
+}
+
+type PickupReservation struct {
+	OrderID  string  `json:"order_id"`
+	PickupAt string  `json:"pickup_at"`
+	Status   string  `json:"status"`
+	Quantity float64 `json:"quantity"`
+}
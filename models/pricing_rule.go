@@ -0,0 +1,39 @@
+package models
+
+// PricingRule changes the price of matching order lines while its schedule
+// is in effect, e.g. half-price iced drinks from 15:00 to 17:00.
+type PricingRule struct {
+	ID        string       `json:"id"`
+	Name      string       `json:"name"`
+	Active    bool         `json:"active"`
+	Schedule  RuleSchedule `json:"schedule"`
+	Targets   RuleTargets  `json:"targets"`
+	Effect    RuleEffect   `json:"effect"`
+	CreatedAt string       `json:"created_at"`
+}
+
+// RuleSchedule restricts a rule to weekdays ("mon".."sun"), a daily time
+// window ("15:00" to "17:00", may wrap past midnight) and a date range
+// (inclusive, YYYY-MM-DD). Empty parts do not restrict.
+type RuleSchedule struct {
+	Days      []string `json:"days,omitempty"`
+	From      string   `json:"from,omitempty"`
+	To        string   `json:"to,omitempty"`
+	StartDate string   `json:"start_date,omitempty"`
+	EndDate   string   `json:"end_date,omitempty"`
+}
+
+// RuleTargets match menu items by product ID or by category, including its
+// subcategories.
+type RuleTargets struct {
+	ProductIDs []string `json:"product_ids,omitempty"`
+	Categories []string `json:"categories,omitempty"`
+}
+
+// RuleEffect takes Value % off for percent_off; amount_off takes Amount off
+// and fixed_price sells at Amount.
+type RuleEffect struct {
+	Kind   string  `json:"kind"`
+	Value  float64 `json:"value,omitempty"`
+	Amount Money   `json:"amount,omitzero"`
+}
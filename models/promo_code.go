@@ -0,0 +1,32 @@
+package models
+
+// PromoCode is a discount customers redeem on an order. Kind is percent
+// (Value % off), amount (Amount off) or buy_x_get_y (every BuyQuantity
+// matching units bought, GetQuantity more of the cheapest are free).
+// ProductIDs limits the discount to those products.
+type PromoCode struct {
+	Code        string   `json:"code"`
+	Description string   `json:"description,omitempty"`
+	Kind        string   `json:"kind"`
+	Value       float64  `json:"value,omitempty"`
+	Amount      Money    `json:"amount,omitzero"`
+	BuyQuantity int      `json:"buy_quantity,omitempty"`
+	GetQuantity int      `json:"get_quantity,omitempty"`
+	ProductIDs  []string `json:"product_ids,omitempty"`
+	MinSpend    Money    `json:"min_spend,omitzero"`
+	MaxUses     int      `json:"max_uses,omitempty"`
+	Uses        int      `json:"uses"`
+	ExpiresAt   string   `json:"expires_at,omitempty"`
+	Active      bool     `json:"active"`
+	CreatedAt   string   `json:"created_at"`
+}
+
+// OrderDiscount is a promo code applied to an order and what it took off.
+// The LOYALTY code redeems Points of the customer's loyalty balance.
+type OrderDiscount struct {
+	Code        string `json:"code"`
+	Kind        string `json:"kind,omitempty"`
+	Description string `json:"description,omitempty"`
+	Points      int    `json:"points,omitempty"`
+	Amount      Money  `json:"amount"`
+}
@@ -0,0 +1,50 @@
+package models
+
+// TaxConfig is how the shop charges tax. With Inclusive set, prices
+// already contain the tax and it is backed out of them; otherwise it is
+// added on top. Rounding is "order" (each rate rounded once per order) or
+// "line" (rounded on every line), RoundingMode is half_up, half_even, up
+// or down.
+type TaxConfig struct {
+	Inclusive    bool      `json:"inclusive"`
+	Rounding     string    `json:"rounding,omitempty"`
+	RoundingMode string    `json:"rounding_mode,omitempty"`
+	Rates        []TaxRate `json:"rates"`
+}
+
+// TaxRate is a percentage. Rates without categories apply to every item
+// that no category rate covers; rates with categories replace them for
+// items in those categories or their subcategories. Several matching rates
+// are all charged.
+type TaxRate struct {
+	ID         string   `json:"id"`
+	Name       string   `json:"name"`
+	Rate       float64  `json:"rate"`
+	Categories []string `json:"categories,omitempty"`
+}
+
+// OrderTotals are computed by the server whenever an order is saved. Net
+// is what was sold after discounts without tax, Total what the customer
+// pays including the untaxed service charge and tip, Paid what the
+// payments cover and Due what is left.
+type OrderTotals struct {
+	Subtotal      Money     `json:"subtotal"`
+	Discounts     Money     `json:"discounts"`
+	Net           Money     `json:"net"`
+	Taxes         []TaxLine `json:"taxes"`
+	Tax           Money     `json:"tax"`
+	ServiceCharge Money     `json:"service_charge,omitzero"`
+	Tip           Money     `json:"tip,omitzero"`
+	Total         Money     `json:"total"`
+	Paid          Money     `json:"paid"`
+	Due           Money     `json:"due"`
+	TaxInclusive  bool      `json:"tax_inclusive"`
+}
+
+type TaxLine struct {
+	RateID  string  `json:"rate_id"`
+	Name    string  `json:"name"`
+	Rate    float64 `json:"rate"`
+	Taxable Money   `json:"taxable"`
+	Amount  Money   `json:"amount"`
+}
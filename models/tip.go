@@ -0,0 +1,56 @@
+package models
+
+// OrderTip is the gratuity on an order, either a fixed Amount or Percent of
+// the net sale (Amount then follows the order). Staff is who it is
+// credited to.
+type OrderTip struct {
+	Amount  Money   `json:"amount"`
+	Percent float64 `json:"percent,omitempty"`
+	Staff   string  `json:"staff,omitempty"`
+}
+
+// ServiceCharge is added to orders of parties of at least MinPartySize
+// guests as Percent of the net sale. A zero Percent turns it off.
+type ServiceCharge struct {
+	Name         string  `json:"name"`
+	Percent      float64 `json:"percent"`
+	MinPartySize int     `json:"min_party_size"`
+}
+
+type TipReport struct {
+	From           string        `json:"from,omitempty"`
+	To             string        `json:"to,omitempty"`
+	Tips           Money         `json:"tips"`
+	ServiceCharges Money         `json:"service_charges"`
+	ByDay          []TipsByDay   `json:"by_day"`
+	ByStaff        []TipsByStaff `json:"by_staff"`
+}
+
+type TipsByDay struct {
+	Date           string `json:"date"`
+	Tips           Money  `json:"tips"`
+	ServiceCharges Money  `json:"service_charges"`
+	Orders         int    `json:"orders"`
+}
+
+type TipsByStaff struct {
+	Staff  string `json:"staff"`
+	Tips   Money  `json:"tips"`
+	Orders int    `json:"orders"`
+}
+
+// TipPool splits the tips of a period between staff by the hours they
+// worked.
+type TipPool struct {
+	From   string     `json:"from,omitempty"`
+	To     string     `json:"to,omitempty"`
+	Pool   Money      `json:"pool"`
+	Hours  float64    `json:"hours"`
+	Shares []TipShare `json:"shares"`
+}
+
+type TipShare struct {
+	Staff  string  `json:"staff"`
+	Hours  float64 `json:"hours"`
+	Amount Money   `json:"amount"`
+}
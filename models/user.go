@@ -0,0 +1,16 @@
+package models
+
+type User struct {
+	Username     string `json:"username"`
+	PasswordHash string `json:"password_hash"`
+	Role         string `json:"role"`
+	CreatedAt    string `json:"created_at"`
+}
+
+type APIKey struct {
+	ID        string `json:"id"`
+	Name      string `json:"name"`
+	Hash      string `json:"hash"`
+	Role      string `json:"role"`
+	CreatedAt string `json:"created_at"`
+}
@@ -0,0 +1,15 @@
+package models
+
+// Voucher is a paper voucher worth Value. The first payment that takes it
+// uses it up, even when the order costs less; refunding that payment makes
+// it usable again.
+type Voucher struct {
+	Code       string `json:"code"`
+	Value      Money  `json:"value"`
+	ExpiresAt  string `json:"expires_at,omitempty"`
+	OrderID    string `json:"order_id,omitempty"`
+	PaymentID  string `json:"payment_id,omitempty"`
+	RedeemedAt string `json:"redeemed_at,omitempty"`
+	CreatedAt  string `json:"created_at"`
+	CreatedBy  string `json:"created_by"`
+}
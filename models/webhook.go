@@ -0,0 +1,30 @@
+package models
+
+import "encoding/json"
+
+type Webhook struct {
+	ID        string   `json:"id"`
+	URL       string   `json:"url"`
+	Events    []string `json:"events"`
+	Secret    string   `json:"secret"`
+	Active    bool     `json:"active"`
+	CreatedAt string   `json:"created_at"`
+}
+
+// WebhookDelivery is both the outbox entry and its log: pending deliveries
+// are retried until they are delivered or fail for good.
+type WebhookDelivery struct {
+	ID             string          `json:"id"`
+	WebhookID      string          `json:"webhook_id"`
+	EventID        int64           `json:"event_id"`
+	EventType      string          `json:"event_type"`
+	Payload        json.RawMessage `json:"payload"`
+	Status         string          `json:"status"`
+	Attempts       int             `json:"attempts"`
+	ResponseStatus int             `json:"response_status,omitempty"`
+	LastError      string          `json:"last_error,omitempty"`
+	ReplayOf       string          `json:"replay_of,omitempty"`
+	CreatedAt      string          `json:"created_at"`
+	NextAttemptAt  string          `json:"next_attempt_at,omitempty"`
+	DeliveredAt    string          `json:"delivered_at,omitempty"`
+}